@@ -0,0 +1,121 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Flags in the (attributes) file saying which values it contains
+const (
+	AttributesCrc32    uint32 = 0x01
+	AttributesFileTime uint32 = 0x02
+	AttributesMd5      uint32 = 0x04
+	AttributesPatchBit uint32 = 0x08
+)
+
+// Attributes holds the contents of the (attributes) file, which stores
+// extra values for each entry in the block table.  Values that weren't
+// recorded for a block are zero.
+type Attributes struct {
+	Version uint32
+	Flags   uint32
+
+	Crc32    []uint32
+	FileTime []uint64
+	Md5      [][16]byte
+}
+
+// Attributes reads the (attributes) file from the MPQ.
+func (mpq *Mpq) Attributes() (attributes *Attributes, err error) {
+	data, err := mpq.ReadFile("(attributes)")
+	if err != nil {
+		return nil, err
+	}
+
+	return readAttributes(data, len(mpq.BlockEntries))
+}
+
+func readAttributes(data []byte, blocks int) (attributes *Attributes, err error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("Attributes file is too small")
+	}
+
+	attributes = new(Attributes)
+	attributes.Version = binary.LittleEndian.Uint32(data[:4])
+	attributes.Flags = binary.LittleEndian.Uint32(data[0x04 : 0x04+4])
+
+	offset := 8
+	need := func(size int) error {
+		if offset+size*blocks > len(data) {
+			return fmt.Errorf("Attributes file is too small for %v blocks",
+				blocks)
+		}
+		return nil
+	}
+
+	if attributes.Flags&AttributesCrc32 != 0 {
+		err = need(4)
+		if err != nil {
+			return nil, err
+		}
+		attributes.Crc32 = make([]uint32, blocks)
+		for idx := range attributes.Crc32 {
+			attributes.Crc32[idx] = binary.LittleEndian.Uint32(data[offset : offset+4])
+			offset += 4
+		}
+	}
+
+	if attributes.Flags&AttributesFileTime != 0 {
+		err = need(8)
+		if err != nil {
+			return nil, err
+		}
+		attributes.FileTime = make([]uint64, blocks)
+		for idx := range attributes.FileTime {
+			attributes.FileTime[idx] = binary.LittleEndian.Uint64(data[offset : offset+8])
+			offset += 8
+		}
+	}
+
+	if attributes.Flags&AttributesMd5 != 0 {
+		err = need(16)
+		if err != nil {
+			return nil, err
+		}
+		attributes.Md5 = make([][16]byte, blocks)
+		for idx := range attributes.Md5 {
+			copy(attributes.Md5[idx][:], data[offset:offset+16])
+			offset += 16
+		}
+	}
+
+	return attributes, nil
+}
@@ -28,11 +28,7 @@
 
 package mpq
 
-import (
-	"encoding/binary"
-	"fmt"
-	"strings"
-)
+import ()
 
 var blockEncryptionTable []uint32
 
@@ -80,9 +76,16 @@ func hashString(input string, offset uint16) (hash uint32) {
 	var seed1 uint32 = 0x7FED7FED
 	var seed2 uint32 = 0xEEEEEEEE
 
-	str := strings.ToUpper(input)
+	// Names are hashed byte by byte, upper casing only ASCII letters
+	// and treating both slashes as path separators.
+	for idx := 0; idx < len(input); idx++ {
+		curChar := input[idx]
+		if curChar >= 'a' && curChar <= 'z' {
+			curChar -= 'a' - 'A'
+		} else if curChar == '/' {
+			curChar = '\\'
+		}
 
-	for _, curChar := range str {
 		value := blockEncryptionTable[offset+uint16(curChar)]
 		seed1 = (value ^ (seed1 + seed2)) & 0xFFFFFFFF
 		seed2 = (uint32(curChar) + seed1 + seed2 + (seed2 << 5) + 3) & 0xFFFFFFFF
@@ -92,22 +95,11 @@ func hashString(input string, offset uint16) (hash uint32) {
 }
 
 func (encryptor *blockEncryptor) decrypt(table *[]byte) (err error) {
-	var seed1 uint32 = hashString(encryptor.key, encryptor.offset)
-	var seed2 uint32 = 0xEEEEEEEE
-
-	fmt.Printf("")
-
-	size := len(*table)
-	pos := 0
-	for ; size >= 4; size -= 4 {
-		seed2 += blockEncryptionTable[0x400+(seed1&0xFF)]
-		curEntry := binary.LittleEndian.Uint32((*table)[pos : pos+4])
-		entry := curEntry ^ (seed1 + seed2)
-		seed1 = ((^seed1 << 0x15) + 0x11111111) | (seed1 >> 0x0B)
-		seed2 = uint32(entry) + seed2 + (seed2 << 5) + 3
+	Decrypt(*table, hashString(encryptor.key, encryptor.offset))
+	return
+}
 
-		binary.LittleEndian.PutUint32((*table)[pos:pos+4], entry)
-		pos += 4
-	}
+func (encryptor *blockEncryptor) encrypt(table *[]byte) (err error) {
+	Encrypt(*table, hashString(encryptor.key, encryptor.offset))
 	return
 }
@@ -34,12 +34,12 @@ import (
 )
 
 type BlockEntry struct {
-	XMLName xml.Name `xml:"blockEntry"`
+	XMLName xml.Name `xml:"blockEntry" json:"-"`
 
-	FilePosition   uint32 `xml:"filePosition"`
-	CompressedSize uint32 `xml:"compressedSize"`
-	FileSize       uint32 `xml:"fileSize"`
-	Flags          uint32 `xml:"flags"`
+	FilePosition   uint32 `xml:"filePosition" json:"filePosition"`
+	CompressedSize uint32 `xml:"compressedSize" json:"compressedSize"`
+	FileSize       uint32 `xml:"fileSize" json:"fileSize"`
+	Flags          uint32 `xml:"flags" json:"flags"`
 }
 
 func newBlockEntry(data []byte) (entry *BlockEntry) {
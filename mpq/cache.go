@@ -0,0 +1,138 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"container/list"
+)
+
+// CacheStats describes how well the decompressed file cache is working.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+
+	Entries int
+	Size    int64 // Bytes of decompressed data currently cached
+	MaxSize int64
+}
+
+type cacheEntry struct {
+	filename string
+	data     []byte
+}
+
+// fileCache holds decompressed files, evicting the least recently
+// used ones once the total size goes over maxSize.
+type fileCache struct {
+	maxSize int64
+	size    int64
+
+	order   *list.List
+	entries map[string]*list.Element
+
+	stats CacheStats
+}
+
+func newFileCache(maxSize int64) (cache *fileCache) {
+	cache = new(fileCache)
+
+	cache.maxSize = maxSize
+	cache.order = list.New()
+	cache.entries = make(map[string]*list.Element)
+
+	return
+}
+
+func (cache *fileCache) get(filename string) (data []byte, found bool) {
+	element, found := cache.entries[filename]
+	if !found {
+		cache.stats.Misses++
+		return nil, false
+	}
+
+	cache.stats.Hits++
+	cache.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).data, true
+}
+
+func (cache *fileCache) put(filename string, data []byte) {
+	size := int64(len(data))
+	if size > cache.maxSize {
+		// Caching this would just evict everything else
+		return
+	}
+
+	element, found := cache.entries[filename]
+	if found {
+		cache.size -= int64(len(element.Value.(*cacheEntry).data))
+		element.Value.(*cacheEntry).data = data
+		cache.order.MoveToFront(element)
+	} else {
+		element = cache.order.PushFront(&cacheEntry{filename, data})
+		cache.entries[filename] = element
+	}
+	cache.size += size
+
+	for cache.size > cache.maxSize {
+		oldest := cache.order.Back()
+		entry := oldest.Value.(*cacheEntry)
+		cache.order.Remove(oldest)
+		delete(cache.entries, entry.filename)
+		cache.size -= int64(len(entry.data))
+		cache.stats.Evictions++
+	}
+}
+
+// EnableCache keeps files read with ReadFile in memory so reading them
+// again doesn't decompress them again.  The least recently used files
+// are evicted once more than maxSize bytes are cached.  A maxSize of
+// zero disables the cache.
+func (mpq *Mpq) EnableCache(maxSize int64) {
+	if maxSize <= 0 {
+		mpq.cache = nil
+		return
+	}
+
+	mpq.cache = newFileCache(maxSize)
+}
+
+// CacheStats returns the current statistics for the file cache.
+func (mpq *Mpq) CacheStats() (stats CacheStats) {
+	if mpq.cache == nil {
+		return
+	}
+
+	stats = mpq.cache.stats
+	stats.Entries = len(mpq.cache.entries)
+	stats.Size = mpq.cache.size
+	stats.MaxSize = mpq.cache.maxSize
+
+	return
+}
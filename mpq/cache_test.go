@@ -0,0 +1,124 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type CacheSuite struct{}
+
+var _ = Suite(&CacheSuite{})
+
+func (s *CacheSuite) TestCacheDisabled(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpq(reader)
+	c.Assert(err, IsNil)
+
+	_, err = mpq.ReadFile("replay.details")
+	c.Assert(err, IsNil)
+	c.Check(mpq.CacheStats(), Equals, CacheStats{})
+}
+
+func (s *CacheSuite) TestCacheHitsAndMisses(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpq(reader)
+	c.Assert(err, IsNil)
+	mpq.EnableCache(4096)
+
+	first, err := mpq.ReadFile("replay.details")
+	c.Assert(err, IsNil)
+	second, err := mpq.ReadFile("replay.details")
+	c.Assert(err, IsNil)
+	c.Check(second, DeepEquals, first)
+
+	// Changing the returned data mustn't change the cached copy
+	first[0] = first[0] + 1
+	third, err := mpq.ReadFile("replay.details")
+	c.Assert(err, IsNil)
+	c.Check(third, DeepEquals, second)
+
+	stats := mpq.CacheStats()
+	c.Check(stats.Hits, Equals, uint64(2))
+	c.Check(stats.Misses, Equals, uint64(1))
+	c.Check(stats.Entries, Equals, 1)
+	c.Check(stats.Size, Equals, int64(593))
+	c.Check(stats.MaxSize, Equals, int64(4096))
+}
+
+func (s *CacheSuite) TestCacheEviction(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpq(reader)
+	c.Assert(err, IsNil)
+	mpq.EnableCache(950)
+
+	// 593 + 314 fits, adding another 96 doesn't
+	mpq.ReadFile("replay.details")
+	mpq.ReadFile("replay.message.events")
+	mpq.ReadFile("replay.details")
+	mpq.ReadFile("replay.load.info")
+
+	stats := mpq.CacheStats()
+	c.Check(stats.Evictions, Equals, uint64(1))
+	c.Check(stats.Entries, Equals, 2)
+	c.Check(stats.Size, Equals, int64(593+96))
+
+	// The least recently used file was the one evicted
+	mpq.ReadFile("replay.details")
+	c.Check(mpq.CacheStats().Hits, Equals, uint64(2))
+	mpq.ReadFile("replay.message.events")
+	c.Check(mpq.CacheStats().Misses, Equals, uint64(4))
+}
+
+func (s *CacheSuite) TestCacheSkipsLargeFiles(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpq(reader)
+	c.Assert(err, IsNil)
+	mpq.EnableCache(1024)
+
+	mpq.ReadFile("replay.details")
+	mpq.ReadFile("replay.game.events")
+
+	stats := mpq.CacheStats()
+	c.Check(stats.Entries, Equals, 1)
+	c.Check(stats.Evictions, Equals, uint64(0))
+}
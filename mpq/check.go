@@ -0,0 +1,417 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"crypto/md5"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+)
+
+type CheckSeverity int
+
+const (
+	CheckWarning CheckSeverity = iota
+	CheckError
+)
+
+func (severity CheckSeverity) String() string {
+	if severity == CheckError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Areas of the archive a CheckProblem can be found in
+const (
+	CheckAreaHeader      = "header"
+	CheckAreaHashTable   = "hash table"
+	CheckAreaBlockTable  = "block table"
+	CheckAreaSectorTable = "sector table"
+	CheckAreaListfile    = "listfile"
+	CheckAreaAttributes  = "attributes"
+)
+
+// CheckProblem is a single problem found by Check.
+type CheckProblem struct {
+	Severity CheckSeverity
+	Area     string
+	Index    int    // Hash or block table index, -1 if it doesn't apply
+	Filename string // Empty if the problem isn't with a known file
+	Message  string
+}
+
+func (problem *CheckProblem) String() string {
+	location := problem.Area
+	if problem.Index >= 0 {
+		location += fmt.Sprintf(" #%v", problem.Index)
+	}
+	if len(problem.Filename) > 0 {
+		location += fmt.Sprintf(" (%v)", problem.Filename)
+	}
+
+	return fmt.Sprintf("%v: %v: %v", problem.Severity, location, problem.Message)
+}
+
+// CheckReport is the result of checking an archive's integrity.
+type CheckReport struct {
+	Problems []*CheckProblem
+
+	HashEntries  int // Hash table entries in use
+	BlockEntries int // Block table entries in use
+	KnownFiles   int // Files whose names are known
+	FilesChecked int // Files whose contents were verified
+}
+
+// Ok reports whether the archive has no errors.  There may still
+// be warnings.
+func (report *CheckReport) Ok() bool {
+	for _, problem := range report.Problems {
+		if problem.Severity == CheckError {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (report *CheckReport) add(severity CheckSeverity, area string, index int,
+	filename string, format string, args ...interface{}) {
+	report.Problems = append(report.Problems, &CheckProblem{
+		Severity: severity,
+		Area:     area,
+		Index:    index,
+		Filename: filename,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Check validates the structure of the archive and the contents of the
+// files it can find names for, returning a report of any problems.
+func (mpq *Mpq) Check() (report *CheckReport) {
+	report = new(CheckReport)
+
+	mpq.checkHeader(report)
+	blockNames := mpq.checkHashTable(report)
+	mpq.checkBlockTable(report, blockNames)
+	mpq.checkListfile(report, blockNames)
+	mpq.checkFiles(report, blockNames)
+
+	return report
+}
+
+func (mpq *Mpq) checkHeader(report *CheckReport) {
+	header := &mpq.Header
+	archiveEnd := int64(mpq.ArchiveOffset) + int64(header.ArchiveSize)
+
+	if archiveEnd > mpq.size {
+		report.add(CheckError, CheckAreaHeader, -1, "",
+			"archive size %v goes past the end of the file (%v bytes)",
+			header.ArchiveSize, mpq.size-int64(mpq.ArchiveOffset))
+	} else if archiveEnd < mpq.size {
+		report.add(CheckWarning, CheckAreaHeader, -1, "",
+			"%v bytes of data after the end of the archive",
+			mpq.size-archiveEnd)
+	}
+
+	expectedSize := map[uint16]uint32{0: 32, 1: 44}
+	size, known := expectedSize[header.FormatVersion]
+	if !known {
+		report.add(CheckWarning, CheckAreaHeader, -1, "",
+			"unknown format version %v", header.FormatVersion)
+	} else if header.HeaderSize != size {
+		report.add(CheckError, CheckAreaHeader, -1, "",
+			"header size %v doesn't match format version %v (expected %v)",
+			header.HeaderSize, header.FormatVersion, size)
+	}
+
+	if header.HashTableEntries&(header.HashTableEntries-1) != 0 {
+		report.add(CheckError, CheckAreaHeader, -1, "",
+			"hash table size %v isn't a power of two", header.HashTableEntries)
+	}
+
+	hashEnd := uint64(header.HashTableOffset) + uint64(header.HashTableEntries)*16
+	if hashEnd > uint64(header.ArchiveSize) {
+		report.add(CheckError, CheckAreaHeader, -1, "",
+			"hash table ends at %v, past the end of the archive", hashEnd)
+	}
+	blockEnd := uint64(header.BlockTableOffset) + uint64(header.BlockTableEntries)*16
+	if blockEnd > uint64(header.ArchiveSize) {
+		report.add(CheckError, CheckAreaHeader, -1, "",
+			"block table ends at %v, past the end of the archive", blockEnd)
+	}
+}
+
+// checkHashTable validates the hash table and returns the names known
+// for each block, keyed by block index.
+func (mpq *Mpq) checkHashTable(report *CheckReport) (blockNames map[uint32]string) {
+	blockNames = make(map[uint32]string)
+	for filename, file := range mpq.files {
+		for idx, entry := range mpq.HashEntries {
+			if entry == file.hash {
+				blockNames[entry.BlockIndex] = filename
+				if !mpq.inHashSlot(filename, idx) {
+					report.add(CheckError, CheckAreaHashTable, idx, filename,
+						"entry can't be reached from the slot its name hashes to")
+				}
+			}
+		}
+	}
+
+	type localeKey struct {
+		block    uint32
+		language uint16
+	}
+	seen := make(map[localeKey]int)
+
+	for idx, entry := range mpq.HashEntries {
+		if entry.BlockIndex == hashEntryEmpty || entry.BlockIndex == hashEntryDeleted {
+			continue
+		}
+		report.HashEntries++
+
+		filename := blockNames[entry.BlockIndex]
+		if entry.BlockIndex >= uint32(len(mpq.BlockEntries)) {
+			report.add(CheckError, CheckAreaHashTable, idx, filename,
+				"block index %v is out of range (%v blocks)",
+				entry.BlockIndex, len(mpq.BlockEntries))
+			continue
+		}
+		if mpq.BlockEntries[entry.BlockIndex].Flags&FlagExists == 0 {
+			report.add(CheckError, CheckAreaHashTable, idx, filename,
+				"refers to block %v which isn't in use", entry.BlockIndex)
+		}
+
+		key := localeKey{entry.BlockIndex, entry.Language}
+		if other, found := seen[key]; found {
+			report.add(CheckWarning, CheckAreaHashTable, idx, filename,
+				"shares block %v with hash entry %v", entry.BlockIndex, other)
+		}
+		seen[key] = idx
+	}
+
+	return blockNames
+}
+
+// inHashSlot reports whether a lookup for filename that starts at the
+// slot its name hashes to reaches the given slot before an empty one.
+func (mpq *Mpq) inHashSlot(filename string, slot int) bool {
+	count := uint32(len(mpq.HashEntries))
+	if count == 0 || count&(count-1) != 0 {
+		return true
+	}
+
+	idx := hashString(filename, 0x000) & (count - 1)
+	for steps := uint32(0); steps < count; steps++ {
+		if int(idx) == slot {
+			return true
+		}
+		if mpq.HashEntries[idx].BlockIndex == hashEntryEmpty {
+			return false
+		}
+		idx = (idx + 1) & (count - 1)
+	}
+
+	return false
+}
+
+func (mpq *Mpq) checkBlockTable(report *CheckReport, blockNames map[uint32]string) {
+	type extent struct {
+		index      int
+		start, end uint64
+	}
+	extents := []extent{}
+
+	for idx, block := range mpq.BlockEntries {
+		if block.Flags&FlagExists == 0 {
+			continue
+		}
+		report.BlockEntries++
+		filename := blockNames[uint32(idx)]
+
+		start := uint64(block.FilePosition)
+		end := start + uint64(block.CompressedSize)
+		if end > uint64(mpq.Header.ArchiveSize) {
+			report.add(CheckError, CheckAreaBlockTable, idx, filename,
+				"data at %v-%v is outside the archive (%v bytes)",
+				start, end, mpq.Header.ArchiveSize)
+			continue
+		}
+		if block.CompressedSize > 0 {
+			extents = append(extents, extent{idx, start, end})
+		}
+
+		if block.Flags&(FlagCompress|FlagImplode) == 0 &&
+			block.CompressedSize != block.FileSize {
+			report.add(CheckError, CheckAreaBlockTable, idx, filename,
+				"uncompressed file stored in %v bytes but is %v bytes",
+				block.CompressedSize, block.FileSize)
+		}
+
+		mpq.checkSectors(report, idx, block, filename)
+	}
+
+	sort.Slice(extents, func(i, j int) bool {
+		return extents[i].start < extents[j].start
+	})
+	for idx := 1; idx < len(extents); idx++ {
+		prev := extents[idx-1]
+		cur := extents[idx]
+		if cur.start < prev.end {
+			report.add(CheckError, CheckAreaBlockTable, cur.index,
+				blockNames[uint32(cur.index)],
+				"data overlaps block %v", prev.index)
+		}
+	}
+}
+
+func (mpq *Mpq) checkSectors(report *CheckReport, idx int, block *BlockEntry, filename string) {
+	if block.Flags&(FlagCompress|FlagImplode) == 0 || block.Flags&FlagSingleUnit != 0 {
+		return
+	}
+	if block.Flags&FlagEncrypted != 0 {
+		report.add(CheckWarning, CheckAreaSectorTable, idx, filename,
+			"can't verify the sector table of an encrypted file")
+		return
+	}
+
+	file := &File{
+		Filename:       filename,
+		CompressedSize: block.CompressedSize,
+		FileSize:       block.FileSize,
+	}
+	if len(file.Filename) == 0 {
+		file.Filename = fmt.Sprintf("block %v", idx)
+	}
+
+	position := int64(mpq.ArchiveOffset) + int64(block.FilePosition)
+	offsets, err := mpq.readSectorOffsets(file, position)
+	if err != nil {
+		report.add(CheckError, CheckAreaSectorTable, idx, filename, "%v", err)
+		return
+	}
+
+	tableSize := uint32(len(offsets)) * 4
+	if block.Flags&FlagSectorCrc != 0 {
+		tableSize += 4
+	}
+	if offsets[0] != tableSize {
+		report.add(CheckError, CheckAreaSectorTable, idx, filename,
+			"first sector starts at %v instead of right after the table at %v",
+			offsets[0], tableSize)
+	}
+}
+
+func (mpq *Mpq) checkListfile(report *CheckReport, blockNames map[uint32]string) {
+	report.KnownFiles = len(mpq.files)
+
+	data, err := mpq.ReadFile("(listfile)")
+	if err != nil {
+		report.add(CheckWarning, CheckAreaListfile, -1, "",
+			"unable to read the listfile: %v", err)
+	} else {
+		for _, filename := range strings.Split(string(data), "\r\n") {
+			if len(filename) == 0 {
+				continue
+			}
+			_, err := mpq.getHashEntry(filename)
+			if err != nil {
+				report.add(CheckWarning, CheckAreaListfile, -1, filename,
+					"listed file isn't in the archive")
+			}
+		}
+	}
+
+	for idx, block := range mpq.BlockEntries {
+		if block.Flags&FlagExists == 0 {
+			continue
+		}
+		if _, known := blockNames[uint32(idx)]; !known {
+			report.add(CheckWarning, CheckAreaListfile, idx, "",
+				"block isn't covered by the listfile")
+		}
+	}
+}
+
+// checkFiles reads every file with a known name, comparing the
+// contents with the checksums in (attributes) when it has them.
+func (mpq *Mpq) checkFiles(report *CheckReport, blockNames map[uint32]string) {
+	var attributes *Attributes
+	if _, found := mpq.files["(attributes)"]; found {
+		var err error
+		attributes, err = mpq.Attributes()
+		if err != nil {
+			report.add(CheckError, CheckAreaAttributes, -1, "(attributes)", "%v", err)
+			attributes = nil
+		}
+	}
+
+	indexes := []int{}
+	for idx := range blockNames {
+		indexes = append(indexes, int(idx))
+	}
+	sort.Ints(indexes)
+
+	for _, idx := range indexes {
+		filename := blockNames[uint32(idx)]
+		if idx >= len(mpq.BlockEntries) {
+			continue
+		}
+
+		data, err := mpq.ReadFile(filename)
+		if err != nil {
+			report.add(CheckError, CheckAreaBlockTable, idx, filename,
+				"unable to read file: %v", err)
+			continue
+		}
+		report.FilesChecked++
+		if attributes == nil {
+			continue
+		}
+
+		// Zero values weren't recorded, the (attributes)
+		// file itself is always one of them.
+		if attributes.Crc32 != nil && attributes.Crc32[idx] != 0 {
+			actual := crc32.ChecksumIEEE(data)
+			if actual != attributes.Crc32[idx] {
+				report.add(CheckError, CheckAreaAttributes, idx, filename,
+					"CRC32 is %08x but should be %08x", actual, attributes.Crc32[idx])
+			}
+		}
+		if attributes.Md5 != nil && attributes.Md5[idx] != [16]byte{} {
+			actual := md5.Sum(data)
+			if actual != attributes.Md5[idx] {
+				report.add(CheckError, CheckAreaAttributes, idx, filename,
+					"MD5 is %x but should be %x", actual, attributes.Md5[idx])
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+type CheckSuite struct{}
+
+var _ = Suite(&CheckSuite{})
+
+func findProblem(report *CheckReport, area string, index int) *CheckProblem {
+	for _, problem := range report.Problems {
+		if problem.Area == area && problem.Index == index {
+			return problem
+		}
+	}
+	return nil
+}
+
+func (s *CheckSuite) TestCheckValidArchive(c *C) {
+	mpq, err := NewMpqFromBytes(readTestReplay(c))
+	c.Assert(err, IsNil)
+
+	report := mpq.Check()
+	c.Check(report.Problems, HasLen, 0)
+	c.Check(report.Ok(), Equals, true)
+	c.Check(report.HashEntries, Equals, 10)
+	c.Check(report.BlockEntries, Equals, 10)
+	c.Check(report.KnownFiles, Equals, 10)
+	c.Check(report.FilesChecked, Equals, 10)
+}
+
+func (s *CheckSuite) TestCheckBadBlockIndex(c *C) {
+	mpq, err := NewMpqFromBytes(readTestReplay(c))
+	c.Assert(err, IsNil)
+
+	mpq.HashEntries[1].BlockIndex = 99
+
+	report := mpq.Check()
+	c.Check(report.Ok(), Equals, false)
+	problem := findProblem(report, CheckAreaHashTable, 1)
+	c.Assert(problem, NotNil)
+	c.Check(problem.Severity, Equals, CheckError)
+	c.Check(problem.Message, Equals, "block index 99 is out of range (10 blocks)")
+
+	// The block is now orphaned
+	c.Check(findProblem(report, CheckAreaListfile, 2), NotNil)
+}
+
+func (s *CheckSuite) TestCheckBlockExtents(c *C) {
+	mpq, err := NewMpqFromBytes(readTestReplay(c))
+	c.Assert(err, IsNil)
+
+	mpq.BlockEntries[3].FilePosition = mpq.BlockEntries[2].FilePosition + 16
+	mpq.BlockEntries[5].CompressedSize = 0x10000000
+
+	report := mpq.Check()
+	c.Check(report.Ok(), Equals, false)
+
+	problem := findProblem(report, CheckAreaBlockTable, 3)
+	c.Assert(problem, NotNil)
+	c.Check(problem.String(), Equals,
+		"error: block table #3 (replay.message.events): data overlaps block 2")
+
+	problem = findProblem(report, CheckAreaBlockTable, 5)
+	c.Assert(problem, NotNil)
+	c.Check(problem.Filename, Equals, "replay.sync.events")
+}
+
+func (s *CheckSuite) TestCheckAttributesChecksum(c *C) {
+	data := readTestReplay(c)
+	mpq, err := NewMpqFromBytes(data)
+	c.Assert(err, IsNil)
+
+	// replay.load.info is stored uncompressed
+	block := mpq.BlockEntries[4]
+	data[mpq.ArchiveOffset+block.FilePosition] ^= 0xFF
+
+	report := mpq.Check()
+	c.Check(report.Ok(), Equals, false)
+	c.Check(report.Problems, HasLen, 2)
+
+	problem := findProblem(report, CheckAreaAttributes, 4)
+	c.Assert(problem, NotNil)
+	c.Check(problem.Filename, Equals, "replay.load.info")
+}
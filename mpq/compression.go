@@ -0,0 +1,179 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compression masks as stored in the first byte of compressed
+// file data (or of each compressed sector).
+const (
+	CompressNone        byte = 0x00
+	CompressHuffman     byte = 0x01
+	CompressZlib        byte = 0x02
+	CompressPkware      byte = 0x08
+	CompressBzip2       byte = 0x10
+	CompressLzma        byte = 0x12
+	CompressSparse      byte = 0x20
+	CompressAdpcmMono   byte = 0x40
+	CompressAdpcmStereo byte = 0x80
+)
+
+var compressionNames = map[byte]string{
+	CompressNone:        "none",
+	CompressHuffman:     "huffman",
+	CompressZlib:        "zlib",
+	CompressPkware:      "pkware",
+	CompressBzip2:       "bzip2",
+	CompressLzma:        "lzma",
+	CompressSparse:      "sparse",
+	CompressAdpcmMono:   "adpcm mono",
+	CompressAdpcmStereo: "adpcm stereo",
+}
+
+// CompressionName returns a readable name for a compression mask.
+// Masks combining several compressions are shown in hex.
+func CompressionName(mask byte) string {
+	if name, found := compressionNames[mask]; found {
+		return name
+	}
+	return fmt.Sprintf("0x%02X", mask)
+}
+
+// A Decompressor wraps a reader positioned just after the compression
+// mask byte and returns a reader for the decompressed data.
+type Decompressor func(reader io.Reader) (io.Reader, error)
+
+// A Compressor returns data compressed with its compression, without
+// the compression mask byte.
+type Compressor func(data []byte) ([]byte, error)
+
+// UnsupportedCompressionError is returned when a file uses a compression
+// mask with no registered Decompressor.
+type UnsupportedCompressionError struct {
+	Filename string
+	Mask     byte
+}
+
+func (e *UnsupportedCompressionError) Error() string {
+	return fmt.Sprintf("unsupported compression 0x%02X in file %v",
+		e.Mask, e.Filename)
+}
+
+var decompressorsLock sync.RWMutex
+var decompressors = make(map[byte]Decompressor)
+
+var compressorsLock sync.RWMutex
+var compressors = make(map[byte]Compressor)
+
+func init() {
+	RegisterDecompressor(CompressNone, func(reader io.Reader) (io.Reader, error) {
+		return reader, nil
+	})
+	RegisterDecompressor(CompressZlib, func(reader io.Reader) (io.Reader, error) {
+		return zlib.NewReader(reader)
+	})
+	RegisterDecompressor(CompressBzip2, func(reader io.Reader) (io.Reader, error) {
+		return bzip2.NewReader(reader), nil
+	})
+
+	RegisterCompressor(CompressZlib, func(data []byte) ([]byte, error) {
+		buffer := new(bytes.Buffer)
+		writer := zlib.NewWriter(buffer)
+		_, err := writer.Write(data)
+		if err == nil {
+			err = writer.Close()
+		}
+		return buffer.Bytes(), err
+	})
+}
+
+// RegisterDecompressor makes a Decompressor available for the given
+// compression mask, replacing any Decompressor already registered for it.
+// Registering a nil Decompressor removes the mask from the registry.
+func RegisterDecompressor(mask byte, decompressor Decompressor) {
+	decompressorsLock.Lock()
+	defer decompressorsLock.Unlock()
+
+	if decompressor == nil {
+		delete(decompressors, mask)
+		return
+	}
+	decompressors[mask] = decompressor
+}
+
+// LookupDecompressor returns the Decompressor registered for the given
+// compression mask, if there is one.
+func LookupDecompressor(mask byte) (decompressor Decompressor, found bool) {
+	decompressorsLock.RLock()
+	defer decompressorsLock.RUnlock()
+
+	decompressor, found = decompressors[mask]
+	return
+}
+
+// RegisterCompressor makes a Compressor available for the given
+// compression mask when writing archives, replacing any Compressor
+// already registered for it.  Registering a nil Compressor removes the
+// mask from the registry.
+func RegisterCompressor(mask byte, compressor Compressor) {
+	compressorsLock.Lock()
+	defer compressorsLock.Unlock()
+
+	if compressor == nil {
+		delete(compressors, mask)
+		return
+	}
+	compressors[mask] = compressor
+}
+
+// LookupCompressor returns the Compressor registered for the given
+// compression mask, if there is one.
+func LookupCompressor(mask byte) (compressor Compressor, found bool) {
+	compressorsLock.RLock()
+	defer compressorsLock.RUnlock()
+
+	compressor, found = compressors[mask]
+	return
+}
+
+func decompress(filename string, mask byte, reader io.Reader) (result io.Reader, err error) {
+	decompressor, found := LookupDecompressor(mask)
+	if !found {
+		return nil, &UnsupportedCompressionError{Filename: filename, Mask: mask}
+	}
+
+	return decompressor(reader)
+}
@@ -0,0 +1,117 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type CompressionSuite struct{}
+
+var _ = Suite(&CompressionSuite{})
+
+func (s *CompressionSuite) TestRegisterDecompressor(c *C) {
+	RegisterDecompressor(0xF0, func(reader io.Reader) (io.Reader, error) {
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(bytes.ToUpper(data)), nil
+	})
+	defer RegisterDecompressor(0xF0, nil)
+
+	_, found := LookupDecompressor(0xF0)
+	c.Check(found, Equals, true)
+
+	reader, err := decompress("test", 0xF0, bytes.NewReader([]byte("zamara")))
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "ZAMARA")
+}
+
+func (s *CompressionSuite) TestUnregisterDecompressor(c *C) {
+	RegisterDecompressor(0xF0, func(reader io.Reader) (io.Reader, error) {
+		return reader, nil
+	})
+	RegisterDecompressor(0xF0, nil)
+
+	_, found := LookupDecompressor(0xF0)
+	c.Check(found, Equals, false)
+}
+
+func (s *CompressionSuite) TestUnsupportedCompression(c *C) {
+	_, err := decompress("replay.details", 0xEE, bytes.NewReader([]byte{}))
+	c.Assert(err, NotNil)
+	c.Check(err, FitsTypeOf, &UnsupportedCompressionError{})
+	c.Check(err.Error(), Equals,
+		"unsupported compression 0xEE in file replay.details")
+}
+
+func (s *CompressionSuite) TestUnsupportedCompressionInMpq(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpq(reader)
+	c.Assert(err, IsNil)
+
+	bzip2, _ := LookupDecompressor(CompressBzip2)
+	RegisterDecompressor(CompressBzip2, nil)
+	defer RegisterDecompressor(CompressBzip2, bzip2)
+
+	_, err = mpq.File("replay.initData")
+	c.Assert(err, NotNil)
+	c.Check(err.Error(), Equals,
+		"unsupported compression 0x10 in file replay.initData")
+}
+
+func (s *CompressionSuite) TestImplodedFile(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpq(reader)
+	c.Assert(err, IsNil)
+
+	// Imploded data has no mask byte and nothing to explode it with
+	file := mpq.Files()["replay.initData"]
+	c.Assert(file, NotNil)
+	file.Flags = file.Flags&^FlagCompress | FlagImplode
+
+	_, err = mpq.ReadFile("replay.initData")
+	c.Assert(err, NotNil)
+	c.Check(err, FitsTypeOf, &UnsupportedCompressionError{})
+	c.Check(err.Error(), Equals,
+		"unsupported compression 0x08 in file replay.initData")
+}
@@ -0,0 +1,295 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Where file metadata is kept in zip and tar archives.  Zip entries
+// get an extra field holding the language, platform and block flags as
+// little endian uint16, uint16 and uint32.  Tar entries get PAX records.
+const (
+	ZipExtraId     uint16 = 0x514D // "MQ"
+	PaxLanguageKey        = "ZAMARA.language"
+	PaxPlatformKey        = "ZAMARA.platform"
+	PaxFlagsKey           = "ZAMARA.flags"
+)
+
+// PackOptions controls how PackZip and PackTar create an MPQ.
+type PackOptions struct {
+	Compression byte // Compression for the files, CompressNone to store them
+}
+
+// sortedFiles returns the known files in the archive sorted by name.
+func (mpq *Mpq) sortedFiles() (files []*File) {
+	names := []string{}
+	for filename := range mpq.files {
+		names = append(names, filename)
+	}
+	sort.Strings(names)
+
+	for _, filename := range names {
+		files = append(files, mpq.files[filename])
+	}
+	return
+}
+
+// WriteZip writes every known file in the archive to a zip archive,
+// turning backslashes in names into directories.
+func (mpq *Mpq) WriteZip(writer io.Writer) (err error) {
+	zipWriter := zip.NewWriter(writer)
+
+	for _, file := range mpq.sortedFiles() {
+		data, err := mpq.ReadFile(file.Filename)
+		if err != nil {
+			return fmt.Errorf("Unable to read file %v: %v", file.Filename, err)
+		}
+
+		extra := make([]byte, 12)
+		binary.LittleEndian.PutUint16(extra[:2], ZipExtraId)
+		binary.LittleEndian.PutUint16(extra[0x02:0x02+2], 8)
+		binary.LittleEndian.PutUint16(extra[0x04:0x04+2], file.Language)
+		binary.LittleEndian.PutUint16(extra[0x06:0x06+2], file.Platform)
+		binary.LittleEndian.PutUint32(extra[0x08:0x08+4], file.Flags)
+
+		entry, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:   toArchivePath(file.Filename),
+			Method: zip.Deflate,
+			Extra:  extra,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = entry.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return zipWriter.Close()
+}
+
+// WriteTar writes every known file in the archive to a tar archive,
+// turning backslashes in names into directories.
+func (mpq *Mpq) WriteTar(writer io.Writer) (err error) {
+	tarWriter := tar.NewWriter(writer)
+
+	for _, file := range mpq.sortedFiles() {
+		data, err := mpq.ReadFile(file.Filename)
+		if err != nil {
+			return fmt.Errorf("Unable to read file %v: %v", file.Filename, err)
+		}
+
+		err = tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     toArchivePath(file.Filename),
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Format:   tar.FormatPAX,
+			PAXRecords: map[string]string{
+				PaxLanguageKey: strconv.Itoa(int(file.Language)),
+				PaxPlatformKey: strconv.Itoa(int(file.Platform)),
+				PaxFlagsKey:    fmt.Sprintf("0x%08x", file.Flags),
+			},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tarWriter.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tarWriter.Close()
+}
+
+// PackZip writes a new MPQ holding the files in a zip archive.  The
+// language and platform kept by WriteZip are restored, and a listfile
+// is written.  Any (listfile) or (attributes) in the zip is left out.
+func PackZip(reader *zip.Reader, writer io.Writer, options PackOptions) (err error) {
+	packer, err := newPacker(writer, options)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range reader.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		if entry.UncompressedSize64 > 0xFFFFFFFF {
+			return fmt.Errorf("File is too large for an MPQ: %v", entry.Name)
+		}
+
+		var language, platform uint16
+		extra := entry.Extra
+		for len(extra) >= 4 {
+			id := binary.LittleEndian.Uint16(extra[:2])
+			size := int(binary.LittleEndian.Uint16(extra[0x02 : 0x02+2]))
+			if 4+size > len(extra) {
+				break
+			}
+			if id == ZipExtraId && size == 8 {
+				language = binary.LittleEndian.Uint16(extra[0x04 : 0x04+2])
+				platform = binary.LittleEndian.Uint16(extra[0x06 : 0x06+2])
+			}
+			extra = extra[4+size:]
+		}
+
+		contents, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("Unable to open %v: %v", entry.Name, err)
+		}
+		data, err := ioutil.ReadAll(contents)
+		contents.Close()
+		if err != nil {
+			return fmt.Errorf("Unable to read %v: %v", entry.Name, err)
+		}
+
+		err = packer.add(entry.Name, data, language, platform)
+		if err != nil {
+			return err
+		}
+	}
+
+	return packer.close()
+}
+
+// PackTar writes a new MPQ holding the regular files in a tar archive.
+// The language and platform kept by WriteTar are restored, and a
+// listfile is written.  Any (listfile) or (attributes) in the tar is
+// left out.
+func PackTar(reader io.Reader, writer io.Writer, options PackOptions) (err error) {
+	packer, err := newPacker(writer, options)
+	if err != nil {
+		return err
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to read tar archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > 0xFFFFFFFF {
+			return fmt.Errorf("File is too large for an MPQ: %v", header.Name)
+		}
+
+		language, _ := strconv.ParseUint(header.PAXRecords[PaxLanguageKey], 10, 16)
+		platform, _ := strconv.ParseUint(header.PAXRecords[PaxPlatformKey], 10, 16)
+
+		data, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return fmt.Errorf("Unable to read %v: %v", header.Name, err)
+		}
+
+		err = packer.add(header.Name, data, uint16(language), uint16(platform))
+		if err != nil {
+			return err
+		}
+	}
+
+	return packer.close()
+}
+
+type packer struct {
+	writer *Writer
+	names  []string
+	listed map[string]bool
+}
+
+func newPacker(writer io.Writer, options PackOptions) (p *packer, err error) {
+	p = &packer{
+		writer: NewWriter(writer),
+		listed: make(map[string]bool),
+	}
+
+	err = p.writer.SetCompression(options.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *packer) add(name string, data []byte, language uint16, platform uint16) (err error) {
+	filename := fromArchivePath(name)
+	if len(filename) == 0 || filename == "(listfile)" || filename == "(attributes)" {
+		return nil
+	}
+
+	err = p.writer.AddFileLocale(filename, data, language, platform)
+	if err != nil {
+		return err
+	}
+
+	key := strings.ToUpper(filename)
+	if !p.listed[key] {
+		p.listed[key] = true
+		p.names = append(p.names, filename)
+	}
+
+	return nil
+}
+
+func (p *packer) close() (err error) {
+	listfile := strings.Join(p.names, "\r\n") + "\r\n"
+	err = p.writer.AddFile("(listfile)", []byte(listfile))
+	if err != nil {
+		return err
+	}
+
+	return p.writer.Close()
+}
+
+// toArchivePath turns an MPQ file name into a zip or tar path.
+func toArchivePath(filename string) string {
+	return strings.Replace(filename, "\\", "/", -1)
+}
+
+// fromArchivePath turns a zip or tar path into an MPQ file name.
+func fromArchivePath(name string) string {
+	name = strings.TrimLeft(strings.TrimPrefix(name, "./"), "/")
+	return strings.Replace(name, "/", "\\", -1)
+}
@@ -0,0 +1,168 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"archive/zip"
+	"bytes"
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type ConvertSuite struct{}
+
+var _ = Suite(&ConvertSuite{})
+
+func openTestReplay(c *C) *Mpq {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+
+	mpq, err := NewMpq(reader)
+	c.Assert(err, IsNil)
+	mpq.closer = reader
+
+	return mpq
+}
+
+// checkPacked checks that packed holds the same files as original,
+// apart from the ones MPQs maintain themselves.
+func checkPacked(c *C, original *Mpq, packed *Mpq) {
+	c.Check(packed.Files(), HasLen, len(original.Files())-1)
+	for filename := range original.Files() {
+		if filename == "(listfile)" || filename == "(attributes)" {
+			continue
+		}
+
+		expected, err := original.ReadFile(filename)
+		c.Assert(err, IsNil)
+		actual, err := packed.ReadFile(filename)
+		c.Assert(err, IsNil)
+		c.Check(actual, DeepEquals, expected, Commentf(filename))
+	}
+	c.Check(packed.Check().Ok(), Equals, true)
+}
+
+func (s *ConvertSuite) TestZipRoundTrip(c *C) {
+	original := openTestReplay(c)
+	defer original.Close()
+
+	zipped := new(bytes.Buffer)
+	c.Assert(original.WriteZip(zipped), IsNil)
+
+	reader, err := zip.NewReader(bytes.NewReader(zipped.Bytes()), int64(zipped.Len()))
+	c.Assert(err, IsNil)
+	c.Check(reader.File, HasLen, 10)
+	c.Check(reader.File[2].Name, Equals, "replay.attributes.events")
+
+	packed := new(bytes.Buffer)
+	c.Assert(PackZip(reader, packed, PackOptions{Compression: CompressZlib}), IsNil)
+
+	archive, err := NewMpqFromBytes(packed.Bytes())
+	c.Assert(err, IsNil)
+	checkPacked(c, original, archive)
+
+	stats := archive.Stats()
+	c.Check(stats.Codecs[CompressZlib] > 0, Equals, true)
+}
+
+func (s *ConvertSuite) TestTarRoundTrip(c *C) {
+	original := openTestReplay(c)
+	defer original.Close()
+
+	tarred := new(bytes.Buffer)
+	c.Assert(original.WriteTar(tarred), IsNil)
+
+	packed := new(bytes.Buffer)
+	c.Assert(PackTar(tarred, packed, PackOptions{}), IsNil)
+
+	archive, err := NewMpqFromBytes(packed.Bytes())
+	c.Assert(err, IsNil)
+	checkPacked(c, original, archive)
+}
+
+func (s *ConvertSuite) TestConvertKeepsPathsAndLocales(c *C) {
+	buffer := new(bytes.Buffer)
+	w := NewWriter(buffer)
+	c.Assert(w.AddFileLocale("Dir\\Sub\\file.txt", []byte("text"), 0x409, 0), IsNil)
+	c.Assert(w.AddFile("(listfile)", []byte("Dir\\Sub\\file.txt\r\n")), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	original, err := NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+
+	zipped := new(bytes.Buffer)
+	c.Assert(original.WriteZip(zipped), IsNil)
+	reader, err := zip.NewReader(bytes.NewReader(zipped.Bytes()), int64(zipped.Len()))
+	c.Assert(err, IsNil)
+	c.Check(reader.File[1].Name, Equals, "Dir/Sub/file.txt")
+
+	tarred := new(bytes.Buffer)
+	c.Assert(original.WriteTar(tarred), IsNil)
+
+	packed := new(bytes.Buffer)
+	c.Assert(PackZip(reader, packed, PackOptions{}), IsNil)
+	fromZip, err := NewMpqFromBytes(packed.Bytes())
+	c.Assert(err, IsNil)
+
+	packed = new(bytes.Buffer)
+	c.Assert(PackTar(tarred, packed, PackOptions{}), IsNil)
+	fromTar, err := NewMpqFromBytes(packed.Bytes())
+	c.Assert(err, IsNil)
+
+	for _, archive := range []*Mpq{fromZip, fromTar} {
+		file, found := archive.Files()["Dir\\Sub\\file.txt"]
+		c.Assert(found, Equals, true)
+		c.Check(file.Language, Equals, uint16(0x409))
+	}
+}
+
+func (s *ConvertSuite) TestWriterCompression(c *C) {
+	buffer := new(bytes.Buffer)
+	w := NewWriter(buffer)
+	c.Check(w.SetCompression(0x03), NotNil)
+	c.Assert(w.SetCompression(CompressZlib), IsNil)
+
+	data := bytes.Repeat([]byte("compress me "), 100)
+	c.Assert(w.AddFile("big", data), IsNil)
+	c.Assert(w.AddFile("small", []byte("x")), IsNil)
+	c.Assert(w.AddFile("(listfile)", []byte("big\r\nsmall\r\n")), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	archive, err := NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+
+	big := archive.Files()["big"]
+	c.Check(big.Flags&FlagCompress, Equals, FlagCompress)
+	c.Check(big.CompressedSize < big.FileSize, Equals, true)
+	c.Check(archive.Files()["small"].Flags&FlagCompress, Equals, uint32(0))
+
+	read, err := archive.ReadFile("big")
+	c.Assert(err, IsNil)
+	c.Check(read, DeepEquals, data)
+}
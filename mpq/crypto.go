@@ -0,0 +1,126 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// HashType selects which of the hashes used by MPQ archives
+// HashString computes.
+type HashType uint16
+
+const (
+	HashTypeTableOffset HashType = 0x000 // Starting slot in the hash table
+	HashTypeNameA       HashType = 0x100 // First name check in a hash entry
+	HashTypeNameB       HashType = 0x200 // Second name check in a hash entry
+	HashTypeFileKey     HashType = 0x300 // Encryption keys
+)
+
+// Keys the hash and block tables are encrypted with, the
+// HashTypeFileKey hashes of "(hash table)" and "(block table)"
+const (
+	HashTableKey  uint32 = 0xC3AF3770
+	BlockTableKey uint32 = 0xEC83B3A3
+)
+
+// HashString hashes a file name or key.  Names are case insensitive
+// and '/' is treated the same as '\'.
+func HashString(input string, hashType HashType) uint32 {
+	return hashString(input, uint16(hashType))
+}
+
+// HashTableIndex returns the slot in a hash table with the given
+// number of entries where a lookup for filename starts.  The number
+// of entries must be a power of two.
+func HashTableIndex(filename string, entries uint32) uint32 {
+	return HashString(filename, HashTypeTableOffset) & (entries - 1)
+}
+
+// FileKey returns the key used to encrypt a file.  It's derived from
+// the file's name without its path and, when FlagFixKey is set, is
+// adjusted by the block's position in the archive and the file size.
+func FileKey(filename string, filePosition uint32, fileSize uint32, flags uint32) uint32 {
+	name := filename
+	if idx := strings.LastIndexAny(name, "\\/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+
+	key := HashString(name, HashTypeFileKey)
+	if flags&FlagFixKey != 0 {
+		key = (key + filePosition) ^ fileSize
+	}
+
+	return key
+}
+
+// Encrypt encrypts data in place with key.  Any bytes after the last
+// whole 4 byte word are left as they are.
+func Encrypt(data []byte, key uint32) {
+	seed1 := key
+	var seed2 uint32 = 0xEEEEEEEE
+
+	for pos := 0; pos+4 <= len(data); pos += 4 {
+		seed2 += blockEncryptionTable[0x400+(seed1&0xFF)]
+		plain := binary.LittleEndian.Uint32(data[pos : pos+4])
+		encrypted := plain ^ (seed1 + seed2)
+		seed1 = ((^seed1 << 0x15) + 0x11111111) | (seed1 >> 0x0B)
+		seed2 = plain + seed2 + (seed2 << 5) + 3
+
+		binary.LittleEndian.PutUint32(data[pos:pos+4], encrypted)
+	}
+}
+
+// Decrypt decrypts data in place with key.  Any bytes after the last
+// whole 4 byte word are left as they are.
+func Decrypt(data []byte, key uint32) {
+	seed1 := key
+	var seed2 uint32 = 0xEEEEEEEE
+
+	for pos := 0; pos+4 <= len(data); pos += 4 {
+		seed2 += blockEncryptionTable[0x400+(seed1&0xFF)]
+		encrypted := binary.LittleEndian.Uint32(data[pos : pos+4])
+		plain := encrypted ^ (seed1 + seed2)
+		seed1 = ((^seed1 << 0x15) + 0x11111111) | (seed1 >> 0x0B)
+		seed2 = plain + seed2 + (seed2 << 5) + 3
+
+		binary.LittleEndian.PutUint32(data[pos:pos+4], plain)
+	}
+}
+
+// FileKey returns the key used to encrypt a file in the archive.
+func (mpq *Mpq) FileKey(filename string) (key uint32, err error) {
+	file, err := mpq.lookupFile(filename)
+	if err != nil {
+		return 0, err
+	}
+
+	return FileKey(filename, file.block.FilePosition, file.FileSize, file.Flags), nil
+}
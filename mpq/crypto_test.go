@@ -0,0 +1,72 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+type CryptoSuite struct{}
+
+var _ = Suite(&CryptoSuite{})
+
+func (s *CryptoSuite) TestHashString(c *C) {
+	c.Check(HashString("(listfile)", HashTypeNameA), Equals, uint32(0xFD657910))
+	c.Check(HashString("(listfile)", HashTypeNameB), Equals, uint32(0x4E9B98A7))
+	c.Check(HashString("(LISTFILE)", HashTypeNameA), Equals, uint32(0xFD657910))
+	c.Check(HashString("(hash table)", HashTypeFileKey), Equals, HashTableKey)
+	c.Check(HashString("(block table)", HashTypeFileKey), Equals, BlockTableKey)
+
+	c.Check(HashString("Dir/File.txt", HashTypeNameA), Equals,
+		HashString("dir\\file.txt", HashTypeNameA))
+}
+
+func (s *CryptoSuite) TestHashTableIndex(c *C) {
+	index := HashTableIndex("(listfile)", 16)
+	c.Check(index, Equals, HashString("(listfile)", HashTypeTableOffset)&15)
+}
+
+func (s *CryptoSuite) TestFileKey(c *C) {
+	key := HashString("file.txt", HashTypeFileKey)
+	c.Check(FileKey("Dir\\file.txt", 0x400, 100, FlagEncrypted), Equals, key)
+	c.Check(FileKey("file.txt", 0x400, 100, FlagEncrypted|FlagFixKey), Equals,
+		(key+0x400)^100)
+}
+
+func (s *CryptoSuite) TestEncryptDecrypt(c *C) {
+	plain := []byte("twelve bytes and 3")
+	data := append([]byte{}, plain...)
+
+	Encrypt(data, 0x12345678)
+	c.Check(data, Not(DeepEquals), plain)
+	c.Check(data[16:], DeepEquals, plain[16:])
+
+	Decrypt(data, 0x12345678)
+	c.Check(data, DeepEquals, plain)
+}
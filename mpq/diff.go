@@ -0,0 +1,406 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type DiffKind int
+
+const (
+	DiffAdded DiffKind = iota
+	DiffRemoved
+	DiffChanged
+)
+
+func (kind DiffKind) String() string {
+	switch kind {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	}
+	return "changed"
+}
+
+// DiffOptions controls what Diff compares.
+type DiffOptions struct {
+	// Content includes a line by line diff of changed files that
+	// look like text in both archives.
+	Content bool
+
+	// MaxContentLines skips the line diff when either version of a
+	// file has more lines than this.  Zero uses 5000.
+	MaxContentLines int
+}
+
+// FieldChange is a single value that differs between two archives.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+func (change *FieldChange) String() string {
+	return fmt.Sprintf("%v: %v -> %v", change.Field, change.Old, change.New)
+}
+
+// DiffLine is one line of a content diff.  Op is ' ' for lines in
+// both versions, '-' for removed lines and '+' for added lines.  Line
+// numbers start at 1 and are 0 for the version a line isn't in.
+type DiffLine struct {
+	Op      byte
+	OldLine int
+	NewLine int
+	Text    string
+}
+
+// FileDiff is a file that was added, removed or changed.
+type FileDiff struct {
+	Filename string
+	Kind     DiffKind
+
+	Old *File // Nil for added files
+	New *File // Nil for removed files
+
+	Changes []*FieldChange
+
+	// Line diff of the contents when DiffOptions.Content was set and
+	// the file is text, nil otherwise.
+	Lines []*DiffLine
+}
+
+// Diff is the difference between two archives.  Only files with
+// known names are compared.
+type Diff struct {
+	Header []*FieldChange
+	Files  []*FileDiff
+}
+
+// Empty reports whether the archives have no differences.
+func (diff *Diff) Empty() bool {
+	return len(diff.Header) == 0 && len(diff.Files) == 0
+}
+
+// DiffMpq compares two archives.  Files are compared by size, flags,
+// locale and the CRC32 and MD5 of their contents.
+func DiffMpq(from *Mpq, to *Mpq, options DiffOptions) (diff *Diff, err error) {
+	diff = new(Diff)
+	diff.Header = diffHeaders(from, to)
+
+	names := []string{}
+	for filename := range from.files {
+		names = append(names, filename)
+	}
+	for filename := range to.files {
+		if _, found := from.files[filename]; !found {
+			names = append(names, filename)
+		}
+	}
+	sort.Strings(names)
+
+	for _, filename := range names {
+		oldFile, inOld := from.files[filename]
+		newFile, inNew := to.files[filename]
+
+		switch {
+		case !inOld:
+			diff.Files = append(diff.Files,
+				&FileDiff{Filename: filename, Kind: DiffAdded, New: newFile})
+		case !inNew:
+			diff.Files = append(diff.Files,
+				&FileDiff{Filename: filename, Kind: DiffRemoved, Old: oldFile})
+		default:
+			fileDiff, err := diffFiles(from, to, oldFile, newFile, options)
+			if err != nil {
+				return nil, err
+			}
+			if fileDiff != nil {
+				diff.Files = append(diff.Files, fileDiff)
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+type fieldChanges []*FieldChange
+
+func (changes *fieldChanges) compare(field string, from interface{}, to interface{}) {
+	oldValue := fmt.Sprintf("%v", from)
+	newValue := fmt.Sprintf("%v", to)
+	if oldValue != newValue {
+		*changes = append(*changes, &FieldChange{field, oldValue, newValue})
+	}
+}
+
+func diffHeaders(from *Mpq, to *Mpq) []*FieldChange {
+	changes := fieldChanges{}
+
+	changes.compare("archive offset", from.ArchiveOffset, to.ArchiveOffset)
+	changes.compare("header size", from.Header.HeaderSize, to.Header.HeaderSize)
+	changes.compare("archive size", from.Header.ArchiveSize, to.Header.ArchiveSize)
+	changes.compare("format version", from.Header.FormatVersion, to.Header.FormatVersion)
+	changes.compare("block size", from.Header.BlockSize, to.Header.BlockSize)
+	changes.compare("hash table offset", from.Header.HashTableOffset, to.Header.HashTableOffset)
+	changes.compare("block table offset", from.Header.BlockTableOffset, to.Header.BlockTableOffset)
+	changes.compare("hash table entries", from.Header.HashTableEntries, to.Header.HashTableEntries)
+	changes.compare("block table entries", from.Header.BlockTableEntries, to.Header.BlockTableEntries)
+	changes.compare("extended block table offset",
+		from.Header.ExtendedBlockTableOffset, to.Header.ExtendedBlockTableOffset)
+	changes.compare("hash table offset high",
+		from.Header.HashTableOffsetHigh, to.Header.HashTableOffsetHigh)
+	changes.compare("block table offset high",
+		from.Header.BlockTableOffsetHigh, to.Header.BlockTableOffsetHigh)
+
+	changes.compare("user data", from.HasUserData, to.HasUserData)
+	if from.HasUserData && to.HasUserData {
+		changes.compare("max user data size",
+			from.UserData.Header.MaxUserDataSize, to.UserData.Header.MaxUserDataSize)
+		changes.compare("user data size",
+			from.UserData.Header.UserDataSize, to.UserData.Header.UserDataSize)
+	}
+
+	return changes
+}
+
+func diffFiles(from *Mpq, to *Mpq, oldFile *File, newFile *File,
+	options DiffOptions) (diff *FileDiff, err error) {
+	changes := fieldChanges{}
+	changes.compare("file size", oldFile.FileSize, newFile.FileSize)
+	changes.compare("compressed size", oldFile.CompressedSize, newFile.CompressedSize)
+	changes.compare("flags", fmt.Sprintf("0x%08x", oldFile.Flags),
+		fmt.Sprintf("0x%08x", newFile.Flags))
+	changes.compare("language", oldFile.Language, newFile.Language)
+	changes.compare("platform", oldFile.Platform, newFile.Platform)
+
+	oldData, err := from.ReadFile(oldFile.Filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %v from the first archive: %v",
+			oldFile.Filename, err)
+	}
+	newData, err := to.ReadFile(newFile.Filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %v from the second archive: %v",
+			newFile.Filename, err)
+	}
+
+	changes.compare("crc32", fmt.Sprintf("%08x", crc32.ChecksumIEEE(oldData)),
+		fmt.Sprintf("%08x", crc32.ChecksumIEEE(newData)))
+	changes.compare("md5", fmt.Sprintf("%x", md5.Sum(oldData)),
+		fmt.Sprintf("%x", md5.Sum(newData)))
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	diff = &FileDiff{
+		Filename: oldFile.Filename,
+		Kind:     DiffChanged,
+		Old:      oldFile,
+		New:      newFile,
+		Changes:  changes,
+	}
+
+	if options.Content && !bytes.Equal(oldData, newData) &&
+		isText(oldData) && isText(newData) {
+		maxLines := options.MaxContentLines
+		if maxLines == 0 {
+			maxLines = 5000
+		}
+		diff.Lines = diffLines(splitLines(oldData), splitLines(newData), maxLines)
+	}
+
+	return diff, nil
+}
+
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+func splitLines(data []byte) []string {
+	text := strings.Replace(string(data), "\r\n", "\n", -1)
+	text = strings.TrimSuffix(text, "\n")
+	if len(text) == 0 {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines returns the longest common subsequence diff of two sets of
+// lines, or nil if either has more than maxLines lines.  It uses
+// Hirschberg's algorithm, so memory grows with the number of lines
+// rather than its square.
+func diffLines(from []string, to []string, maxLines int) (lines []*DiffLine) {
+	if len(from) > maxLines || len(to) > maxLines {
+		return nil
+	}
+
+	diff := &lineDiff{from: from, to: to, lines: []*DiffLine{}}
+	diff.diffRange(0, len(from), 0, len(to))
+	return diff.lines
+}
+
+type lineDiff struct {
+	from  []string
+	to    []string
+	lines []*DiffLine
+}
+
+func (diff *lineDiff) same(i int, j int) {
+	diff.lines = append(diff.lines, &DiffLine{' ', i + 1, j + 1, diff.from[i]})
+}
+
+func (diff *lineDiff) removed(i int) {
+	diff.lines = append(diff.lines, &DiffLine{'-', i + 1, 0, diff.from[i]})
+}
+
+func (diff *lineDiff) added(j int) {
+	diff.lines = append(diff.lines, &DiffLine{'+', 0, j + 1, diff.to[j]})
+}
+
+// diffRange appends the diff of from[i0:i1] and to[j0:j1].
+func (diff *lineDiff) diffRange(i0 int, i1 int, j0 int, j1 int) {
+	for i0 < i1 && j0 < j1 && diff.from[i0] == diff.to[j0] {
+		diff.same(i0, j0)
+		i0++
+		j0++
+	}
+	suffix := 0
+	for i0 < i1-suffix && j0 < j1-suffix &&
+		diff.from[i1-suffix-1] == diff.to[j1-suffix-1] {
+		suffix++
+	}
+	i1 -= suffix
+	j1 -= suffix
+
+	switch {
+	case i0 == i1:
+		for j := j0; j < j1; j++ {
+			diff.added(j)
+		}
+	case j0 == j1:
+		for i := i0; i < i1; i++ {
+			diff.removed(i)
+		}
+	case i1-i0 == 1:
+		// One line left, which is either in the rest of to or removed
+		match := -1
+		for j := j0; j < j1; j++ {
+			if diff.from[i0] == diff.to[j] {
+				match = j
+				break
+			}
+		}
+		if match < 0 {
+			diff.removed(i0)
+			match = j0 - 1
+		}
+		for j := j0; j < j1; j++ {
+			if j == match {
+				diff.same(i0, j)
+			} else {
+				diff.added(j)
+			}
+		}
+	default:
+		// Split from in half and to where the common subsequences of
+		// the halves are longest together
+		middle := (i0 + i1) / 2
+		forward := diff.forwardLengths(i0, middle, j0, j1)
+		backward := diff.backwardLengths(middle, i1, j0, j1)
+		split := j0
+		best := -1
+		for j := j0; j <= j1; j++ {
+			if length := forward[j-j0] + backward[j-j0]; length > best {
+				best = length
+				split = j
+			}
+		}
+		diff.diffRange(i0, middle, j0, split)
+		diff.diffRange(middle, i1, split, j1)
+	}
+
+	for ; suffix > 0; suffix-- {
+		diff.same(i1, j1)
+		i1++
+		j1++
+	}
+}
+
+// forwardLengths returns the length of the longest common subsequence
+// of from[i0:i1] and to[j0:j] for each j from j0 to j1.
+func (diff *lineDiff) forwardLengths(i0 int, i1 int, j0 int, j1 int) []int {
+	row := make([]int, j1-j0+1)
+	previous := make([]int, j1-j0+1)
+	for i := i0; i < i1; i++ {
+		row, previous = previous, row
+		row[0] = 0
+		for k := 1; k <= j1-j0; k++ {
+			if diff.from[i] == diff.to[j0+k-1] {
+				row[k] = previous[k-1] + 1
+			} else if row[k-1] > previous[k] {
+				row[k] = row[k-1]
+			} else {
+				row[k] = previous[k]
+			}
+		}
+	}
+
+	return row
+}
+
+// backwardLengths returns the length of the longest common subsequence
+// of from[i0:i1] and to[j:j1] for each j from j0 to j1.
+func (diff *lineDiff) backwardLengths(i0 int, i1 int, j0 int, j1 int) []int {
+	row := make([]int, j1-j0+1)
+	previous := make([]int, j1-j0+1)
+	for i := i1 - 1; i >= i0; i-- {
+		row, previous = previous, row
+		row[j1-j0] = 0
+		for k := j1 - j0 - 1; k >= 0; k-- {
+			if diff.from[i] == diff.to[j0+k] {
+				row[k] = previous[k+1] + 1
+			} else if row[k+1] > previous[k] {
+				row[k] = row[k+1]
+			} else {
+				row[k] = previous[k]
+			}
+		}
+	}
+
+	return row
+}
@@ -0,0 +1,177 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	"fmt"
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type DiffSuite struct{}
+
+var _ = Suite(&DiffSuite{})
+
+func writeTestArchive(c *C, files map[string]string) *Mpq {
+	buffer := new(bytes.Buffer)
+	w := NewWriter(buffer)
+	listfile := ""
+	for filename, contents := range files {
+		c.Assert(w.AddFile(filename, []byte(contents)), IsNil)
+		listfile += filename + "\r\n"
+	}
+	c.Assert(w.AddFile("(listfile)", []byte(listfile)), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	mpq, err := NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+	return mpq
+}
+
+func (s *DiffSuite) TestDiffSameArchive(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpq(reader)
+	c.Assert(err, IsNil)
+
+	diff, err := DiffMpq(mpq, mpq, DiffOptions{Content: true})
+	c.Assert(err, IsNil)
+	c.Check(diff.Empty(), Equals, true)
+}
+
+func (s *DiffSuite) TestDiffFiles(c *C) {
+	from := writeTestArchive(c, map[string]string{
+		"removed.txt":   "gone",
+		"same.txt":      "same",
+		"script.galaxy": "one\r\ntwo\r\nthree\r\n",
+	})
+	to := writeTestArchive(c, map[string]string{
+		"added.txt":     "new",
+		"same.txt":      "same",
+		"script.galaxy": "one\r\n2\r\nthree\r\n",
+	})
+
+	diff, err := DiffMpq(from, to, DiffOptions{Content: true})
+	c.Assert(err, IsNil)
+	c.Assert(len(diff.Header) > 0, Equals, true)
+	c.Check(diff.Header[0].Field, Equals, "archive size")
+
+	// (listfile) changed along with the files it names
+	c.Assert(diff.Files, HasLen, 4)
+	c.Check(diff.Files[0].Filename, Equals, "(listfile)")
+	c.Check(diff.Files[1].Filename, Equals, "added.txt")
+	c.Check(diff.Files[1].Kind, Equals, DiffAdded)
+	c.Check(diff.Files[2].Filename, Equals, "removed.txt")
+	c.Check(diff.Files[2].Kind, Equals, DiffRemoved)
+
+	script := diff.Files[3]
+	c.Check(script.Filename, Equals, "script.galaxy")
+	c.Check(script.Kind, Equals, DiffChanged)
+	fields := []string{}
+	for _, change := range script.Changes {
+		fields = append(fields, change.Field)
+	}
+	c.Check(fields, DeepEquals, []string{"file size", "compressed size", "crc32", "md5"})
+	c.Check(script.Lines, DeepEquals, []*DiffLine{
+		{' ', 1, 1, "one"},
+		{'-', 2, 0, "two"},
+		{'+', 0, 2, "2"},
+		{' ', 3, 3, "three"},
+	})
+}
+
+func (s *DiffSuite) TestDiffSkipsBinaryContent(c *C) {
+	from := writeTestArchive(c, map[string]string{"data": "a\x00b"})
+	to := writeTestArchive(c, map[string]string{"data": "a\x00c"})
+
+	diff, err := DiffMpq(from, to, DiffOptions{Content: true})
+	c.Assert(err, IsNil)
+	c.Assert(diff.Files, HasLen, 1)
+	c.Check(diff.Files[0].Lines, IsNil)
+}
+
+// checkScript checks that a line diff turns from into to and keeps
+// common lines in both.
+func checkScript(c *C, from []string, to []string, lines []*DiffLine, common int) {
+	oldLines, newLines := []string{}, []string{}
+	same := 0
+	for _, line := range lines {
+		if line.Op != '+' {
+			c.Check(line.OldLine, Equals, len(oldLines)+1)
+			oldLines = append(oldLines, line.Text)
+		}
+		if line.Op != '-' {
+			c.Check(line.NewLine, Equals, len(newLines)+1)
+			newLines = append(newLines, line.Text)
+		}
+		if line.Op == ' ' {
+			same++
+		}
+	}
+	c.Check(oldLines, DeepEquals, from)
+	c.Check(newLines, DeepEquals, to)
+	c.Check(same, Equals, common)
+}
+
+func (s *DiffSuite) TestDiffLines(c *C) {
+	from := []string{"a", "b", "c", "a", "b", "b", "a"}
+	to := []string{"c", "b", "a", "b", "a", "c"}
+	checkScript(c, from, to, diffLines(from, to, 100), 4)
+	checkScript(c, to, from, diffLines(to, from, 100), 4)
+	checkScript(c, from, []string{}, diffLines(from, []string{}, 100), 0)
+	c.Check(diffLines(from, to, 6), IsNil)
+
+	// Long files only need memory for a few rows at a time
+	from, to = []string{}, []string{}
+	for i := 0; i < 5000; i++ {
+		from = append(from, fmt.Sprint(i))
+		if i%100 != 0 {
+			to = append(to, fmt.Sprint(i))
+		}
+		if i%250 == 0 {
+			to = append(to, "new")
+		}
+	}
+	checkScript(c, from, to, diffLines(from, to, 5000), 4950)
+}
+
+func (s *DiffSuite) TestDiffFlags(c *C) {
+	from := writeTestArchive(c, map[string]string{"data": "same"})
+	to := writeTestArchive(c, map[string]string{"data": "same"})
+	to.Files()["data"].Flags = FlagExists | FlagSingleUnit
+
+	diff, err := DiffMpq(from, to, DiffOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(diff.Files, HasLen, 1)
+	c.Assert(diff.Files[0].Changes, HasLen, 1)
+	c.Check(diff.Files[0].Changes[0].String(), Equals, "flags: 0x80000000 -> 0x81000000")
+}
@@ -0,0 +1,202 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractOptions selects which files Extract writes and how.
+type ExtractOptions struct {
+	// Include limits extraction to files matching at least one of these
+	// patterns.  All files are included when it's empty.
+	Include []string
+	// Exclude skips files matching any of these patterns, even if they
+	// were included.
+	Exclude []string
+	// Locales limits extraction to files with one of these language
+	// IDs.  All locales are included when it's empty.
+	Locales []uint16
+	// Overwrite replaces files that already exist in the output
+	// directory instead of skipping them.
+	Overwrite bool
+	// Progress is called after each selected file is handled.
+	Progress func(result *ExtractResult, done int, total int)
+}
+
+// ExtractResult records what happened to one file during Extract.
+type ExtractResult struct {
+	Filename string
+	Path     string // Where the file was or would have been written
+	Skipped  bool   // The file already existed and Overwrite wasn't set
+	Err      error
+}
+
+// MatchPattern reports whether an archive file name matches a glob
+// pattern.  Both backslashes and forward slashes separate directories
+// and matching ignores case like MPQ name lookups do.  A pattern without
+// a separator is matched against the base name of the file, otherwise
+// it's matched against the whole path and also selects everything
+// below a matching directory.
+func MatchPattern(pattern string, filename string) (matched bool, err error) {
+	pattern = strings.ToLower(toArchivePath(pattern))
+	filename = strings.ToLower(toArchivePath(filename))
+
+	if !strings.Contains(pattern, "/") {
+		return path.Match(pattern, path.Base(filename))
+	}
+
+	pattern = strings.TrimSuffix(pattern, "/")
+	for name := filename; ; name = path.Dir(name) {
+		matched, err = path.Match(pattern, name)
+		if matched || err != nil || !strings.Contains(name, "/") {
+			return
+		}
+	}
+}
+
+// ExtractPath returns where an archive file is written below dir.  File
+// names that are absolute or would escape dir are rejected.
+func ExtractPath(dir string, filename string) (extractPath string, err error) {
+	name := toArchivePath(filename)
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, ":") {
+		return "", fmt.Errorf("Unsafe file name in archive: %v", filename)
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("Unsafe file name in archive: %v", filename)
+		}
+	}
+
+	name = path.Clean(name)
+	if name == "." {
+		return "", fmt.Errorf("Unsafe file name in archive: %v", filename)
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(name)), nil
+}
+
+// Extract writes the selected files to dir, recreating the directory
+// layout stored in their names.  Files that can't be read or written
+// are recorded in their result and the rest are still extracted, err is
+// only set if nothing could be attempted.
+func (mpq *Mpq) Extract(dir string, options ExtractOptions) (results []*ExtractResult, err error) {
+	for _, pattern := range append(options.Include, options.Exclude...) {
+		_, err = path.Match(pattern, "")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern %v: %v", pattern, err)
+		}
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create output directory: %v", err)
+	}
+
+	files := []*File{}
+	for _, file := range mpq.sortedFiles() {
+		if options.selects(file) {
+			files = append(files, file)
+		}
+	}
+
+	for idx, file := range files {
+		result := &ExtractResult{Filename: file.Filename}
+		result.Path, result.Err = ExtractPath(dir, file.Filename)
+		if result.Err == nil {
+			result.Skipped, result.Err = mpq.extractFile(file, result.Path, options.Overwrite)
+		}
+
+		results = append(results, result)
+		if options.Progress != nil {
+			options.Progress(result, idx+1, len(files))
+		}
+	}
+
+	return results, nil
+}
+
+func (mpq *Mpq) extractFile(file *File, extractPath string, overwrite bool) (skipped bool, err error) {
+	if !overwrite {
+		_, err = os.Lstat(extractPath)
+		if err == nil {
+			return true, nil
+		}
+	}
+
+	data, err := mpq.ReadFile(file.Filename)
+	if err != nil {
+		return false, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(extractPath), 0755)
+	if err != nil {
+		return false, err
+	}
+
+	return false, ioutil.WriteFile(extractPath, data, 0644)
+}
+
+func (options *ExtractOptions) selects(file *File) bool {
+	if len(options.Locales) > 0 {
+		found := false
+		for _, locale := range options.Locales {
+			if file.Language == locale {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(options.Include) > 0 && !matchAny(options.Include, file.Filename) {
+		return false
+	}
+
+	return !matchAny(options.Exclude, file.Filename)
+}
+
+func matchAny(patterns []string, filename string) bool {
+	for _, pattern := range patterns {
+		matched, _ := MatchPattern(pattern, filename)
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,169 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"os"
+	"path/filepath"
+)
+
+type ExtractSuite struct{}
+
+var _ = Suite(&ExtractSuite{})
+
+func writeExtractArchive(c *C) *Mpq {
+	buffer := new(bytes.Buffer)
+	w := NewWriter(buffer)
+	c.Assert(w.AddFile("base.txt", []byte("base")), IsNil)
+	c.Assert(w.AddFile("Data\\Units\\marine.xml", []byte("marine")), IsNil)
+	c.Assert(w.AddFile("Data\\Units\\zealot.txt", []byte("zealot")), IsNil)
+	c.Assert(w.AddFileLocale("Data\\strings.txt", []byte("deDE"), 0x407, 0), IsNil)
+	c.Assert(w.AddFile("..\\escape.txt", []byte("escape")), IsNil)
+	c.Assert(w.AddFile("(listfile)", []byte("base.txt\r\nData\\Units\\marine.xml\r\n"+
+		"Data\\Units\\zealot.txt\r\nData\\strings.txt\r\n..\\escape.txt\r\n")), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	mpq, err := NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+	return mpq
+}
+
+func (s *ExtractSuite) TestMatchPattern(c *C) {
+	tests := []struct {
+		pattern  string
+		filename string
+		matched  bool
+	}{
+		{"*.xml", "Data\\Units\\marine.xml", true},
+		{"*.XML", "data\\units\\marine.xml", true},
+		{"*.txt", "Data\\Units\\marine.xml", false},
+		{"Data/Units", "Data\\Units\\marine.xml", true},
+		{"data\\*", "Data\\Units\\marine.xml", true},
+		{"Data/*.xml", "Data\\Units\\marine.xml", false},
+		{"Data/*/*.xml", "Data\\Units\\marine.xml", true},
+		{"(listfile)", "(listfile)", true},
+		{"(*)", "Data\\(attributes)", true},
+	}
+
+	for _, test := range tests {
+		matched, err := MatchPattern(test.pattern, test.filename)
+		c.Check(err, IsNil)
+		c.Check(matched, Equals, test.matched, Commentf("%v %v", test.pattern, test.filename))
+	}
+}
+
+func (s *ExtractSuite) TestExtractPath(c *C) {
+	path, err := ExtractPath("out", "Data\\Units\\marine.xml")
+	c.Check(err, IsNil)
+	c.Check(path, Equals, filepath.Join("out", "Data", "Units", "marine.xml"))
+
+	for _, filename := range []string{"..\\escape.txt", "a\\..\\..\\b", "\\root.txt", "C:\\drive.txt", ""} {
+		_, err = ExtractPath("out", filename)
+		c.Check(err, NotNil, Commentf(filename))
+	}
+}
+
+func (s *ExtractSuite) TestExtractLayout(c *C) {
+	mpq := writeExtractArchive(c)
+	dir := c.MkDir()
+
+	done := 0
+	results, err := mpq.Extract(dir, ExtractOptions{
+		Progress: func(result *ExtractResult, count int, total int) {
+			done++
+			c.Check(count, Equals, done)
+			c.Check(total, Equals, 6)
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(results, HasLen, 6)
+	c.Check(done, Equals, 6)
+
+	failed := []string{}
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result.Filename)
+		}
+	}
+	c.Check(failed, DeepEquals, []string{"..\\escape.txt"})
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "Data", "Units", "marine.xml"))
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, "marine")
+	_, err = os.Stat(filepath.Join(dir, "(listfile)"))
+	c.Check(err, IsNil)
+	_, err = os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *ExtractSuite) TestExtractFilters(c *C) {
+	mpq := writeExtractArchive(c)
+	dir := c.MkDir()
+
+	results, err := mpq.Extract(dir, ExtractOptions{
+		Include: []string{"Data/*"},
+		Exclude: []string{"*.xml"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 2)
+	c.Check(results[0].Filename, Equals, "Data\\Units\\zealot.txt")
+	c.Check(results[1].Filename, Equals, "Data\\strings.txt")
+
+	results, err = mpq.Extract(dir, ExtractOptions{Locales: []uint16{0x407}})
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Filename, Equals, "Data\\strings.txt")
+	c.Check(results[0].Skipped, Equals, true)
+
+	_, err = mpq.Extract(dir, ExtractOptions{Include: []string{"["}})
+	c.Check(err, NotNil)
+}
+
+func (s *ExtractSuite) TestExtractOverwrite(c *C) {
+	mpq := writeExtractArchive(c)
+	dir := c.MkDir()
+	path := filepath.Join(dir, "base.txt")
+	c.Assert(ioutil.WriteFile(path, []byte("old"), 0644), IsNil)
+
+	results, err := mpq.Extract(dir, ExtractOptions{Include: []string{"base.txt"}})
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Skipped, Equals, true)
+	data, _ := ioutil.ReadFile(path)
+	c.Check(string(data), Equals, "old")
+
+	results, err = mpq.Extract(dir, ExtractOptions{Include: []string{"base.txt"}, Overwrite: true})
+	c.Assert(err, IsNil)
+	c.Check(results[0].Skipped, Equals, false)
+	data, _ = ioutil.ReadFile(path)
+	c.Check(string(data), Equals, "base")
+}
@@ -30,14 +30,27 @@ package mpq
 
 import ()
 
+// Block table flags
+const (
+	FlagImplode      uint32 = 0x00000100
+	FlagCompress     uint32 = 0x00000200
+	FlagEncrypted    uint32 = 0x00010000
+	FlagFixKey       uint32 = 0x00020000
+	FlagPatchFile    uint32 = 0x00100000
+	FlagSingleUnit   uint32 = 0x01000000
+	FlagDeleteMarker uint32 = 0x02000000
+	FlagSectorCrc    uint32 = 0x04000000
+	FlagExists       uint32 = 0x80000000
+)
+
 type File struct {
-	Filename string
+	Filename string `xml:"filename" json:"filename"`
 
-	CompressedSize uint32
-	FileSize       uint32
-	Flags          uint32
-	Language       uint16
-	Platform       uint16
+	CompressedSize uint32 `xml:"compressedSize" json:"compressedSize"`
+	FileSize       uint32 `xml:"fileSize" json:"fileSize"`
+	Flags          uint32 `xml:"flags" json:"flags"`
+	Language       uint16 `xml:"language" json:"language"`
+	Platform       uint16 `xml:"platform" json:"platform"`
 
 	compressionType byte
 	block           *BlockEntry
@@ -48,6 +61,7 @@ func newFile(filename string, hash *HashEntry, block *BlockEntry) (file *File) {
 	file = new(File)
 
 	file.Filename = filename
+	file.CompressedSize = block.CompressedSize
 	file.FileSize = block.FileSize
 	file.Flags = block.Flags
 	file.Language = hash.Language
@@ -60,3 +74,17 @@ func newFile(filename string, hash *HashEntry, block *BlockEntry) (file *File) {
 
 	return
 }
+
+func (file *File) isCompressed() bool {
+	return file.Flags&(FlagCompress|FlagImplode) != 0
+}
+
+// isImploded reports whether the file is compressed with PKWARE DCL
+// alone, which stores its data without a compression mask byte.
+func (file *File) isImploded() bool {
+	return file.Flags&FlagImplode != 0
+}
+
+func (file *File) isSingleUnit() bool {
+	return file.Flags&FlagSingleUnit != 0
+}
@@ -34,13 +34,13 @@ import (
 )
 
 type HashEntry struct {
-	XMLName xml.Name `xml:"hashEntry"`
+	XMLName xml.Name `xml:"hashEntry" json:"-"`
 
-	FilePathHashA uint32 `xml:"filePathHashA"`
-	FilePathHashB uint32 `xml:"filePathHashB"`
-	Language      uint16 `xml:"language"`
-	Platform      uint16 `xml:"platform"`
-	BlockIndex    uint32 `xml:"blockIndex"`
+	FilePathHashA uint32 `xml:"filePathHashA" json:"filePathHashA"`
+	FilePathHashB uint32 `xml:"filePathHashB" json:"filePathHashB"`
+	Language      uint16 `xml:"language" json:"language"`
+	Platform      uint16 `xml:"platform" json:"platform"`
+	BlockIndex    uint32 `xml:"blockIndex" json:"blockIndex"`
 }
 
 func newHashEntry(data []byte) (entry *HashEntry) {
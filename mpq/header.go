@@ -33,20 +33,20 @@ import (
 )
 
 type Header struct {
-	XMLName xml.Name `xml:"header"`
+	XMLName xml.Name `xml:"header" json:"-"`
 
-	HeaderSize    uint32 `xml:"headerSize"`
-	ArchiveSize   uint32 `xml:"archiveSize"`
-	FormatVersion uint16 `xml:"formatVersion"`
+	HeaderSize    uint32 `xml:"headerSize" json:"headerSize"`
+	ArchiveSize   uint32 `xml:"archiveSize" json:"archiveSize"`
+	FormatVersion uint16 `xml:"formatVersion" json:"formatVersion"`
 
-	BlockSize uint16 `xml:"blockSize"`
+	BlockSize uint16 `xml:"blockSize" json:"blockSize"`
 
-	HashTableOffset   uint32 `xml:"hashTableOffset"`
-	BlockTableOffset  uint32 `xml:"blockTableOffset"`
-	HashTableEntries  uint32 `xml:"hashTableEntries"`
-	BlockTableEntries uint32 `xml:"blockTableEntries"`
+	HashTableOffset   uint32 `xml:"hashTableOffset" json:"hashTableOffset"`
+	BlockTableOffset  uint32 `xml:"blockTableOffset" json:"blockTableOffset"`
+	HashTableEntries  uint32 `xml:"hashTableEntries" json:"hashTableEntries"`
+	BlockTableEntries uint32 `xml:"blockTableEntries" json:"blockTableEntries"`
 
-	ExtendedBlockTableOffset uint64 `xml:"extendedBlockTableOffset"`
-	HashTableOffsetHigh      uint16 `xml:"hashTableOffsetHigh"`
-	BlockTableOffsetHigh     uint16 `xml:"blockTableOffsetHigh"`
+	ExtendedBlockTableOffset uint64 `xml:"extendedBlockTableOffset" json:"extendedBlockTableOffset"`
+	HashTableOffsetHigh      uint16 `xml:"hashTableOffsetHigh" json:"hashTableOffsetHigh"`
+	BlockTableOffsetHigh     uint16 `xml:"blockTableOffsetHigh" json:"blockTableOffsetHigh"`
 }
@@ -0,0 +1,156 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"fmt"
+)
+
+// Limits restricts how much an Mpq will decompress so archives from
+// untrusted sources can't exhaust memory.  A zero value for any of the
+// fields means that value isn't limited.
+type Limits struct {
+	MaxFileSize    uint64  // Largest decompressed size of a single file
+	MaxArchiveSize uint64  // Most decompressed bytes read from the archive
+	MaxRatio       float64 // Largest decompressed to compressed size ratio
+	MaxEntries     uint32  // Most entries in the hash or block table
+	MaxStreamSize  uint64  // Largest archive read from a stream that can't seek
+}
+
+// DefaultLimits are the limits used by NewMpq.
+var DefaultLimits = Limits{}
+
+type LimitKind int
+
+const (
+	LimitFileSize LimitKind = iota
+	LimitArchiveSize
+	LimitRatio
+	LimitEntries
+	LimitStreamSize
+)
+
+func (kind LimitKind) String() string {
+	switch kind {
+	case LimitFileSize:
+		return "file size"
+	case LimitArchiveSize:
+		return "archive size"
+	case LimitRatio:
+		return "compression ratio"
+	case LimitEntries:
+		return "table entries"
+	case LimitStreamSize:
+		return "stream size"
+	}
+
+	return "unknown"
+}
+
+// LimitError is returned when reading an archive would exceed one
+// of its Limits.
+type LimitError struct {
+	Kind     LimitKind
+	Filename string // Empty for limits that apply to the whole archive
+	Value    float64
+	Max      float64
+}
+
+func (e *LimitError) Error() string {
+	format := "%.0f"
+	if e.Kind == LimitRatio {
+		format = "%.2f"
+	}
+
+	msg := fmt.Sprintf("Exceeded %v limit: "+format+" > "+format,
+		e.Kind, e.Value, e.Max)
+	if len(e.Filename) > 0 {
+		msg += fmt.Sprintf(" (file: %v)", e.Filename)
+	}
+
+	return msg
+}
+
+func (limits *Limits) checkEntries(header *Header) (err error) {
+	if limits.MaxEntries == 0 {
+		return nil
+	}
+
+	entries := header.HashTableEntries
+	if header.BlockTableEntries > entries {
+		entries = header.BlockTableEntries
+	}
+	if entries > limits.MaxEntries {
+		return &LimitError{
+			Kind:  LimitEntries,
+			Value: float64(entries),
+			Max:   float64(limits.MaxEntries),
+		}
+	}
+
+	return nil
+}
+
+func (limits *Limits) checkFile(file *File) (err error) {
+	if limits.MaxFileSize > 0 && uint64(file.FileSize) > limits.MaxFileSize {
+		return &LimitError{
+			Kind:     LimitFileSize,
+			Filename: file.Filename,
+			Value:    float64(file.FileSize),
+			Max:      float64(limits.MaxFileSize),
+		}
+	}
+
+	if limits.MaxRatio > 0 && file.isCompressed() {
+		ratio := float64(file.FileSize) / float64(file.CompressedSize)
+		if file.CompressedSize == 0 || ratio > limits.MaxRatio {
+			return &LimitError{
+				Kind:     LimitRatio,
+				Filename: file.Filename,
+				Value:    ratio,
+				Max:      limits.MaxRatio,
+			}
+		}
+	}
+
+	return nil
+}
+
+func (limits *Limits) checkArchiveSize(file *File, total uint64) (err error) {
+	if limits.MaxArchiveSize > 0 && total > limits.MaxArchiveSize {
+		return &LimitError{
+			Kind:     LimitArchiveSize,
+			Filename: file.Filename,
+			Value:    float64(total),
+			Max:      float64(limits.MaxArchiveSize),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type LimitsSuite struct{}
+
+var _ = Suite(&LimitsSuite{})
+
+func (s *LimitsSuite) TestEntriesLimit(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpqWithLimits(reader, Limits{MaxEntries: 8})
+	c.Check(mpq, IsNil)
+	c.Assert(err, FitsTypeOf, &LimitError{})
+	c.Check(err.(*LimitError).Kind, Equals, LimitEntries)
+	c.Check(err.(*LimitError).Value, Equals, float64(16))
+}
+
+func (s *LimitsSuite) TestFileSizeLimit(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpqWithLimits(reader, Limits{MaxFileSize: 2048})
+	c.Assert(err, IsNil)
+
+	// Files over the limit are still listed, they just can't be read
+	c.Check(len(mpq.Files()), Equals, len(expectedFiles))
+
+	_, err = mpq.ReadFile("replay.details")
+	c.Check(err, IsNil)
+
+	_, err = mpq.File("replay.game.events")
+	c.Assert(err, FitsTypeOf, &LimitError{})
+	c.Check(err.(*LimitError).Kind, Equals, LimitFileSize)
+	c.Check(err.Error(), Equals,
+		"Exceeded file size limit: 266269 > 2048 (file: replay.game.events)")
+}
+
+func (s *LimitsSuite) TestRatioLimit(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpqWithLimits(reader, Limits{MaxRatio: 2.5})
+	c.Assert(err, IsNil)
+
+	_, err = mpq.ReadFile("replay.initData")
+	c.Check(err, IsNil)
+
+	_, err = mpq.ReadFile("replay.game.events")
+	c.Assert(err, FitsTypeOf, &LimitError{})
+	c.Check(err.(*LimitError).Kind, Equals, LimitRatio)
+	c.Check(err.(*LimitError).Filename, Equals, "replay.game.events")
+}
+
+func (s *LimitsSuite) TestArchiveSizeLimit(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	// The list file (164 bytes) is read while opening the archive
+	mpq, err := NewMpqWithLimits(reader, Limits{MaxArchiveSize: 1000})
+	c.Assert(err, IsNil)
+
+	_, err = mpq.ReadFile("replay.details")
+	c.Check(err, IsNil)
+
+	_, err = mpq.ReadFile("replay.details")
+	c.Assert(err, FitsTypeOf, &LimitError{})
+	c.Check(err.(*LimitError).Kind, Equals, LimitArchiveSize)
+}
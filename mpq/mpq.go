@@ -30,8 +30,8 @@
 package mpq
 
 import (
-	"compress/bzip2"
 	"encoding/binary"
+	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -41,33 +41,42 @@ import (
 
 var EOF = errors.New("EOF")
 
-const (
-	CompressNone  byte = 0x00
-	CompressBzip2 byte = 0x10
-)
-
 type Mpq struct {
-	XMLName xml.Name `xml:"mpq"`
+	XMLName xml.Name `xml:"mpq" json:"-"`
 
 	reader io.ReadSeeker
+	closer io.Closer
+	size   int64
+	limits Limits
 
-	ArchiveOffset uint32 `xml:"archiveOffset"`
-	Header        Header `xml:"header"`
+	ArchiveOffset uint32 `xml:"archiveOffset" json:"archiveOffset"`
+	Header        Header `xml:"header" json:"header"`
 
-	HasUserData bool      `xml:"-"`
-	UserData    *UserData `xml:"userData"`
+	HasUserData bool      `xml:"-" json:"-"`
+	UserData    *UserData `xml:"userData" json:"userData"`
 
 	files        map[string]*File
-	HashEntries  []*HashEntry  `xml:"hashEntries>hashEntry"`
-	BlockEntries []*BlockEntry `xml:"blockEntries>blockEntry"`
+	HashEntries  []*HashEntry  `xml:"hashEntries>hashEntry" json:"hashEntries"`
+	BlockEntries []*BlockEntry `xml:"blockEntries>blockEntry" json:"blockEntries"`
 
 	file          *File
 	fileReader    io.Reader
 	fileBytesRead int
+
+	bytesDecompressed uint64
+
+	cache *fileCache
 }
 
 func NewMpq(reader io.ReadSeeker) (mpq *Mpq, err error) {
+	return NewMpqWithLimits(reader, DefaultLimits)
+}
+
+// NewMpqWithLimits reads an MPQ the same way as NewMpq but returns a
+// *LimitError instead of decompressing more than the limits allow.
+func NewMpqWithLimits(reader io.ReadSeeker, limits Limits) (mpq *Mpq, err error) {
 	mpq = new(Mpq)
+	mpq.limits = limits
 	err = mpq.readHeaders(reader)
 	if err != nil {
 		return nil, err
@@ -79,6 +88,10 @@ func (mpq *Mpq) readHeaders(reader io.ReadSeeker) (err error) {
 	mpq.files = make(map[string]*File)
 
 	mpq.reader = reader
+	mpq.size, err = mpq.reader.Seek(0, 2)
+	if err != nil {
+		return err
+	}
 	mpq.reader.Seek(0, 0)
 
 	err = mpq.readHeader()
@@ -86,6 +99,11 @@ func (mpq *Mpq) readHeaders(reader io.ReadSeeker) (err error) {
 		return err
 	}
 
+	err = mpq.limits.checkEntries(&mpq.Header)
+	if err != nil {
+		return err
+	}
+
 	err = mpq.readHashTable()
 	if err != nil {
 		return err
@@ -105,57 +123,19 @@ func (mpq *Mpq) readHeaders(reader io.ReadSeeker) (err error) {
 }
 
 func (mpq *Mpq) File(filename string) (file *File, err error) {
-	// First see if the file is already in the map
-	file, found := mpq.files[filename]
-	if !found {
-		// If it's not, try to load it
-		fileHash, hashErr := mpq.getHashEntry(filename)
-		if hashErr != nil {
-			err = fmt.Errorf("Unable to find file: %v",
-				filename)
-			return
-		}
-		fileBlock := mpq.BlockEntries[fileHash.BlockIndex]
-
-		file = newFile(filename, fileHash, fileBlock)
-		mpq.files[filename] = file
-	}
-
-	_, err = mpq.reader.Seek(int64(mpq.ArchiveOffset+
-		file.block.FilePosition), 0)
+	file, err = mpq.lookupFile(filename)
 	if err != nil {
 		return
 	}
-	compType := make([]byte, 1)
-	_, err = mpq.reader.Read(compType)
+
+	err = mpq.limits.checkFile(file)
 	if err != nil {
 		return
 	}
 
-	file.compressionType = compType[0]
-	switch file.compressionType {
-	case CompressBzip2:
-		mpq.fileReader = bzip2.NewReader(mpq.reader)
-		break
-	case CompressNone:
-		// Explicitly found that this doesn't have
-		// compression, so don't go back to the
-		// original file position as in the default.
-		mpq.fileReader = mpq.reader
-		break
-	default:
-		// Don't know this compression type, so just
-		// reset back one byte and read from there as
-		// if this file doesn't have a header byte.  This
-		// can happen if the file flags say the file is
-		// compressed but the header byte doesn't exist.
-		_, err = mpq.reader.Seek(int64(mpq.ArchiveOffset+
-			file.block.FilePosition), 0)
-		if err != nil {
-			return
-		}
-		mpq.fileReader = mpq.reader
-		break
+	mpq.fileReader, err = mpq.openFile(file)
+	if err != nil {
+		return
 	}
 
 	mpq.file = file
@@ -165,10 +145,81 @@ func (mpq *Mpq) File(filename string) (file *File, err error) {
 	return
 }
 
+func (mpq *Mpq) lookupFile(filename string) (file *File, err error) {
+	// First see if the file is already in the map
+	file, found := mpq.files[filename]
+	if found {
+		return file, nil
+	}
+
+	// If it's not, try to load it
+	fileHash, hashErr := mpq.getHashEntry(filename)
+	if hashErr != nil {
+		return nil, fmt.Errorf("Unable to find file: %v",
+			filename)
+	}
+	if fileHash.BlockIndex >= uint32(len(mpq.BlockEntries)) {
+		return nil, fmt.Errorf("Invalid block index for file: %v",
+			filename)
+	}
+	fileBlock := mpq.BlockEntries[fileHash.BlockIndex]
+
+	file = newFile(filename, fileHash, fileBlock)
+	mpq.files[filename] = file
+
+	return file, nil
+}
+
+func (mpq *Mpq) openFile(file *File) (reader io.Reader, err error) {
+	position := int64(mpq.ArchiveOffset + file.block.FilePosition)
+	_, err = mpq.reader.Seek(position, 0)
+	if err != nil {
+		return
+	}
+
+	if !file.isCompressed() {
+		return mpq.reader, nil
+	}
+	if !file.isSingleUnit() {
+		return newSectorReader(mpq, file, position)
+	}
+	if file.CompressedSize >= file.FileSize {
+		// Compressing the file didn't make it any smaller
+		// so it was stored without a compression mask.
+		return mpq.reader, nil
+	}
+	if file.isImploded() {
+		file.compressionType = CompressPkware
+		return decompress(file.Filename, CompressPkware,
+			io.LimitReader(mpq.reader, int64(file.CompressedSize)))
+	}
+
+	mask := make([]byte, 1)
+	_, err = io.ReadFull(mpq.reader, mask)
+	if err != nil {
+		return
+	}
+	file.compressionType = mask[0]
+
+	return decompress(file.Filename, mask[0],
+		io.LimitReader(mpq.reader, int64(file.CompressedSize)-1))
+}
+
 func (mpq *Mpq) Files() (files map[string]*File) {
 	return mpq.files
 }
 
+// MarshalJSON encodes the archive's header, user data and tables along
+// with its known files, sorted by name.
+func (mpq *Mpq) MarshalJSON() ([]byte, error) {
+	// mpqFields has Mpq's fields without this method
+	type mpqFields Mpq
+	return json.Marshal(&struct {
+		*mpqFields
+		Files []*File `json:"files"`
+	}{(*mpqFields)(mpq), mpq.sortedFiles()})
+}
+
 func (mpq *Mpq) Read(p []byte) (n int, err error) {
 	// Don't allow the read to go past the end of the
 	// file, even if there's more data in the MPQ.
@@ -182,8 +233,16 @@ func (mpq *Mpq) Read(p []byte) (n int, err error) {
 	if len(p) > bytesLeft {
 		readBuffer = p[:bytesLeft]
 	}
+
+	err = mpq.limits.checkArchiveSize(mpq.file,
+		mpq.bytesDecompressed+uint64(len(readBuffer)))
+	if err != nil {
+		return 0, err
+	}
+
 	n, err = mpq.fileReader.Read(readBuffer)
 	mpq.fileBytesRead += n
+	mpq.bytesDecompressed += uint64(n)
 	if err != nil {
 		return n, err
 	}
@@ -195,6 +254,39 @@ func (mpq *Mpq) Read(p []byte) (n int, err error) {
 	return
 }
 
+// ReadFile reads the entire contents of a file in the MPQ.  If the
+// cache is enabled the file is only decompressed the first time it's
+// read, and each call returns its own copy of the data.
+func (mpq *Mpq) ReadFile(filename string) (data []byte, err error) {
+	if mpq.cache != nil {
+		cached, found := mpq.cache.get(filename)
+		if found {
+			data = make([]byte, len(cached))
+			copy(data, cached)
+			return data, nil
+		}
+	}
+
+	file, err := mpq.File(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	data = make([]byte, file.FileSize)
+	_, err = io.ReadFull(mpq, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if mpq.cache != nil {
+		cached := make([]byte, len(data))
+		copy(cached, data)
+		mpq.cache.put(filename, cached)
+	}
+
+	return data, nil
+}
+
 func (mpq *Mpq) getHashEntry(filename string) (entry *HashEntry, err error) {
 	hashA := hashString(filename, 0x100)
 	hashB := hashString(filename, 0x200)
@@ -230,6 +322,10 @@ func (mpq *Mpq) readHeader() (err error) {
 		// Archive Size
 		_, _ = mpq.reader.Read(buf[:4])
 		userArchiveSize := binary.LittleEndian.Uint32(buf[:4])
+		if userArchiveSize < 16 || int64(userArchiveSize) > mpq.size {
+			return fmt.Errorf("Invalid user data size: %v",
+				userArchiveSize)
+		}
 
 		mpq.reader.Seek(0, 0)
 
@@ -243,9 +339,23 @@ func (mpq *Mpq) readHeader() (err error) {
 
 	_, _ = mpq.reader.Read(buf[:4])
 	mpq.Header.HeaderSize = binary.LittleEndian.Uint32(buf[:4])
+	if mpq.Header.HeaderSize < 32 || int64(mpq.Header.HeaderSize) > mpq.size {
+		return fmt.Errorf("Invalid MPQ header size: %v",
+			mpq.Header.HeaderSize)
+	}
 
-	buf = make([]byte, mpq.Header.HeaderSize-4)
-	mpq.reader.Read(buf)
+	// The signature and header size have already been read.  Version
+	// 0 headers are shorter than the fields read below, so leave the
+	// missing fields zeroed.
+	bufSize := mpq.Header.HeaderSize - 8
+	if bufSize < 0x24 {
+		bufSize = 0x24
+	}
+	buf = make([]byte, bufSize)
+	_, err = io.ReadFull(mpq.reader, buf[:mpq.Header.HeaderSize-8])
+	if err != nil {
+		return fmt.Errorf("Could not read MPQ header")
+	}
 
 	mpq.Header.ArchiveSize = binary.LittleEndian.Uint32(buf[:4])
 	mpq.Header.FormatVersion = binary.LittleEndian.Uint16(buf[0x04 : 0x04+2])
@@ -258,6 +368,13 @@ func (mpq *Mpq) readHeader() (err error) {
 	mpq.Header.HashTableOffsetHigh = binary.LittleEndian.Uint16(buf[0x20 : 0x20+2])
 	mpq.Header.BlockTableOffsetHigh = binary.LittleEndian.Uint16(buf[0x22 : 0x22+2])
 
+	// Sectors larger than this aren't used by any known archive
+	// and would overflow the sector size.
+	if mpq.Header.BlockSize > 22 {
+		return fmt.Errorf("Invalid MPQ block size: %v",
+			mpq.Header.BlockSize)
+	}
+
 	mpq.ArchiveOffset = 0x00
 	if mpq.HasUserData {
 		mpq.ArchiveOffset = mpq.UserData.Header.ArchiveOffset
@@ -269,16 +386,24 @@ func (mpq *Mpq) readHeader() (err error) {
 func (mpq *Mpq) readHashTable() (err error) {
 	HashEntries := mpq.Header.HashTableEntries
 
+	err = mpq.checkTableExtent(mpq.Header.HashTableOffset, HashEntries)
+	if err != nil {
+		return fmt.Errorf("Invalid hash table: %v", err)
+	}
+
 	mpq.HashEntries = make([]*HashEntry, HashEntries)
 
 	mpq.reader.Seek(
-		int64(mpq.ArchiveOffset+mpq.Header.HashTableOffset), 0)
+		int64(mpq.ArchiveOffset)+int64(mpq.Header.HashTableOffset), 0)
 
 	// Each entry is the size of 4x uint32, giving 16 bytes.
 	// Create a buffer and read the entire hash table from
 	// the file, then decrypt it.
 	buffer := make([]byte, HashEntries*16)
-	mpq.reader.Read(buffer)
+	_, err = io.ReadFull(mpq.reader, buffer)
+	if err != nil {
+		return fmt.Errorf("Could not read hash table: %v", err)
+	}
 
 	encryptor := newBlockEncryptor("(hash table)", 0x300)
 	encryptor.decrypt(&buffer)
@@ -296,15 +421,24 @@ func (mpq *Mpq) readHashTable() (err error) {
 func (mpq *Mpq) readBlockTable() (err error) {
 	BlockEntries := mpq.Header.BlockTableEntries
 
+	err = mpq.checkTableExtent(mpq.Header.BlockTableOffset, BlockEntries)
+	if err != nil {
+		return fmt.Errorf("Invalid block table: %v", err)
+	}
+
 	mpq.BlockEntries = make([]*BlockEntry, BlockEntries)
 
-	mpq.reader.Seek(int64(mpq.ArchiveOffset+mpq.Header.BlockTableOffset), 0)
+	mpq.reader.Seek(
+		int64(mpq.ArchiveOffset)+int64(mpq.Header.BlockTableOffset), 0)
 
 	// Each entry is the size of 4x uint32, giving 16 bytes.
 	// Create a buffer and read the entire hash table from
 	// the file, then decrypt it.
 	buffer := make([]byte, BlockEntries*16)
-	mpq.reader.Read(buffer)
+	_, err = io.ReadFull(mpq.reader, buffer)
+	if err != nil {
+		return fmt.Errorf("Could not read block table: %v", err)
+	}
 
 	encryptor := newBlockEncryptor("(block table)", 0x300)
 	encryptor.decrypt(&buffer)
@@ -319,22 +453,35 @@ func (mpq *Mpq) readBlockTable() (err error) {
 	return nil
 }
 
+// checkTableExtent makes sure a table lies within the archive before
+// anything is allocated for it based on the entry count in the header.
+func (mpq *Mpq) checkTableExtent(offset uint32, entries uint32) (err error) {
+	end := int64(mpq.ArchiveOffset) + int64(offset) + int64(entries)*16
+	if end > mpq.size {
+		return fmt.Errorf("%v entries at offset %v extend past the end of the archive",
+			entries, offset)
+	}
+
+	return nil
+}
+
 func (mpq *Mpq) readFiles() (err error) {
 	// Attempt to read the special files just
 	// to get them in the file list, since they
 	// won't be in the list file
-	mpq.File("(attributes)")
-	mpq.File("(signature)")
-	mpq.File("(user data)")
+	mpq.lookupFile("(attributes)")
+	mpq.lookupFile("(signature)")
+	mpq.lookupFile("(user data)")
 
-	listfile, err := mpq.File("(listfile)")
+	// Archives don't need a list file, their files just
+	// can't be listed without one.
+	_, err = mpq.lookupFile("(listfile)")
 	if err != nil {
-		return
+		return nil
 	}
 
-	outBuffer := make([]byte, listfile.FileSize)
-	read, err := mpq.Read(outBuffer)
-	if read <= 0 && err != nil {
+	outBuffer, err := mpq.ReadFile("(listfile)")
+	if err != nil {
 		return
 	}
 
@@ -342,12 +489,7 @@ func (mpq *Mpq) readFiles() (err error) {
 	files := strings.Split(listFile, "\r\n")
 
 	for _, filename := range files {
-		file, err := mpq.File(filename)
-		if err != nil {
-			continue
-		}
-
-		mpq.files[filename] = file
+		mpq.lookupFile(filename)
 	}
 
 	return nil
@@ -29,6 +29,9 @@
 package mpq
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
 	. "launchpad.net/gocheck"
 	"math"
 	"os"
@@ -70,6 +73,8 @@ func (s *MpqSuite) TestUserDataHeader(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(mpq.UserData.Header.MaxUserDataSize, Equals, uint32(512))
 	c.Check(mpq.UserData.Header.UserDataSize, Equals, uint32(60))
+	c.Check(mpq.UserData.Content, HasLen, 60)
+	c.Check(mpq.UserData.Content[0], Equals, byte(0x05))
 }
 
 func (s *MpqSuite) TestReadMpqHeader(c *C) {
@@ -92,6 +97,21 @@ func (s *MpqSuite) TestReadMpqHeader(c *C) {
 	c.Check(mpq.Header.BlockTableOffsetHigh, Equals, uint16(0))
 }
 
+func (s *MpqSuite) TestShortHeaderFieldsZeroed(c *C) {
+	// A version 0 header followed directly by data
+	header := []uint32{32, 40, 3 << 16, 32, 32, 0, 0}
+	buffer := bytes.NewBufferString("MPQ\x1a")
+	c.Assert(binary.Write(buffer, binary.LittleEndian, header), IsNil)
+	buffer.WriteString("\xff\xff\xff\xff\xff\xff\xff\xff")
+
+	mpq := &Mpq{reader: bytes.NewReader(buffer.Bytes()), size: int64(buffer.Len())}
+	c.Assert(mpq.readHeader(), IsNil)
+	c.Check(mpq.Header.FormatVersion, Equals, uint16(0))
+	c.Check(mpq.Header.BlockSize, Equals, uint16(3))
+	c.Check(mpq.Header.ExtendedBlockTableOffset, Equals, uint64(0))
+	c.Check(mpq.Header.HashTableOffsetHigh, Equals, uint16(0))
+}
+
 var expectedFiles = []string{
 	"(listfile)",
 	"(attributes)",
@@ -161,7 +181,7 @@ func (s *MpqSuite) TestReadBeyondFile(c *C) {
 	buffer = make([]byte, finalSize)
 	read, err = mpq.Read(buffer)
 	if read != finalSize {
-		c.Error("Partial read of the wrong size. Expected: %v Actual: %v", finalSize, read)
+		c.Errorf("Partial read of the wrong size. Expected: %v Actual: %v", finalSize, read)
 	}
 	if err == nil || err != EOF {
 		c.Errorf("Partial read expected an EOF error but did not receive one.")
@@ -212,3 +232,40 @@ func (s *MpqSuite) TestReadFromClosedReader(c *C) {
 		c.Error("Attempt to read from a closed io.Reader succeeded.")
 	}
 }
+
+func (s *MpqSuite) TestMarshalJSON(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpq(reader)
+	c.Assert(err, IsNil)
+
+	data, err := json.Marshal(mpq)
+	c.Assert(err, IsNil)
+
+	var decoded struct {
+		ArchiveOffset uint32 `json:"archiveOffset"`
+		Header        struct {
+			HashTableEntries uint32 `json:"hashTableEntries"`
+		} `json:"header"`
+		UserData struct {
+			Header struct {
+				UserDataSize uint32 `json:"userDataSize"`
+			} `json:"userDataHeader"`
+		} `json:"userData"`
+		HashEntries  []map[string]uint32      `json:"hashEntries"`
+		BlockEntries []map[string]uint32      `json:"blockEntries"`
+		Files        []map[string]interface{} `json:"files"`
+	}
+	c.Assert(json.Unmarshal(data, &decoded), IsNil)
+
+	c.Check(decoded.ArchiveOffset, Equals, uint32(1024))
+	c.Check(decoded.Header.HashTableEntries, Equals, uint32(16))
+	c.Check(decoded.UserData.Header.UserDataSize, Equals, uint32(60))
+	c.Check(decoded.HashEntries, HasLen, 16)
+	c.Check(decoded.BlockEntries, HasLen, 10)
+	c.Assert(decoded.Files, HasLen, 10)
+	c.Check(decoded.Files[0]["filename"], Equals, "(attributes)")
+	c.Check(decoded.Files[0]["fileSize"], Equals, float64(288))
+}
@@ -0,0 +1,147 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NestedSeparator separates the archives in a nested path such as
+// "outer.mpq!inner.SC2Map!MapInfo".
+const NestedSeparator = "!"
+
+// SplitNestedPath splits a nested path into its parts.
+func SplitNestedPath(path string) []string {
+	return strings.Split(path, NestedSeparator)
+}
+
+// OpenArchive opens an MPQ stored as a file inside this one.  The
+// inner archive is read into memory and uses the same limits as this
+// one, so it stays usable after this one is closed.
+func (mpq *Mpq) OpenArchive(filename string) (archive *Mpq, err error) {
+	data, err := mpq.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err = NewMpqWithLimits(bytes.NewReader(data), mpq.limits)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read nested MPQ %v: %v", filename, err)
+	}
+
+	return archive, nil
+}
+
+// OpenNested opens the innermost archive of a nested path relative to
+// this one, where each part of the path is an archive inside the one
+// before it.  An empty path returns this archive.
+func (mpq *Mpq) OpenNested(path string) (archive *Mpq, err error) {
+	archive = mpq
+	if len(path) == 0 {
+		return archive, nil
+	}
+
+	for _, part := range SplitNestedPath(path) {
+		archive, err = archive.OpenArchive(part)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return archive, nil
+}
+
+// ReadNestedFile reads a file from a nested path relative to this
+// archive, where the last part of the path is the file and the parts
+// before it are archives.
+func (mpq *Mpq) ReadNestedFile(path string) (data []byte, err error) {
+	archive := mpq
+	parts := SplitNestedPath(path)
+	if len(parts) > 1 {
+		archive, err = mpq.OpenNested(strings.Join(parts[:len(parts)-1], NestedSeparator))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return archive.ReadFile(parts[len(parts)-1])
+}
+
+// OpenNested opens the innermost archive of a nested path whose first
+// part is a file on disk, such as "outer.mpq!inner.SC2Map".  A file
+// on disk whose name contains the separator is opened directly.  The
+// returned archive must be closed.
+func OpenNested(path string) (archive *Mpq, err error) {
+	filename, inner := path, ""
+	if _, err := os.Stat(path); err != nil {
+		parts := SplitNestedPath(path)
+		filename = parts[0]
+		inner = strings.Join(parts[1:], NestedSeparator)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	outer, err := NewMpq(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	outer.closer = file
+
+	if len(inner) == 0 {
+		return outer, nil
+	}
+	defer outer.Close()
+
+	return outer.OpenNested(inner)
+}
+
+// ReadNested reads a file from a nested path whose first part is a
+// file on disk and whose last part is the file to read, such as
+// "outer.mpq!inner.SC2Map!MapInfo".
+func ReadNested(path string) (data []byte, err error) {
+	parts := SplitNestedPath(path)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("Nested path has no file: %v", path)
+	}
+
+	archive, err := OpenNested(strings.Join(parts[:len(parts)-1], NestedSeparator))
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	return archive.ReadFile(parts[len(parts)-1])
+}
@@ -0,0 +1,122 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"path/filepath"
+)
+
+type NestedSuite struct{}
+
+var _ = Suite(&NestedSuite{})
+
+func writeArchiveBytes(c *C, files map[string][]byte) []byte {
+	buffer := new(bytes.Buffer)
+	w := NewWriter(buffer)
+	listfile := ""
+	for filename, contents := range files {
+		c.Assert(w.AddFile(filename, contents), IsNil)
+		listfile += filename + "\r\n"
+	}
+	c.Assert(w.AddFile("(listfile)", []byte(listfile)), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	return buffer.Bytes()
+}
+
+// writeNestedArchive writes outer.mpq!inner.SC2Map!deep.mpq to disk
+// and returns the path of outer.mpq.
+func writeNestedArchive(c *C) string {
+	deep := writeArchiveBytes(c, map[string][]byte{"deep.txt": []byte("deep")})
+	inner := writeArchiveBytes(c, map[string][]byte{
+		"MapInfo":  []byte("map info"),
+		"deep.mpq": deep,
+	})
+	outer := writeArchiveBytes(c, map[string][]byte{"inner.SC2Map": inner})
+
+	path := filepath.Join(c.MkDir(), "outer.mpq")
+	c.Assert(ioutil.WriteFile(path, outer, 0644), IsNil)
+
+	return path
+}
+
+func (s *NestedSuite) TestReadNested(c *C) {
+	path := writeNestedArchive(c)
+
+	data, err := ReadNested(path + "!inner.SC2Map!MapInfo")
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "map info")
+
+	data, err = ReadNested(path + "!inner.SC2Map!deep.mpq!deep.txt")
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "deep")
+
+	_, err = ReadNested(path + "!inner.SC2Map!missing")
+	c.Check(err, NotNil)
+	_, err = ReadNested(path)
+	c.Check(err, NotNil)
+}
+
+func (s *NestedSuite) TestOpenNested(c *C) {
+	path := writeNestedArchive(c)
+
+	archive, err := OpenNested(path + "!inner.SC2Map")
+	c.Assert(err, IsNil)
+	defer archive.Close()
+	c.Check(archive.Files(), HasLen, 3)
+
+	data, err := archive.ReadNestedFile("deep.mpq!deep.txt")
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "deep")
+
+	// Files that aren't archives can't be opened as one
+	_, err = archive.OpenNested("MapInfo")
+	c.Check(err, NotNil)
+}
+
+func (s *NestedSuite) TestOpenNestedKeepsLimits(c *C) {
+	inner := writeArchiveBytes(c, map[string][]byte{"big": make([]byte, 4096)})
+	outer := writeArchiveBytes(c, map[string][]byte{"inner.mpq": inner})
+
+	archive, err := NewMpqWithLimits(bytes.NewReader(outer), Limits{MaxFileSize: 8192})
+	c.Assert(err, IsNil)
+
+	nested, err := archive.OpenArchive("inner.mpq")
+	c.Assert(err, IsNil)
+	c.Check(nested.limits, Equals, archive.limits)
+
+	// The inner archive itself is too big to open
+	archive, err = NewMpqWithLimits(bytes.NewReader(outer), Limits{MaxFileSize: 2048})
+	c.Assert(err, IsNil)
+	_, err = archive.OpenArchive("inner.mpq")
+	c.Check(err, FitsTypeOf, &LimitError{})
+}
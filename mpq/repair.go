@@ -0,0 +1,119 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Repair writes a clean copy of the archive to writer.  The hash table
+// is rebuilt from the files named in the listfile plus any extra
+// filenames given, only the blocks belonging to those files are copied,
+// and a new listfile naming all of them is written.  (attributes) is
+// left out since its values are stored by block index.
+//
+// Encrypted files whose key depends on their position in the archive
+// can't be moved, so Repair fails if it finds one.
+func (mpq *Mpq) Repair(writer io.Writer, filenames []string) (err error) {
+	for _, filename := range filenames {
+		mpq.lookupFile(filename)
+	}
+
+	names := []string{}
+	for filename := range mpq.files {
+		if filename == "(attributes)" || filename == "(listfile)" {
+			continue
+		}
+		names = append(names, filename)
+	}
+	sort.Strings(names)
+
+	w := NewWriter(writer)
+	w.blockSize = mpq.Header.BlockSize
+
+	if mpq.HasUserData {
+		content := make([]byte, mpq.UserData.Header.UserDataSize)
+		_, err = mpq.reader.Seek(16, 0)
+		if err != nil {
+			return
+		}
+		_, err = io.ReadFull(mpq.reader, content)
+		if err != nil {
+			return fmt.Errorf("Unable to read user data: %v", err)
+		}
+		w.SetUserData(content)
+	}
+
+	listed := []string{}
+	for _, filename := range names {
+		file := mpq.files[filename]
+		block := file.block
+
+		if block.Flags&FlagExists == 0 {
+			continue
+		}
+		if block.Flags&FlagEncrypted != 0 && block.Flags&FlagFixKey != 0 {
+			return fmt.Errorf("Unable to move encrypted file: %v", filename)
+		}
+
+		start := int64(mpq.ArchiveOffset) + int64(block.FilePosition)
+		if start+int64(block.CompressedSize) > mpq.size {
+			// Nothing to salvage from a block outside the file
+			continue
+		}
+
+		data := make([]byte, block.CompressedSize)
+		_, err = mpq.reader.Seek(start, 0)
+		if err != nil {
+			return
+		}
+		_, err = io.ReadFull(mpq.reader, data)
+		if err != nil {
+			return fmt.Errorf("Unable to read file %v: %v", filename, err)
+		}
+
+		err = w.addBlock(filename, data, block.FileSize, block.Flags,
+			file.Language, file.Platform)
+		if err != nil {
+			return
+		}
+		listed = append(listed, filename)
+	}
+
+	listfile := strings.Join(listed, "\r\n") + "\r\n"
+	err = w.AddFile("(listfile)", []byte(listfile))
+	if err != nil {
+		return
+	}
+
+	return w.Close()
+}
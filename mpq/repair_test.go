@@ -0,0 +1,93 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	. "launchpad.net/gocheck"
+)
+
+type RepairSuite struct{}
+
+var _ = Suite(&RepairSuite{})
+
+func (s *RepairSuite) TestRepair(c *C) {
+	original, err := NewMpqFromBytes(readTestReplay(c))
+	c.Assert(err, IsNil)
+
+	// Orphan the (attributes) block
+	original.HashEntries[0].BlockIndex = hashEntryDeleted
+	c.Check(original.Check().Ok(), Equals, true)
+
+	buffer := new(bytes.Buffer)
+	err = original.Repair(buffer, nil)
+	c.Assert(err, IsNil)
+
+	repaired, err := NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+
+	report := repaired.Check()
+	c.Check(report.Problems, HasLen, 0)
+	c.Check(repaired.Header.BlockTableEntries, Equals, uint32(9))
+
+	c.Check(repaired.HasUserData, Equals, true)
+	c.Check(*repaired.UserData.Header, Equals, *original.UserData.Header)
+
+	for filename := range original.Files() {
+		if filename == "(attributes)" || filename == "(listfile)" {
+			continue
+		}
+		expected, err := original.ReadFile(filename)
+		c.Assert(err, IsNil)
+		actual, err := repaired.ReadFile(filename)
+		c.Assert(err, IsNil)
+		c.Check(actual, DeepEquals, expected)
+	}
+	_, err = repaired.File("(attributes)")
+	c.Check(err, NotNil)
+}
+
+func (s *RepairSuite) TestRepairWithExtraNames(c *C) {
+	original, err := NewMpqFromBytes(readTestReplay(c))
+	c.Assert(err, IsNil)
+
+	// Pretend the listfile didn't know about one of the files
+	delete(original.files, "replay.sync.events")
+
+	buffer := new(bytes.Buffer)
+	err = original.Repair(buffer, []string{"replay.sync.events", "not.there"})
+	c.Assert(err, IsNil)
+
+	repaired, err := NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+	_, found := repaired.Files()["replay.sync.events"]
+	c.Check(found, Equals, true)
+	_, found = repaired.Files()["not.there"]
+	c.Check(found, Equals, false)
+}
@@ -0,0 +1,167 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// sectorReader reads a file that is split into compressed
+// sectors, decompressing one sector at a time as it's read.
+type sectorReader struct {
+	mpq      *Mpq
+	file     *File
+	position int64
+
+	sectorSize uint32
+	sectors    uint32
+	offsets    []uint32
+
+	index   uint32
+	current io.Reader
+}
+
+func newSectorReader(mpq *Mpq, file *File, position int64) (reader *sectorReader, err error) {
+	reader = new(sectorReader)
+
+	reader.mpq = mpq
+	reader.file = file
+	reader.position = position
+	reader.sectorSize = mpq.sectorSize()
+	reader.sectors = (file.FileSize + reader.sectorSize - 1) / reader.sectorSize
+
+	reader.offsets, err = mpq.readSectorOffsets(file, position)
+	if err != nil {
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+func (mpq *Mpq) sectorSize() uint32 {
+	return 512 << mpq.Header.BlockSize
+}
+
+// readSectorOffsets reads the table of sector offsets stored at the
+// beginning of a sectored file.  The table has one entry for the start
+// of each sector plus one marking the end of the last sector.
+func (mpq *Mpq) readSectorOffsets(file *File, position int64) (offsets []uint32, err error) {
+	sectorSize := mpq.sectorSize()
+	sectors := (file.FileSize + sectorSize - 1) / sectorSize
+
+	_, err = mpq.reader.Seek(position, 0)
+	if err != nil {
+		return
+	}
+
+	buffer := make([]byte, (sectors+1)*4)
+	_, err = io.ReadFull(mpq.reader, buffer)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read sector table for file %v: %v",
+			file.Filename, err)
+	}
+
+	offsets = make([]uint32, sectors+1)
+	for idx := range offsets {
+		offsets[idx] = binary.LittleEndian.Uint32(buffer[idx*4 : idx*4+4])
+		if idx > 0 && offsets[idx] < offsets[idx-1] {
+			return nil, fmt.Errorf("Invalid sector table for file %v",
+				file.Filename)
+		}
+	}
+	if offsets[sectors] > file.CompressedSize {
+		return nil, fmt.Errorf("Invalid sector table for file %v",
+			file.Filename)
+	}
+
+	return offsets, nil
+}
+
+func (reader *sectorReader) Read(p []byte) (n int, err error) {
+	for {
+		if reader.current != nil {
+			n, err = reader.current.Read(p)
+			if err == io.EOF {
+				reader.current = nil
+				err = nil
+				if n == 0 {
+					continue
+				}
+			}
+			return
+		}
+
+		if reader.index >= reader.sectors {
+			return 0, io.EOF
+		}
+
+		reader.current, err = reader.openSector(reader.index)
+		if err != nil {
+			return 0, err
+		}
+		reader.index++
+	}
+}
+
+func (reader *sectorReader) openSector(index uint32) (sector io.Reader, err error) {
+	start := reader.offsets[index]
+	end := reader.offsets[index+1]
+
+	_, err = reader.mpq.reader.Seek(reader.position+int64(start), 0)
+	if err != nil {
+		return
+	}
+
+	buffer := make([]byte, end-start)
+	_, err = io.ReadFull(reader.mpq.reader, buffer)
+	if err != nil {
+		return
+	}
+
+	// The last sector is usually smaller than the others
+	expected := reader.file.FileSize - index*reader.sectorSize
+	if expected > reader.sectorSize {
+		expected = reader.sectorSize
+	}
+
+	if uint32(len(buffer)) >= expected || len(buffer) == 0 {
+		// Sectors that don't get any smaller are stored as-is
+		return bytes.NewReader(buffer), nil
+	}
+	if reader.file.isImploded() {
+		return decompress(reader.file.Filename, CompressPkware,
+			bytes.NewReader(buffer))
+	}
+
+	return decompress(reader.file.Filename, buffer[0],
+		bytes.NewReader(buffer[1:]))
+}
@@ -0,0 +1,310 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// FileStats describes how a single block is stored in the archive.
+type FileStats struct {
+	Filename   string `json:"filename"` // Empty if the block's name isn't known
+	BlockIndex int    `json:"blockIndex"`
+
+	CompressedSize uint32  `json:"compressedSize"`
+	FileSize       uint32  `json:"fileSize"`
+	Ratio          float64 `json:"ratio"` // FileSize / CompressedSize
+
+	// Compression masks used by the block's data, CompressNone for
+	// data stored as-is.  Nil if the block couldn't be inspected,
+	// such as when it's encrypted.
+	Compression []byte `json:"compression"`
+
+	Encrypted bool `json:"encrypted"`
+	Orphaned  bool `json:"orphaned"` // Not referenced by any hash table entry
+}
+
+// MarshalJSON encodes the compression masks as numbers instead of the
+// base64 string used for byte slices.
+func (file *FileStats) MarshalJSON() ([]byte, error) {
+	// fileStatsFields has FileStats' fields without this method
+	type fileStatsFields FileStats
+
+	var masks []int
+	if file.Compression != nil {
+		masks = []int{}
+		for _, mask := range file.Compression {
+			masks = append(masks, int(mask))
+		}
+	}
+
+	return json.Marshal(&struct {
+		*fileStatsFields
+		Compression []int `json:"compression"`
+	}{(*fileStatsFields)(file), masks})
+}
+
+// Slack is a range of the archive not used by the header, the hash
+// and block tables or any block.  Offset is relative to the start of
+// the archive.
+type Slack struct {
+	Offset uint32 `json:"offset"`
+	Size   uint32 `json:"size"`
+}
+
+// Stats is a report on how space is used in an archive.
+type Stats struct {
+	Files []*FileStats `json:"files"` // Blocks in use, by block index
+
+	CompressedSize uint64  `json:"compressedSize"`
+	FileSize       uint64  `json:"fileSize"`
+	Ratio          float64 `json:"ratio"`
+
+	// Number of compressed units (single unit files or sectors)
+	// using each compression mask.
+	Codecs map[byte]int `json:"codecs" xml:"-"`
+
+	Slack     []Slack `json:"slack"`
+	SlackSize uint64  `json:"slackSize"`
+
+	HashSlots   int     `json:"hashSlots"`
+	HashUsed    int     `json:"hashUsed"`
+	HashDeleted int     `json:"hashDeleted"`
+	LoadFactor  float64 `json:"loadFactor"` // HashUsed / HashSlots
+
+	OrphanedBlocks []int `json:"orphanedBlocks"`
+}
+
+// Stats reports compression ratios, compression use, space between
+// blocks and hash table usage for the archive.
+func (mpq *Mpq) Stats() (stats *Stats) {
+	stats = new(Stats)
+	stats.Codecs = make(map[byte]int)
+
+	blockNames := make(map[uint32]string)
+	for filename, file := range mpq.files {
+		blockNames[file.hash.BlockIndex] = filename
+	}
+
+	referenced := make(map[uint32]bool)
+	stats.HashSlots = len(mpq.HashEntries)
+	for _, entry := range mpq.HashEntries {
+		switch entry.BlockIndex {
+		case hashEntryEmpty:
+		case hashEntryDeleted:
+			stats.HashDeleted++
+		default:
+			stats.HashUsed++
+			referenced[entry.BlockIndex] = true
+		}
+	}
+	if stats.HashSlots > 0 {
+		stats.LoadFactor = float64(stats.HashUsed) / float64(stats.HashSlots)
+	}
+
+	for idx, block := range mpq.BlockEntries {
+		if block.Flags&FlagExists == 0 {
+			continue
+		}
+
+		file := &FileStats{
+			Filename:       blockNames[uint32(idx)],
+			BlockIndex:     idx,
+			CompressedSize: block.CompressedSize,
+			FileSize:       block.FileSize,
+			Ratio:          ratio(uint64(block.FileSize), uint64(block.CompressedSize)),
+			Encrypted:      block.Flags&FlagEncrypted != 0,
+			Orphaned:       !referenced[uint32(idx)],
+		}
+		if file.Orphaned {
+			stats.OrphanedBlocks = append(stats.OrphanedBlocks, idx)
+		}
+
+		if !file.Encrypted {
+			masks := mpq.blockCompression(block)
+			for _, mask := range masks {
+				stats.Codecs[mask]++
+			}
+			file.Compression = distinctMasks(masks)
+		}
+
+		stats.CompressedSize += uint64(block.CompressedSize)
+		stats.FileSize += uint64(block.FileSize)
+		stats.Files = append(stats.Files, file)
+	}
+	stats.Ratio = ratio(stats.FileSize, stats.CompressedSize)
+
+	mpq.findSlack(stats)
+
+	return stats
+}
+
+func ratio(fileSize uint64, compressedSize uint64) float64 {
+	if compressedSize == 0 {
+		return 1
+	}
+	return float64(fileSize) / float64(compressedSize)
+}
+
+// blockCompression returns the compression mask of each unit of the
+// block's data, or nil if the data can't be read.
+func (mpq *Mpq) blockCompression(block *BlockEntry) (masks []byte) {
+	position := int64(mpq.ArchiveOffset) + int64(block.FilePosition)
+
+	// Imploded data has no mask byte
+	unitMask := func(offset int64, stored uint32, expected uint32) (mask byte, ok bool) {
+		if stored >= expected || stored == 0 {
+			return CompressNone, true
+		}
+		if block.Flags&FlagImplode != 0 {
+			return CompressPkware, true
+		}
+
+		buffer := make([]byte, 1)
+		_, err := mpq.reader.Seek(offset, 0)
+		if err != nil {
+			return 0, false
+		}
+		_, err = io.ReadFull(mpq.reader, buffer)
+		if err != nil {
+			return 0, false
+		}
+		return buffer[0], true
+	}
+
+	if block.Flags&(FlagCompress|FlagImplode) == 0 {
+		return []byte{CompressNone}
+	}
+
+	if block.Flags&FlagSingleUnit != 0 {
+		mask, ok := unitMask(position, block.CompressedSize, block.FileSize)
+		if !ok {
+			return nil
+		}
+		return []byte{mask}
+	}
+
+	file := &File{
+		CompressedSize: block.CompressedSize,
+		FileSize:       block.FileSize,
+	}
+	offsets, err := mpq.readSectorOffsets(file, position)
+	if err != nil {
+		return nil
+	}
+
+	sectorSize := mpq.sectorSize()
+	for idx := 0; idx+1 < len(offsets); idx++ {
+		expected := block.FileSize - uint32(idx)*sectorSize
+		if expected > sectorSize {
+			expected = sectorSize
+		}
+
+		mask, ok := unitMask(position+int64(offsets[idx]),
+			offsets[idx+1]-offsets[idx], expected)
+		if !ok {
+			return nil
+		}
+		masks = append(masks, mask)
+	}
+
+	return masks
+}
+
+func distinctMasks(masks []byte) (distinct []byte) {
+	if masks == nil {
+		return nil
+	}
+
+	seen := make(map[byte]bool)
+	distinct = []byte{}
+	for _, mask := range masks {
+		if !seen[mask] {
+			seen[mask] = true
+			distinct = append(distinct, mask)
+		}
+	}
+	sort.Slice(distinct, func(i, j int) bool {
+		return distinct[i] < distinct[j]
+	})
+
+	return distinct
+}
+
+// findSlack finds the ranges of the archive that nothing uses.
+func (mpq *Mpq) findSlack(stats *Stats) {
+	header := &mpq.Header
+	type extent struct {
+		start, end uint64
+	}
+	extents := []extent{
+		{0, uint64(header.HeaderSize)},
+		{uint64(header.HashTableOffset),
+			uint64(header.HashTableOffset) + uint64(header.HashTableEntries)*16},
+		{uint64(header.BlockTableOffset),
+			uint64(header.BlockTableOffset) + uint64(header.BlockTableEntries)*16},
+	}
+	if header.ExtendedBlockTableOffset != 0 {
+		extents = append(extents, extent{header.ExtendedBlockTableOffset,
+			header.ExtendedBlockTableOffset + uint64(header.BlockTableEntries)*2})
+	}
+	for _, block := range mpq.BlockEntries {
+		if block.Flags&FlagExists == 0 {
+			continue
+		}
+		start := uint64(block.FilePosition)
+		extents = append(extents, extent{start, start + uint64(block.CompressedSize)})
+	}
+
+	sort.Slice(extents, func(i, j int) bool {
+		return extents[i].start < extents[j].start
+	})
+
+	archiveSize := uint64(header.ArchiveSize)
+	addSlack := func(start uint64, end uint64) {
+		if end > archiveSize {
+			end = archiveSize
+		}
+		if end > start {
+			stats.Slack = append(stats.Slack, Slack{uint32(start), uint32(end - start)})
+			stats.SlackSize += end - start
+		}
+	}
+
+	cursor := uint64(0)
+	for _, cur := range extents {
+		addSlack(cursor, cur.start)
+		if cur.end > cursor {
+			cursor = cur.end
+		}
+	}
+	addSlack(cursor, archiveSize)
+}
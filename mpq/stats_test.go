@@ -0,0 +1,109 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"encoding/json"
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type StatsSuite struct{}
+
+var _ = Suite(&StatsSuite{})
+
+func (s *StatsSuite) TestStats(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpq(reader)
+	c.Assert(err, IsNil)
+
+	stats := mpq.Stats()
+	c.Assert(stats.Files, HasLen, 10)
+	c.Check(stats.CompressedSize, Equals, uint64(108552))
+	c.Check(stats.FileSize, Equals, uint64(282120))
+	c.Check(stats.Codecs, DeepEquals, map[byte]int{CompressNone: 2, CompressBzip2: 8})
+	c.Check(stats.Slack, HasLen, 0)
+	c.Check(stats.SlackSize, Equals, uint64(0))
+	c.Check(stats.HashSlots, Equals, 16)
+	c.Check(stats.HashUsed, Equals, 10)
+	c.Check(stats.LoadFactor, Equals, 0.625)
+	c.Check(stats.OrphanedBlocks, HasLen, 0)
+
+	details := stats.Files[0]
+	c.Check(details.Filename, Equals, "replay.details")
+	c.Check(details.Ratio, Equals, 1.0)
+	c.Check(details.Compression, DeepEquals, []byte{CompressNone})
+
+	gameEvents := stats.Files[2]
+	c.Check(gameEvents.Filename, Equals, "replay.game.events")
+	c.Check(gameEvents.Ratio > 2.6, Equals, true)
+	c.Check(gameEvents.Compression, DeepEquals, []byte{CompressBzip2})
+}
+
+func (s *StatsSuite) TestStatsSlackAndOrphans(c *C) {
+	reader, err := os.Open("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	mpq, err := NewMpq(reader)
+	c.Assert(err, IsNil)
+
+	// Drop the hash entry for one block and free another
+	mpq.files["replay.message.events"].hash.BlockIndex = hashEntryDeleted
+	loadInfo := mpq.BlockEntries[4]
+	loadInfo.Flags = 0
+
+	stats := mpq.Stats()
+	c.Check(stats.Files, HasLen, 9)
+	c.Check(stats.HashUsed, Equals, 9)
+	c.Check(stats.HashDeleted, Equals, 1)
+	c.Check(stats.OrphanedBlocks, DeepEquals, []int{3})
+	c.Check(stats.Files[3].Orphaned, Equals, true)
+	c.Check(stats.Slack, DeepEquals, []Slack{{loadInfo.FilePosition, 96}})
+	c.Check(stats.SlackSize, Equals, uint64(96))
+}
+
+func (s *StatsSuite) TestCompressionName(c *C) {
+	c.Check(CompressionName(CompressBzip2), Equals, "bzip2")
+	c.Check(CompressionName(CompressZlib|CompressHuffman), Equals, "0x03")
+}
+
+func (s *StatsSuite) TestStatsJson(c *C) {
+	file := &FileStats{Filename: "file", Compression: []byte{CompressNone, CompressBzip2}}
+	data, err := json.Marshal(file)
+	c.Assert(err, IsNil)
+
+	decoded := make(map[string]interface{})
+	c.Assert(json.Unmarshal(data, &decoded), IsNil)
+	c.Check(decoded["filename"], Equals, "file")
+	c.Check(decoded["compression"], DeepEquals, []interface{}{float64(0), float64(16)})
+}
@@ -0,0 +1,153 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// DefaultMemoryThreshold is a reasonable threshold to pass to
+// NewMpqFromReader for archives the size of a typical replay or map.
+const DefaultMemoryThreshold int64 = 32 * 1024 * 1024
+
+// tempFile removes the file it wraps when it's closed.
+type tempFile struct {
+	*os.File
+}
+
+func (file *tempFile) Close() (err error) {
+	err = file.File.Close()
+	removeErr := os.Remove(file.Name())
+	if err == nil {
+		err = removeErr
+	}
+
+	return
+}
+
+// NewMpqFromReader reads an MPQ from a reader that can't seek, such as
+// an HTTP request body or an entry in a tar stream.  Archives up to
+// threshold bytes are buffered in memory and larger ones are spilled to
+// a temporary file, which is removed when the Mpq is closed.
+func NewMpqFromReader(reader io.Reader, threshold int64) (mpq *Mpq, err error) {
+	return NewMpqFromReaderWithLimits(reader, threshold, DefaultLimits)
+}
+
+// NewMpqFromReaderWithLimits reads an MPQ the same way as
+// NewMpqFromReader but with the given limits.  MaxStreamSize caps how
+// much of the reader is buffered or spilled to disk.
+func NewMpqFromReaderWithLimits(reader io.Reader, threshold int64,
+	limits Limits) (mpq *Mpq, err error) {
+	var limited *io.LimitedReader
+	if limits.MaxStreamSize > 0 {
+		// One byte over the limit tells a stream at the limit from a
+		// longer one
+		limited = &io.LimitedReader{R: reader, N: int64(limits.MaxStreamSize) + 1}
+		reader = limited
+	}
+	checkStream := func() error {
+		if limited != nil && limited.N == 0 {
+			return &LimitError{
+				Kind:  LimitStreamSize,
+				Value: float64(limits.MaxStreamSize + 1),
+				Max:   float64(limits.MaxStreamSize),
+			}
+		}
+		return nil
+	}
+
+	buffer := new(bytes.Buffer)
+	_, err = io.CopyN(buffer, reader, threshold+1)
+	if err == io.EOF {
+		err = checkStream()
+		if err != nil {
+			return nil, err
+		}
+		return NewMpqFromBytesWithLimits(buffer.Bytes(), limits)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ioutil.TempFile("", "zamara")
+	if err != nil {
+		return nil, err
+	}
+	closer := &tempFile{file}
+
+	_, err = io.Copy(file, buffer)
+	if err == nil {
+		_, err = io.Copy(file, reader)
+	}
+	if err == nil {
+		err = checkStream()
+	}
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+
+	mpq, err = NewMpqWithLimits(file, limits)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	mpq.closer = closer
+
+	return mpq, nil
+}
+
+// NewMpqFromBytes reads an MPQ that's already in memory.  The data
+// isn't copied, so it must not be modified while the Mpq is in use.
+func NewMpqFromBytes(data []byte) (mpq *Mpq, err error) {
+	return NewMpqFromBytesWithLimits(data, DefaultLimits)
+}
+
+// NewMpqFromBytesWithLimits reads an MPQ that's already in memory with
+// the given limits.
+func NewMpqFromBytesWithLimits(data []byte, limits Limits) (mpq *Mpq, err error) {
+	return NewMpqWithLimits(bytes.NewReader(data), limits)
+}
+
+// Close releases anything the Mpq opened itself, such as the temporary
+// file used by NewMpqFromReader.  Readers passed to NewMpq are left
+// for the caller to close.
+func (mpq *Mpq) Close() (err error) {
+	if mpq.closer == nil {
+		return nil
+	}
+
+	err = mpq.closer.Close()
+	mpq.closer = nil
+
+	return
+}
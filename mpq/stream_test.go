@@ -0,0 +1,136 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type StreamSuite struct{}
+
+var _ = Suite(&StreamSuite{})
+
+// onlyReader hides everything but Read so the MPQ can't seek on it
+type onlyReader struct {
+	reader io.Reader
+}
+
+func (r *onlyReader) Read(p []byte) (n int, err error) {
+	return r.reader.Read(p)
+}
+
+func readTestReplay(c *C) []byte {
+	data, err := ioutil.ReadFile("testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	return data
+}
+
+func (s *StreamSuite) TestNewMpqFromBytes(c *C) {
+	mpq, err := NewMpqFromBytes(readTestReplay(c))
+	c.Assert(err, IsNil)
+	defer mpq.Close()
+
+	c.Check(len(mpq.Files()), Equals, len(expectedFiles))
+	data, err := mpq.ReadFile("replay.details")
+	c.Assert(err, IsNil)
+	c.Check(len(data), Equals, 593)
+}
+
+func (s *StreamSuite) TestNewMpqFromReaderInMemory(c *C) {
+	reader := &onlyReader{bytes.NewReader(readTestReplay(c))}
+	mpq, err := NewMpqFromReader(reader, DefaultMemoryThreshold)
+	c.Assert(err, IsNil)
+
+	c.Check(mpq.closer, IsNil)
+	c.Check(len(mpq.Files()), Equals, len(expectedFiles))
+	c.Check(mpq.Close(), IsNil)
+}
+
+func (s *StreamSuite) TestNewMpqFromReaderSpilled(c *C) {
+	reader := &onlyReader{bytes.NewReader(readTestReplay(c))}
+	mpq, err := NewMpqFromReader(reader, 1024)
+	c.Assert(err, IsNil)
+
+	c.Assert(mpq.closer, NotNil)
+	tempName := mpq.closer.(*tempFile).Name()
+	_, err = os.Stat(tempName)
+	c.Check(err, IsNil)
+
+	data, err := mpq.ReadFile("replay.game.events")
+	c.Assert(err, IsNil)
+	c.Check(len(data), Equals, 266269)
+
+	c.Check(mpq.Close(), IsNil)
+	_, err = os.Stat(tempName)
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *StreamSuite) TestNewMpqFromReaderNotAnMpq(c *C) {
+	reader := &onlyReader{bytes.NewReader(make([]byte, 4096))}
+	mpq, err := NewMpqFromReader(reader, 1024)
+	c.Check(err, NotNil)
+	c.Check(mpq, IsNil)
+}
+
+func (s *StreamSuite) TestNewMpqFromReaderStreamLimit(c *C) {
+	data := readTestReplay(c)
+
+	// Both the buffered and the spilled stream stop at the limit
+	for _, threshold := range []int64{DefaultMemoryThreshold, 1024} {
+		reader := &onlyReader{bytes.NewReader(data)}
+		mpq, err := NewMpqFromReaderWithLimits(reader, threshold,
+			Limits{MaxStreamSize: uint64(len(data) - 1)})
+		c.Check(mpq, IsNil)
+		c.Assert(err, FitsTypeOf, &LimitError{})
+		c.Check(err.(*LimitError).Kind, Equals, LimitStreamSize)
+		c.Check(err.(*LimitError).Max, Equals, float64(len(data)-1))
+	}
+
+	reader := &onlyReader{bytes.NewReader(data)}
+	mpq, err := NewMpqFromReaderWithLimits(reader, 1024,
+		Limits{MaxStreamSize: uint64(len(data)), MaxFileSize: 2048})
+	c.Assert(err, IsNil)
+	defer mpq.Close()
+
+	// The other limits apply to the archive that was read
+	_, err = mpq.ReadFile("replay.game.events")
+	c.Assert(err, FitsTypeOf, &LimitError{})
+	c.Check(err.(*LimitError).Kind, Equals, LimitFileSize)
+}
+
+func (s *StreamSuite) TestNewMpqFromBytesWithLimits(c *C) {
+	mpq, err := NewMpqFromBytesWithLimits(readTestReplay(c), Limits{MaxEntries: 8})
+	c.Check(mpq, IsNil)
+	c.Assert(err, FitsTypeOf, &LimitError{})
+	c.Check(err.(*LimitError).Kind, Equals, LimitEntries)
+}
@@ -34,15 +34,19 @@ import (
 )
 
 type UserDataHeader struct {
-	XMLName xml.Name `xml:"userDataHeader"`
+	XMLName xml.Name `xml:"userDataHeader" json:"-"`
 
-	MaxUserDataSize uint32 `xml:"maxUserDataSize"`
-	ArchiveOffset   uint32 `xml:"archiveOffset"`
-	UserDataSize    uint32 `xml:"userDataSize"`
+	MaxUserDataSize uint32 `xml:"maxUserDataSize" json:"maxUserDataSize"`
+	ArchiveOffset   uint32 `xml:"archiveOffset" json:"archiveOffset"`
+	UserDataSize    uint32 `xml:"userDataSize" json:"userDataSize"`
 }
 
 type UserData struct {
-	Header *UserDataHeader `xml:"userDataHeader"`
+	Header *UserDataHeader `xml:"userDataHeader" json:"userDataHeader"`
+
+	// Content is the data stored after the user data header, StarCraft
+	// II keeps its replay header here.
+	Content []byte `xml:"-" json:"-"`
 }
 
 func readUserData(data []byte) (readData *UserData) {
@@ -53,5 +57,11 @@ func readUserData(data []byte) (readData *UserData) {
 	readData.Header.ArchiveOffset = binary.LittleEndian.Uint32(data[0x08 : 0x08+4])
 	readData.Header.UserDataSize = binary.LittleEndian.Uint32(data[0x0c : 0x0c+4])
 
+	end := 0x10 + int(readData.Header.UserDataSize)
+	if end > len(data) {
+		end = len(data)
+	}
+	readData.Content = data[0x10:end]
+
 	return
 }
@@ -0,0 +1,243 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Block indexes with special meaning in the hash table
+const (
+	hashEntryEmpty   uint32 = 0xFFFFFFFF
+	hashEntryDeleted uint32 = 0xFFFFFFFE
+)
+
+// Writer creates a new MPQ archive.  Files are kept in memory as
+// they're added and the archive is written out when Close is called.
+type Writer struct {
+	writer io.Writer
+
+	userData    []byte
+	blockSize   uint16
+	compression byte
+
+	files []*writerFile
+	names map[string]bool
+}
+
+type writerFile struct {
+	filename string
+	data     []byte // Data as it's stored in the archive
+
+	fileSize uint32
+	flags    uint32
+	language uint16
+	platform uint16
+}
+
+func NewWriter(writer io.Writer) (w *Writer) {
+	w = new(Writer)
+
+	w.writer = writer
+	w.blockSize = 3
+	w.names = make(map[string]bool)
+
+	return
+}
+
+// SetUserData stores content in a user data block in front of the
+// archive, the way StarCraft II stores the replay header.
+func (w *Writer) SetUserData(content []byte) {
+	maxSize := roundUp(uint32(len(content)), 512)
+	if maxSize == 0 {
+		maxSize = 512
+	}
+	archiveOffset := roundUp(16+maxSize, 512)
+
+	w.userData = make([]byte, archiveOffset)
+	copy(w.userData, "MPQ\x1b")
+	binary.LittleEndian.PutUint32(w.userData[0x04:0x04+4], maxSize)
+	binary.LittleEndian.PutUint32(w.userData[0x08:0x08+4], archiveOffset)
+	binary.LittleEndian.PutUint32(w.userData[0x0c:0x0c+4], uint32(len(content)))
+	copy(w.userData[0x10:], content)
+}
+
+// SetCompression sets the compression used for files added after
+// it's called.  Files are stored uncompressed by default.
+func (w *Writer) SetCompression(mask byte) (err error) {
+	if mask != CompressNone {
+		if _, found := LookupCompressor(mask); !found {
+			return fmt.Errorf("No compressor for compression %v",
+				CompressionName(mask))
+		}
+	}
+	w.compression = mask
+
+	return nil
+}
+
+// AddFile adds a file to the archive using the neutral locale.
+func (w *Writer) AddFile(filename string, data []byte) (err error) {
+	return w.AddFileLocale(filename, data, 0, 0)
+}
+
+// AddFileLocale adds a file to the archive for the given language and
+// platform.  Files that don't get any smaller when compressed are
+// stored as they are.
+func (w *Writer) AddFileLocale(filename string, data []byte,
+	language uint16, platform uint16) (err error) {
+	flags := FlagExists
+	stored := data
+
+	if w.compression != CompressNone {
+		compressor, _ := LookupCompressor(w.compression)
+		compressed, err := compressor(data)
+		if err != nil {
+			return fmt.Errorf("Unable to compress %v: %v", filename, err)
+		}
+		if len(compressed)+1 < len(data) {
+			stored = append([]byte{w.compression}, compressed...)
+			flags |= FlagCompress | FlagSingleUnit
+		}
+	}
+
+	return w.addBlock(filename, stored, uint32(len(data)), flags, language, platform)
+}
+
+// addBlock adds a file whose data is already in the form it's stored
+// in the archive, such as a block copied from another MPQ.
+func (w *Writer) addBlock(filename string, data []byte, fileSize uint32,
+	flags uint32, language uint16, platform uint16) (err error) {
+	key := fmt.Sprintf("%v:%v", strings.ToUpper(filename), language)
+	if w.names[key] {
+		return fmt.Errorf("File already added: %v", filename)
+	}
+	w.names[key] = true
+
+	w.files = append(w.files, &writerFile{
+		filename: filename,
+		data:     data,
+		fileSize: fileSize,
+		flags:    flags,
+		language: language,
+		platform: platform,
+	})
+
+	return nil
+}
+
+// Close writes the archive.  It doesn't close the underlying writer.
+func (w *Writer) Close() (err error) {
+	const headerSize = 32
+
+	blockEntries := uint32(len(w.files))
+	hashEntries := hashTableSize(blockEntries)
+
+	blockTable := make([]byte, blockEntries*16)
+	position := uint32(headerSize)
+	for idx, file := range w.files {
+		entry := blockTable[idx*16 : idx*16+16]
+		binary.LittleEndian.PutUint32(entry[:4], position)
+		binary.LittleEndian.PutUint32(entry[0x04:0x04+4], uint32(len(file.data)))
+		binary.LittleEndian.PutUint32(entry[0x08:0x08+4], file.fileSize)
+		binary.LittleEndian.PutUint32(entry[0x0C:0x0C+4], file.flags)
+		position += uint32(len(file.data))
+	}
+
+	hashTable := make([]byte, hashEntries*16)
+	for idx := range hashTable {
+		hashTable[idx] = 0xFF
+	}
+	for idx, file := range w.files {
+		// Files go in the first free slot at or after the slot
+		// their name hashes to, wrapping around at the end.
+		slot := hashString(file.filename, 0x000) & (hashEntries - 1)
+		for binary.LittleEndian.Uint32(hashTable[slot*16+0x0C:slot*16+0x0C+4]) != hashEntryEmpty {
+			slot = (slot + 1) & (hashEntries - 1)
+		}
+
+		entry := hashTable[slot*16 : slot*16+16]
+		binary.LittleEndian.PutUint32(entry[:4], hashString(file.filename, 0x100))
+		binary.LittleEndian.PutUint32(entry[0x04:0x04+4], hashString(file.filename, 0x200))
+		binary.LittleEndian.PutUint16(entry[0x08:0x08+2], file.language)
+		binary.LittleEndian.PutUint16(entry[0x0A:0x0A+2], file.platform)
+		binary.LittleEndian.PutUint32(entry[0x0C:0x0C+4], uint32(idx))
+	}
+
+	newBlockEncryptor("(hash table)", 0x300).encrypt(&hashTable)
+	newBlockEncryptor("(block table)", 0x300).encrypt(&blockTable)
+
+	hashTableOffset := position
+	blockTableOffset := hashTableOffset + hashEntries*16
+	archiveSize := blockTableOffset + blockEntries*16
+
+	header := make([]byte, headerSize)
+	copy(header, "MPQ\x1a")
+	binary.LittleEndian.PutUint32(header[0x04:0x04+4], headerSize)
+	binary.LittleEndian.PutUint32(header[0x08:0x08+4], archiveSize)
+	binary.LittleEndian.PutUint16(header[0x0C:0x0C+2], 0)
+	binary.LittleEndian.PutUint16(header[0x0E:0x0E+2], w.blockSize)
+	binary.LittleEndian.PutUint32(header[0x10:0x10+4], hashTableOffset)
+	binary.LittleEndian.PutUint32(header[0x14:0x14+4], blockTableOffset)
+	binary.LittleEndian.PutUint32(header[0x18:0x18+4], hashEntries)
+	binary.LittleEndian.PutUint32(header[0x1C:0x1C+4], blockEntries)
+
+	parts := [][]byte{w.userData, header}
+	for _, file := range w.files {
+		parts = append(parts, file.data)
+	}
+	parts = append(parts, hashTable, blockTable)
+
+	for _, part := range parts {
+		_, err = w.writer.Write(part)
+		if err != nil {
+			return
+		}
+	}
+
+	return nil
+}
+
+// hashTableSize returns the smallest power of two that leaves at
+// least one slot in the hash table empty.
+func hashTableSize(files uint32) (size uint32) {
+	size = 16
+	for size <= files {
+		size <<= 1
+	}
+
+	return
+}
+
+func roundUp(value uint32, multiple uint32) uint32 {
+	return (value + multiple - 1) / multiple * multiple
+}
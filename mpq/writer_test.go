@@ -0,0 +1,80 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mpq
+
+import (
+	"bytes"
+	. "launchpad.net/gocheck"
+)
+
+type WriterSuite struct{}
+
+var _ = Suite(&WriterSuite{})
+
+func (s *WriterSuite) TestWriteArchive(c *C) {
+	buffer := new(bytes.Buffer)
+	w := NewWriter(buffer)
+	c.Assert(w.AddFile("first.txt", []byte("first file")), IsNil)
+	c.Assert(w.AddFile("Dir\\second.txt", []byte("second file")), IsNil)
+	c.Assert(w.AddFile("(listfile)", []byte("first.txt\r\nDir\\second.txt\r\n")), IsNil)
+	c.Check(w.AddFile("FIRST.TXT", []byte{}), NotNil)
+	c.Assert(w.Close(), IsNil)
+
+	mpq, err := NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+	c.Check(mpq.HasUserData, Equals, false)
+	c.Check(mpq.Header.HashTableEntries, Equals, uint32(16))
+	c.Check(mpq.Header.BlockTableEntries, Equals, uint32(3))
+	c.Check(mpq.Files(), HasLen, 3)
+
+	data, err := mpq.ReadFile("Dir\\second.txt")
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "second file")
+
+	c.Check(mpq.Check().Problems, HasLen, 0)
+}
+
+func (s *WriterSuite) TestWriteUserData(c *C) {
+	buffer := new(bytes.Buffer)
+	w := NewWriter(buffer)
+	w.SetUserData([]byte("user data"))
+	c.Assert(w.AddFile("file", []byte("contents")), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	mpq, err := NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+	c.Check(mpq.HasUserData, Equals, true)
+	c.Check(mpq.UserData.Header.MaxUserDataSize, Equals, uint32(512))
+	c.Check(mpq.UserData.Header.ArchiveOffset, Equals, uint32(1024))
+	c.Check(mpq.UserData.Header.UserDataSize, Equals, uint32(9))
+
+	data, err := mpq.ReadFile("file")
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "contents")
+}
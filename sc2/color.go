@@ -29,8 +29,8 @@
 package sc2
 
 type Color struct {
-	A int
-	R int
-	G int
-	B int
+	A int `xml:"a" json:"a"`
+	R int `xml:"r" json:"r"`
+	G int `xml:"g" json:"g"`
+	B int `xml:"b" json:"b"`
 }
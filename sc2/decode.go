@@ -0,0 +1,109 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// SerializedField is one member of a keyed structure in a serialized
+// blob.
+type SerializedField struct {
+	Key   int64
+	Value interface{}
+}
+
+// SerializedStruct is a keyed structure from a serialized blob, in the
+// order its fields were stored.  It encodes to a JSON object.
+type SerializedStruct []SerializedField
+
+func (fields SerializedStruct) MarshalJSON() ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("{")
+	for idx, field := range fields {
+		if idx > 0 {
+			buffer.WriteString(",")
+		}
+		fmt.Fprintf(buffer, "\"%v\":", field.Key)
+
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(value)
+	}
+	buffer.WriteString("}")
+
+	return buffer.Bytes(), nil
+}
+
+// DecodeSerialized parses a serialized blob such as replay.details or
+// replay.initData's lobby state into plain values: strings become
+// string, integers int64, arrays []interface{}, keyed structures and
+// choices SerializedStruct, bit arrays BitArray and missing optional
+// values nil.
+func DecodeSerialized(data []byte) (decoded interface{}, err error) {
+	value, _, err := newSerializedValue(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode serialized data: %v", err)
+	}
+
+	return value.decode(), nil
+}
+
+func (value *serializedValue) decode() interface{} {
+	switch value.valueType {
+	case ValueString:
+		return value.stringValue
+	case ValueArray:
+		items := make([]interface{}, len(value.members))
+		for idx, member := range value.members {
+			items[idx] = member.decode()
+		}
+		return items
+	case ValueBitArray:
+		return BitArray{
+			Length: int(value.intValue),
+			Bits:   new(big.Int).SetBytes([]byte(value.stringValue)),
+		}
+	case ValueOptional:
+		return nil
+	case ValueKey, ValueChoice:
+		fields := make(SerializedStruct, len(value.members))
+		for idx, member := range value.members {
+			fields[idx] = SerializedField{value.keys[idx], member.decode()}
+		}
+		return fields
+	}
+
+	return value.intValue
+}
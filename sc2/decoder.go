@@ -0,0 +1,695 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+var Truncated error = errors.New("Data ended unexpectedly")
+
+// Kinds of type info.
+const (
+	TypeArray    = "array"
+	TypeBitArray = "bitarray"
+	TypeBlob     = "blob"
+	TypeBool     = "bool"
+	TypeChoice   = "choice"
+	TypeFourcc   = "fourcc"
+	TypeInt      = "int"
+	TypeNull     = "null"
+	TypeOptional = "optional"
+	TypeReal32   = "real32"
+	TypeReal64   = "real64"
+	TypeStruct   = "struct"
+)
+
+// ParentField is the name of a struct field whose members are merged
+// into the struct itself.
+const ParentField = "__parent"
+
+// TypeInfo describes one type in a protocol's type table.  Bounds holds
+// the minimum value and the number of bits of ints and of the lengths
+// of arrays, bit arrays, blobs and choice tags.  Type is the element
+// type of arrays and optionals.  Fields are the members of structs and
+// the options of choices.
+type TypeInfo struct {
+	Kind   string      `json:"kind"`
+	Bounds [2]int64    `json:"bounds"`
+	Type   int         `json:"type"`
+	Fields []TypeField `json:"fields"`
+}
+
+// TypeField is a member of a struct or an option of a choice.
+type TypeField struct {
+	Name string `json:"name"`
+	Type int    `json:"type"`
+	Tag  int64  `json:"tag"`
+}
+
+// Struct is a decoded struct, keyed by field name.  A decoded choice is
+// a Struct holding only the chosen field.
+type Struct map[string]interface{}
+
+// BitArray is a decoded bit array, bit 0 is the first bit of the array.
+type BitArray struct {
+	Length int      `json:"length"`
+	Bits   *big.Int `json:"bits"`
+}
+
+// Bit reports whether a bit of the array is set.
+func (bits BitArray) Bit(index int) bool {
+	return bits.Bits != nil && bits.Bits.Bit(index) != 0
+}
+
+// A Decoder reads values described by a type table from a stream.
+// Decoded values are int64, bool, []byte for blobs, string for four
+// character codes, float32, float64, []interface{}, BitArray, Struct or
+// nil for missing optionals.
+type Decoder interface {
+	Instance(typeId int) (value interface{}, err error)
+	ByteAlign()
+	Done() bool
+	UsedBits() int
+}
+
+// bitBuffer reads bits most significant first from each byte.
+type bitBuffer struct {
+	data     []byte
+	used     int
+	next     byte
+	nextBits uint
+}
+
+func (buffer *bitBuffer) done() bool {
+	return buffer.nextBits == 0 && buffer.used >= len(buffer.data)
+}
+
+func (buffer *bitBuffer) usedBits() int {
+	return buffer.used*8 - int(buffer.nextBits)
+}
+
+func (buffer *bitBuffer) byteAlign() {
+	buffer.nextBits = 0
+}
+
+func (buffer *bitBuffer) readAlignedBytes(count int) (data []byte, err error) {
+	buffer.byteAlign()
+	if count < 0 || buffer.used+count > len(buffer.data) {
+		return nil, Truncated
+	}
+	data = buffer.data[buffer.used : buffer.used+count]
+	buffer.used += count
+
+	return data, nil
+}
+
+// readBits reads up to 64 bits, earlier bits are more significant.
+func (buffer *bitBuffer) readBits(bits uint) (result uint64, err error) {
+	resultBits := uint(0)
+	for resultBits != bits {
+		if buffer.nextBits == 0 {
+			if buffer.done() {
+				return 0, Truncated
+			}
+			buffer.next = buffer.data[buffer.used]
+			buffer.used++
+			buffer.nextBits = 8
+		}
+
+		copyBits := bits - resultBits
+		if buffer.nextBits < copyBits {
+			copyBits = buffer.nextBits
+		}
+		copied := uint64(buffer.next & byte((1<<copyBits)-1))
+		result |= copied << (bits - resultBits - copyBits)
+		buffer.next >>= copyBits
+		buffer.nextBits -= copyBits
+		resultBits += copyBits
+	}
+
+	return result, nil
+}
+
+func (buffer *bitBuffer) readUnalignedBytes(count int) (data []byte, err error) {
+	data = make([]byte, count)
+	for idx := range data {
+		value, err := buffer.readBits(8)
+		if err != nil {
+			return nil, err
+		}
+		data[idx] = byte(value)
+	}
+
+	return data, nil
+}
+
+func lookupType(typeInfos []TypeInfo, typeId int) (info *TypeInfo, err error) {
+	if typeId < 0 || typeId >= len(typeInfos) {
+		return nil, fmt.Errorf("Unknown type info: %v", typeId)
+	}
+
+	return &typeInfos[typeId], nil
+}
+
+// BitPackedDecoder reads the bit packed encoding used by event streams
+// and initData.
+type BitPackedDecoder struct {
+	buffer    bitBuffer
+	typeInfos []TypeInfo
+}
+
+func NewBitPackedDecoder(data []byte, typeInfos []TypeInfo) (decoder *BitPackedDecoder) {
+	decoder = new(BitPackedDecoder)
+	decoder.buffer.data = data
+	decoder.typeInfos = typeInfos
+
+	return decoder
+}
+
+func (decoder *BitPackedDecoder) ByteAlign() {
+	decoder.buffer.byteAlign()
+}
+
+func (decoder *BitPackedDecoder) Done() bool {
+	return decoder.buffer.done()
+}
+
+func (decoder *BitPackedDecoder) UsedBits() int {
+	return decoder.buffer.usedBits()
+}
+
+// ReadBits reads an unsigned value of up to 64 bits outside of the
+// type table.
+func (decoder *BitPackedDecoder) ReadBits(bits uint) (value uint64, err error) {
+	if bits > 64 {
+		return 0, fmt.Errorf("Unable to read %v bits at once", bits)
+	}
+
+	return decoder.buffer.readBits(bits)
+}
+
+func (decoder *BitPackedDecoder) Instance(typeId int) (value interface{}, err error) {
+	info, err := lookupType(decoder.typeInfos, typeId)
+	if err != nil {
+		return nil, err
+	}
+
+	switch info.Kind {
+	case TypeArray:
+		length, err := decoder.readInt(info.Bounds)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, 0, boundedLength(length))
+		for idx := int64(0); idx < length; idx++ {
+			item, err := decoder.Instance(info.Type)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case TypeBitArray:
+		length, err := decoder.readInt(info.Bounds)
+		if err != nil {
+			return nil, err
+		}
+		bits := new(big.Int)
+		for remaining := length; remaining > 0; remaining -= 64 {
+			count := uint(64)
+			if remaining < 64 {
+				count = uint(remaining)
+			}
+			chunk, err := decoder.buffer.readBits(count)
+			if err != nil {
+				return nil, err
+			}
+			bits.Lsh(bits, count)
+			bits.Or(bits, new(big.Int).SetUint64(chunk))
+		}
+		return BitArray{Length: int(length), Bits: bits}, nil
+	case TypeBlob:
+		length, err := decoder.readInt(info.Bounds)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.buffer.readAlignedBytes(int(length))
+	case TypeBool:
+		value, err := decoder.buffer.readBits(1)
+		return value != 0, err
+	case TypeChoice:
+		tag, err := decoder.readInt(info.Bounds)
+		if err != nil {
+			return nil, err
+		}
+		field := info.field(tag)
+		if field == nil {
+			return nil, fmt.Errorf("Unknown choice tag %v for type info %v", tag, typeId)
+		}
+		value, err := decoder.Instance(field.Type)
+		if err != nil {
+			return nil, err
+		}
+		return Struct{field.Name: value}, nil
+	case TypeFourcc:
+		data, err := decoder.buffer.readUnalignedBytes(4)
+		return string(data), err
+	case TypeInt:
+		return decoder.readInt(info.Bounds)
+	case TypeNull:
+		return nil, nil
+	case TypeOptional:
+		exists, err := decoder.buffer.readBits(1)
+		if err != nil || exists == 0 {
+			return nil, err
+		}
+		return decoder.Instance(info.Type)
+	case TypeReal32:
+		data, err := decoder.buffer.readUnalignedBytes(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(data)), nil
+	case TypeReal64:
+		data, err := decoder.buffer.readUnalignedBytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case TypeStruct:
+		result := Struct{}
+		for _, field := range info.Fields {
+			value, err := decoder.Instance(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			if field.Name == ParentField {
+				value, merged := mergeParent(result, value, len(info.Fields))
+				if !merged {
+					return value, nil
+				}
+				continue
+			}
+			result[field.Name] = value
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("Unknown type info kind: %v", info.Kind)
+}
+
+func (decoder *BitPackedDecoder) readInt(bounds [2]int64) (value int64, err error) {
+	if bounds[1] < 0 || bounds[1] > 64 {
+		return 0, fmt.Errorf("Invalid int bounds: %v", bounds)
+	}
+
+	bits, err := decoder.buffer.readBits(uint(bounds[1]))
+	return bounds[0] + int64(bits), err
+}
+
+// VersionedDecoder reads the self describing encoding used by the
+// replay header and details.  Struct fields missing from the type table
+// are skipped, so one table can read newer data.
+type VersionedDecoder struct {
+	buffer    bitBuffer
+	typeInfos []TypeInfo
+}
+
+func NewVersionedDecoder(data []byte, typeInfos []TypeInfo) (decoder *VersionedDecoder) {
+	decoder = new(VersionedDecoder)
+	decoder.buffer.data = data
+	decoder.typeInfos = typeInfos
+
+	return decoder
+}
+
+func (decoder *VersionedDecoder) ByteAlign() {
+	decoder.buffer.byteAlign()
+}
+
+func (decoder *VersionedDecoder) Done() bool {
+	return decoder.buffer.done()
+}
+
+func (decoder *VersionedDecoder) UsedBits() int {
+	return decoder.buffer.usedBits()
+}
+
+func (decoder *VersionedDecoder) Instance(typeId int) (value interface{}, err error) {
+	info, err := lookupType(decoder.typeInfos, typeId)
+	if err != nil {
+		return nil, err
+	}
+
+	switch info.Kind {
+	case TypeArray:
+		length, err := decoder.expectLength(ValueArray)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, 0, boundedLength(length))
+		for idx := int64(0); idx < length; idx++ {
+			item, err := decoder.Instance(info.Type)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case TypeBitArray:
+		length, err := decoder.expectLength(ValueBitArray)
+		if err != nil {
+			return nil, err
+		}
+		data, err := decoder.buffer.readAlignedBytes(int((length + 7) / 8))
+		if err != nil {
+			return nil, err
+		}
+		return BitArray{Length: int(length), Bits: new(big.Int).SetBytes(data)}, nil
+	case TypeBlob:
+		length, err := decoder.expectLength(ValueString)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.buffer.readAlignedBytes(int(length))
+	case TypeBool:
+		err = decoder.expect(ValueInt8)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decoder.buffer.readBits(8)
+		return value != 0, err
+	case TypeChoice:
+		tag, err := decoder.expectLength(ValueChoice)
+		if err != nil {
+			return nil, err
+		}
+		field := info.field(tag)
+		if field == nil {
+			return Struct{}, decoder.skipInstance()
+		}
+		value, err := decoder.Instance(field.Type)
+		if err != nil {
+			return nil, err
+		}
+		return Struct{field.Name: value}, nil
+	case TypeFourcc:
+		err = decoder.expect(ValueInt32)
+		if err != nil {
+			return nil, err
+		}
+		data, err := decoder.buffer.readAlignedBytes(4)
+		return string(data), err
+	case TypeInt:
+		err = decoder.expect(ValueIntVlf)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.readVint()
+	case TypeNull:
+		return nil, nil
+	case TypeOptional:
+		err = decoder.expect(ValueOptional)
+		if err != nil {
+			return nil, err
+		}
+		exists, err := decoder.buffer.readBits(8)
+		if err != nil || exists == 0 {
+			return nil, err
+		}
+		return decoder.Instance(info.Type)
+	case TypeReal32:
+		err = decoder.expect(ValueInt32)
+		if err != nil {
+			return nil, err
+		}
+		data, err := decoder.buffer.readAlignedBytes(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(data)), nil
+	case TypeReal64:
+		err = decoder.expect(ValueInt64)
+		if err != nil {
+			return nil, err
+		}
+		data, err := decoder.buffer.readAlignedBytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case TypeStruct:
+		length, err := decoder.expectLength(ValueKey)
+		if err != nil {
+			return nil, err
+		}
+		result := Struct{}
+		for idx := int64(0); idx < length; idx++ {
+			tag, err := decoder.readVint()
+			if err != nil {
+				return nil, err
+			}
+			field := info.field(tag)
+			if field == nil {
+				err = decoder.skipInstance()
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			value, err := decoder.Instance(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			if field.Name == ParentField {
+				value, merged := mergeParent(result, value, len(info.Fields))
+				if !merged {
+					return value, nil
+				}
+				continue
+			}
+			result[field.Name] = value
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("Unknown type info kind: %v", info.Kind)
+}
+
+func (decoder *VersionedDecoder) expect(tag byte) (err error) {
+	found, err := decoder.buffer.readBits(8)
+	if err != nil {
+		return err
+	}
+	if byte(found) != tag {
+		return fmt.Errorf("Expected type tag %v, found %v", tag, found)
+	}
+
+	return nil
+}
+
+// expectLength checks the type tag and reads the length or choice tag
+// that follows it.
+func (decoder *VersionedDecoder) expectLength(tag byte) (length int64, err error) {
+	err = decoder.expect(tag)
+	if err != nil {
+		return 0, err
+	}
+
+	length, err = decoder.readVint()
+	if err == nil && length < 0 && tag != ValueChoice {
+		err = fmt.Errorf("Negative length: %v", length)
+	}
+	return length, err
+}
+
+func (decoder *VersionedDecoder) readVint() (value int64, err error) {
+	b, err := decoder.buffer.readBits(8)
+	if err != nil {
+		return 0, err
+	}
+	negative := b&1 != 0
+	result := (b >> 1) & 0x3f
+	shift := uint(6)
+	for b&0x80 != 0 {
+		if shift > 63 {
+			return 0, fmt.Errorf("Variable length integer is too long")
+		}
+		b, err = decoder.buffer.readBits(8)
+		if err != nil {
+			return 0, err
+		}
+		result |= (b & 0x7f) << shift
+		shift += 7
+	}
+
+	if negative {
+		return -int64(result), nil
+	}
+	return int64(result), nil
+}
+
+// skipInstance steps over a value the type table doesn't describe.
+func (decoder *VersionedDecoder) skipInstance() (err error) {
+	tag, err := decoder.buffer.readBits(8)
+	if err != nil {
+		return err
+	}
+
+	switch byte(tag) {
+	case ValueArray:
+		length, err := decoder.readVint()
+		for idx := int64(0); err == nil && idx < length; idx++ {
+			err = decoder.skipInstance()
+		}
+		return err
+	case ValueBitArray:
+		length, err := decoder.readVint()
+		if err == nil {
+			_, err = decoder.buffer.readAlignedBytes(int((length + 7) / 8))
+		}
+		return err
+	case ValueString:
+		length, err := decoder.readVint()
+		if err == nil {
+			_, err = decoder.buffer.readAlignedBytes(int(length))
+		}
+		return err
+	case ValueChoice:
+		_, err = decoder.readVint()
+		if err == nil {
+			err = decoder.skipInstance()
+		}
+		return err
+	case ValueOptional:
+		exists, err := decoder.buffer.readBits(8)
+		if err == nil && exists != 0 {
+			err = decoder.skipInstance()
+		}
+		return err
+	case ValueKey:
+		length, err := decoder.readVint()
+		for idx := int64(0); err == nil && idx < length; idx++ {
+			_, err = decoder.readVint()
+			if err == nil {
+				err = decoder.skipInstance()
+			}
+		}
+		return err
+	case ValueInt8:
+		_, err = decoder.buffer.readAlignedBytes(1)
+	case ValueInt32:
+		_, err = decoder.buffer.readAlignedBytes(4)
+	case ValueInt64:
+		_, err = decoder.buffer.readAlignedBytes(8)
+	case ValueIntVlf:
+		_, err = decoder.readVint()
+	default:
+		err = fmt.Errorf("Unknown serialization type: %v", tag)
+	}
+
+	return err
+}
+
+func (info *TypeInfo) validate(typeCount int) (err error) {
+	checkType := func(typeId int) error {
+		if typeId < 0 || typeId >= typeCount {
+			return fmt.Errorf("unknown type %v", typeId)
+		}
+		return nil
+	}
+
+	switch info.Kind {
+	case TypeArray, TypeOptional:
+		return checkType(info.Type)
+	case TypeStruct, TypeChoice:
+		for _, field := range info.Fields {
+			err = checkType(field.Type)
+			if err != nil {
+				return
+			}
+		}
+	case TypeBitArray, TypeBlob, TypeBool, TypeFourcc, TypeInt, TypeNull,
+		TypeReal32, TypeReal64:
+	default:
+		return fmt.Errorf("unknown kind %v", info.Kind)
+	}
+
+	if info.Bounds[1] < 0 || info.Bounds[1] > 64 {
+		return fmt.Errorf("invalid bounds %v", info.Bounds)
+	}
+
+	return nil
+}
+
+func (info *TypeInfo) field(tag int64) *TypeField {
+	for idx := range info.Fields {
+		if info.Fields[idx].Tag == tag {
+			return &info.Fields[idx]
+		}
+	}
+
+	return nil
+}
+
+// mergeParent adds the fields of a decoded parent struct to result.  A
+// struct whose only field is its parent becomes the parent's value, in
+// which case merged is false and value is what to return.
+func mergeParent(result Struct, parent interface{}, fieldCount int) (value interface{}, merged bool) {
+	if fields, ok := parent.(Struct); ok {
+		for name, field := range fields {
+			result[name] = field
+		}
+		return result, true
+	}
+	if fieldCount == 1 {
+		return parent, false
+	}
+
+	result[ParentField] = parent
+	return result, true
+}
+
+// boundedLength caps the capacity reserved for a decoded length, the
+// length itself may be corrupt.
+func boundedLength(length int64) int {
+	if length > 1024 {
+		return 1024
+	}
+	if length < 0 {
+		return 0
+	}
+	return int(length)
+}
@@ -0,0 +1,283 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"github.com/aphistic/go.Zamara/mpq"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+)
+
+type DecoderSuite struct{}
+
+var _ = Suite(&DecoderSuite{})
+
+// bitWriter builds bit packed test data, the reverse of bitBuffer.
+type bitWriter struct {
+	data     []byte
+	next     byte
+	nextBits uint
+}
+
+func (writer *bitWriter) writeBits(value uint64, bits uint) {
+	for bits > 0 {
+		take := 8 - writer.nextBits
+		if bits < take {
+			take = bits
+		}
+		chunk := byte(value>>(bits-take)) & byte((1<<take)-1)
+		writer.next |= chunk << writer.nextBits
+		writer.nextBits += take
+		bits -= take
+		if writer.nextBits == 8 {
+			writer.align()
+		}
+	}
+}
+
+func (writer *bitWriter) align() {
+	if writer.nextBits > 0 {
+		writer.data = append(writer.data, writer.next)
+		writer.next = 0
+		writer.nextBits = 0
+	}
+}
+
+func (writer *bitWriter) writeBytes(data []byte) {
+	writer.align()
+	writer.data = append(writer.data, data...)
+}
+
+func (writer *bitWriter) bytes() []byte {
+	writer.align()
+	return writer.data
+}
+
+var testTypeInfos = []TypeInfo{
+	{Kind: TypeInt, Bounds: [2]int64{-4, 7}},           // 0
+	{Kind: TypeBlob, Bounds: [2]int64{0, 8}},           // 1
+	{Kind: TypeArray, Bounds: [2]int64{0, 4}, Type: 0}, // 2
+	{Kind: TypeOptional, Type: 0},                      // 3
+	{Kind: TypeChoice, Bounds: [2]int64{0, 2}, Fields: []TypeField{
+		{Name: "number", Type: 0, Tag: 0},
+		{Name: "text", Type: 1, Tag: 1},
+	}}, // 4
+	{Kind: TypeBool}, // 5
+	{Kind: TypeBitArray, Bounds: [2]int64{0, 8}}, // 6
+	{Kind: TypeFourcc},                           // 7
+	{Kind: TypeStruct, Fields: []TypeField{
+		{Name: "int", Type: 0, Tag: 0},
+		{Name: "blob", Type: 1, Tag: 1},
+		{Name: "array", Type: 2, Tag: 2},
+		{Name: "optional", Type: 3, Tag: 3},
+		{Name: "choice", Type: 4, Tag: 4},
+		{Name: "bool", Type: 5, Tag: 5},
+		{Name: "bits", Type: 6, Tag: 6},
+		{Name: "fourcc", Type: 7, Tag: 7},
+	}}, // 8
+	{Kind: TypeStruct, Fields: []TypeField{
+		{Name: ParentField, Type: 0, Tag: 0},
+	}}, // 9
+	{Kind: TypeNull}, // 10
+}
+
+func (s *DecoderSuite) checkStruct(c *C, value interface{}) {
+	result, ok := value.(Struct)
+	c.Assert(ok, Equals, true)
+	c.Check(result["int"], Equals, int64(3))
+	c.Check(result["blob"], DeepEquals, []byte("abc"))
+	c.Check(result["array"], DeepEquals, []interface{}{int64(-4), int64(100)})
+	c.Check(result["optional"], IsNil)
+	c.Check(result["choice"], DeepEquals, Struct{"text": []byte("x")})
+	c.Check(result["bool"], Equals, true)
+	bits := result["bits"].(BitArray)
+	c.Check(bits.Length, Equals, 10)
+	c.Check(bits.Bit(0), Equals, true)
+	c.Check(bits.Bit(1), Equals, false)
+	c.Check(bits.Bit(9), Equals, true)
+	c.Check(result["fourcc"], Equals, "S2MA")
+}
+
+func (s *DecoderSuite) TestReadBits(c *C) {
+	buffer := &bitBuffer{data: []byte{0xA5, 0xFF, 0x12}}
+
+	value, err := buffer.readBits(4)
+	c.Check(err, IsNil)
+	c.Check(value, Equals, uint64(0x5))
+	value, err = buffer.readBits(8)
+	c.Check(err, IsNil)
+	c.Check(value, Equals, uint64(0xAF))
+	c.Check(buffer.usedBits(), Equals, 12)
+
+	data, err := buffer.readAlignedBytes(1)
+	c.Check(err, IsNil)
+	c.Check(data, DeepEquals, []byte{0x12})
+	c.Check(buffer.done(), Equals, true)
+
+	_, err = buffer.readBits(1)
+	c.Check(err, Equals, Truncated)
+}
+
+func (s *DecoderSuite) TestBitPacked(c *C) {
+	writer := new(bitWriter)
+	writer.writeBits(7, 7) // int 3
+	writer.writeBits(3, 8) // blob length
+	writer.writeBytes([]byte("abc"))
+	writer.writeBits(2, 4)   // array length
+	writer.writeBits(0, 7)   // -4
+	writer.writeBits(104, 7) // 100
+	writer.writeBits(0, 1)   // optional missing
+	writer.writeBits(1, 2)   // choice text
+	writer.writeBits(1, 8)
+	writer.writeBytes([]byte("x"))
+	writer.writeBits(1, 1)           // bool
+	writer.writeBits(10, 8)          // bit array length
+	writer.writeBits(0x201, 10)      // bits 0 and 9
+	writer.writeBits(uint64('S'), 8) // fourcc
+	writer.writeBits(uint64('2'), 8)
+	writer.writeBits(uint64('M'), 8)
+	writer.writeBits(uint64('A'), 8)
+	data := writer.bytes()
+
+	decoder := NewBitPackedDecoder(data, testTypeInfos)
+	value, err := decoder.Instance(8)
+	c.Assert(err, IsNil)
+	s.checkStruct(c, value)
+	c.Check(decoder.Done(), Equals, false)
+	decoder.ByteAlign()
+	c.Check(decoder.Done(), Equals, true)
+
+	_, err = NewBitPackedDecoder(data[:len(data)-1], testTypeInfos).Instance(8)
+	c.Check(err, Equals, Truncated)
+
+	_, err = NewBitPackedDecoder(data, testTypeInfos).Instance(11)
+	c.Check(err, ErrorMatches, "Unknown type info: 11")
+}
+
+func (s *DecoderSuite) TestVersioned(c *C) {
+	data := []byte{
+		0x05, 0x14, // struct with 10 fields
+		0x00, 0x09, 0x06, // int 3
+		0x02, 0x02, 0x06, 'a', 'b', 'c', // blob
+		0x04, 0x00, 0x04, 0x09, 0x09, 0x09, 0xc8, 0x01, // array -4, 100
+		0x06, 0x04, 0x00, // optional missing
+		0x08, 0x03, 0x02, 0x02, 0x02, 'x', // choice text
+		0x0a, 0x06, 0x01, // bool
+		0x0c, 0x01, 0x14, 0x02, 0x01, // bits 0 and 9
+		0x0e, 0x07, 'S', '2', 'M', 'A', // fourcc
+		0x10, 0x02, 0x04, 'n', 'o', // unknown field 8
+		0x12, 0x05, 0x02, 0x00, 0x09, 0x02, // unknown struct field 9
+	}
+
+	decoder := NewVersionedDecoder(data, testTypeInfos)
+	value, err := decoder.Instance(8)
+	c.Assert(err, IsNil)
+	s.checkStruct(c, value)
+	c.Check(decoder.Done(), Equals, true)
+
+	_, err = NewVersionedDecoder(data[:len(data)-1], testTypeInfos).Instance(8)
+	c.Check(err, Equals, Truncated)
+
+	_, err = NewVersionedDecoder([]byte{0x02, 0x00}, testTypeInfos).Instance(0)
+	c.Check(err, ErrorMatches, "Expected type tag 9, found 2")
+}
+
+func (s *DecoderSuite) TestParentStruct(c *C) {
+	value, err := NewVersionedDecoder([]byte{0x05, 0x02, 0x00, 0x09, 0x04}, testTypeInfos).Instance(9)
+	c.Assert(err, IsNil)
+	c.Check(value, Equals, int64(2))
+
+	writer := new(bitWriter)
+	writer.writeBits(6, 7)
+	value, err = NewBitPackedDecoder(writer.bytes(), testTypeInfos).Instance(9)
+	c.Assert(err, IsNil)
+	c.Check(value, Equals, int64(2))
+}
+
+func (s *DecoderSuite) TestVersionedReplayHeader(c *C) {
+	typeInfos := []TypeInfo{
+		{Kind: TypeInt, Bounds: [2]int64{0, 32}},
+		{Kind: TypeBlob, Bounds: [2]int64{0, 8}},
+		{Kind: TypeStruct, Fields: []TypeField{
+			{Name: "m_major", Type: 0, Tag: 1},
+			{Name: "m_build", Type: 0, Tag: 4},
+			{Name: "m_baseBuild", Type: 0, Tag: 5},
+		}},
+		{Kind: TypeStruct, Fields: []TypeField{
+			{Name: "m_signature", Type: 1, Tag: 0},
+			{Name: "m_version", Type: 2, Tag: 1},
+			{Name: "m_elapsedGameLoops", Type: 0, Tag: 3},
+		}},
+	}
+
+	data, err := ioutil.ReadFile("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	archive, err := mpq.NewMpqFromBytes(data)
+	c.Assert(err, IsNil)
+
+	value, err := NewVersionedDecoder(archive.UserData.Content, typeInfos).Instance(3)
+	c.Assert(err, IsNil)
+	header := value.(Struct)
+	c.Check(header["m_signature"], DeepEquals, []byte("StarCraft II replay\x1b11"))
+	c.Check(header["m_version"], DeepEquals, Struct{
+		"m_major": int64(1), "m_build": int64(16195), "m_baseBuild": int64(15405),
+	})
+	c.Check(header["m_elapsedGameLoops"], Equals, int64(22571))
+}
+
+func (s *DecoderSuite) TestValidateTypeInfos(c *C) {
+	for id, info := range testTypeInfos {
+		c.Check(info.validate(len(testTypeInfos)), IsNil, Commentf("%v", id))
+	}
+
+	c.Check((&TypeInfo{Kind: TypeArray, Type: 5}).validate(5), ErrorMatches, "unknown type 5")
+	c.Check((&TypeInfo{Kind: "float"}).validate(5), ErrorMatches, "unknown kind float")
+	c.Check((&TypeInfo{Kind: TypeInt, Bounds: [2]int64{0, 65}}).validate(5), ErrorMatches, "invalid bounds .*")
+}
+
+func (s *DecoderSuite) TestSerializedAllTags(c *C) {
+	data := []byte{
+		0x05, 0x0a, // struct with 5 fields
+		0x00, 0x01, 0x14, 0x02, 0x01, // bits
+		0x02, 0x03, 0x02, 0x09, 0x02, // choice 1: 1
+		0x04, 0x04, 0x00, // missing optional
+		0x06, 0x08, 1, 0, 0, 0, 0, 0, 0, 0, // u64
+		0x08, 0x04, 0x01, 0x00, 0x00, // optional array, empty
+	}
+
+	decoded, err := DecodeSerialized(data)
+	c.Assert(err, IsNil)
+	fields := decoded.(SerializedStruct)
+	c.Assert(fields, HasLen, 5)
+	c.Check(fields[0].Value.(BitArray).Length, Equals, 10)
+	c.Check(fields[1].Value, DeepEquals, SerializedStruct{{1, int64(1)}})
+	c.Check(fields[2].Value, IsNil)
+	c.Check(fields[3].Value, Equals, int64(1))
+	c.Check(fields[4].Value, DeepEquals, []interface{}{})
+}
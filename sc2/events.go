@@ -0,0 +1,213 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Event stream names used by Protocol.Headers and Protocol.Events.
+const (
+	GameEventStream    = "game"
+	MessageEventStream = "message"
+	TrackerEventStream = "tracker"
+)
+
+// StreamHeader names the type infos read before each event of a
+// stream: the game loops since the previous event, the user who sent
+// it and the event ID.  UserTypeId is -1 for streams without users.
+// UserIdBase is the user ID the stream gives the lobby's first user,
+// user IDs are shifted down by it to count from 0 as LobbySlot.UserId
+// does.
+type StreamHeader struct {
+	DeltaTypeId int `json:"deltaTypeId"`
+	UserTypeId  int `json:"userTypeId"`
+	IdTypeId    int `json:"idTypeId"`
+	UserIdBase  int `json:"userIdBase"`
+}
+
+// EventHeader is what every event carries.  Name is the event's name
+// in the protocol, e.g. "Cmd", and Time is the real time of Loop.
+type EventHeader struct {
+	Loop   int           `json:"loop"`
+	Time   time.Duration `json:"time"`
+	UserId int           `json:"userId"` // As LobbySlot.UserId, -1 if the stream has no users
+	Name   string        `json:"name"`
+}
+
+func (header *EventHeader) Header() *EventHeader {
+	return header
+}
+
+// Event is an event from one of a replay's event streams.  Use a type
+// switch to get at the fields of a particular kind of event.
+type Event interface {
+	Header() *EventHeader
+}
+
+// GenericEvent is an event without a Go type of its own, Data holds
+// its decoded payload.
+type GenericEvent struct {
+	EventHeader
+	Data interface{} `json:"data"`
+}
+
+func (header *StreamHeader) validate(typeCount int) (err error) {
+	if header.DeltaTypeId < 0 || header.DeltaTypeId >= typeCount {
+		return fmt.Errorf("unknown type %v", header.DeltaTypeId)
+	}
+	if header.UserTypeId < -1 || header.UserTypeId >= typeCount {
+		return fmt.Errorf("unknown type %v", header.UserTypeId)
+	}
+	if header.IdTypeId < 0 || header.IdTypeId >= typeCount {
+		return fmt.Errorf("unknown type %v", header.IdTypeId)
+	}
+
+	return nil
+}
+
+// eventReader decodes the events of a stream one at a time.
+type eventReader struct {
+	replay   *Replay
+	protocol *Protocol
+	stream   string
+	header   StreamHeader
+	decoder  Decoder
+	loop     int
+}
+
+func newEventReader(replay *Replay, stream string, decoder Decoder) (reader *eventReader, err error) {
+	protocol := replay.Protocol
+	header, found := protocol.Headers[stream]
+	if !found {
+		return nil, fmt.Errorf("Protocol %v doesn't describe the %v event stream",
+			protocol.Name, stream)
+	}
+
+	reader = new(eventReader)
+	reader.replay = replay
+	reader.protocol = protocol
+	reader.stream = stream
+	reader.header = header
+	reader.decoder = decoder
+
+	return reader, nil
+}
+
+// next returns the header and payload of the next event, or io.EOF
+// after the last one.
+func (reader *eventReader) next() (header EventHeader, payload interface{}, err error) {
+	if reader.decoder.Done() {
+		return header, nil, io.EOF
+	}
+
+	delta, err := reader.decoder.Instance(reader.header.DeltaTypeId)
+	if err != nil {
+		return header, nil, reader.error(err)
+	}
+	reader.loop += int(unwrapInt(delta))
+	header.Loop = reader.loop
+	header.Time = reader.replay.LoopTime(reader.loop)
+
+	header.UserId = -1
+	if reader.header.UserTypeId >= 0 {
+		user, err := reader.decoder.Instance(reader.header.UserTypeId)
+		if err != nil {
+			return header, nil, reader.error(err)
+		}
+		header.UserId = int(unwrapInt(user)) - reader.header.UserIdBase
+	}
+
+	id, err := reader.decoder.Instance(reader.header.IdTypeId)
+	if err != nil {
+		return header, nil, reader.error(err)
+	}
+	eventType, found := reader.protocol.EventType(reader.stream, int(unwrapInt(id)))
+	if !found {
+		return header, nil, fmt.Errorf("Unknown %v event %v at game loop %v",
+			reader.stream, unwrapInt(id), reader.loop)
+	}
+	header.Name = eventType.Name
+
+	payload, err = reader.decoder.Instance(eventType.TypeId)
+	if err != nil {
+		return header, nil, reader.error(err)
+	}
+	reader.decoder.ByteAlign()
+
+	return header, payload, nil
+}
+
+func (reader *eventReader) error(err error) error {
+	return fmt.Errorf("Unable to read %v event at game loop %v: %v",
+		reader.stream, reader.loop, err)
+}
+
+// unwrapInt returns an int, or the int inside a struct or choice with
+// a single field such as a user ID or a variable length loop delta.
+func unwrapInt(value interface{}) int64 {
+	switch value := value.(type) {
+	case int64:
+		return value
+	case Struct:
+		if len(value) == 1 {
+			for _, field := range value {
+				return unwrapInt(field)
+			}
+		}
+	}
+
+	return 0
+}
+
+// lookup follows field names through decoded structs, it returns nil
+// if one is missing.
+func lookup(value interface{}, names ...string) interface{} {
+	for _, name := range names {
+		fields, ok := value.(Struct)
+		if !ok {
+			return nil
+		}
+		value = fields[name]
+	}
+
+	return value
+}
+
+func lookupInt(value interface{}, names ...string) int64 {
+	result, _ := lookup(value, names...).(int64)
+	return result
+}
+
+func lookupString(value interface{}, names ...string) string {
+	result, _ := lookup(value, names...).([]byte)
+	return string(result)
+}
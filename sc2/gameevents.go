@@ -0,0 +1,296 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"fmt"
+)
+
+// Control group updates.
+const (
+	ControlGroupSet = iota
+	ControlGroupAppend
+	ControlGroupRecall
+)
+
+// The selection a SelectionDeltaEvent changes when it isn't a control
+// group.
+const ActiveSelection = 10
+
+// Commands without an ability, such as a right click, set every bit of
+// the ability link.
+const noAbilityLink = 1<<21 - 1
+
+// Point is a position in map units.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// Ability is the ability a command uses, e.g. a unit's build or train
+// ability with the index of the button within it.
+type Ability struct {
+	Link     int `json:"link"`
+	CmdIndex int `json:"cmdIndex"`
+}
+
+// UnitTarget is the unit a command was aimed at.
+type UnitTarget struct {
+	Tag  int64 `json:"tag"`
+	Link int   `json:"link"` // The unit's type
+}
+
+// CmdEvent is an order given to the selected units.
+type CmdEvent struct {
+	EventHeader
+	Flags   int64       `json:"flags"`
+	Ability *Ability    `json:"ability"` // Nil for the default command
+	Point   *Point      `json:"point"`   // Nil without a target, where the unit was for unit targets
+	Unit    *UnitTarget `json:"unit"`    // Nil unless a unit was targeted
+}
+
+// Subgroup is a group of units of one type in a selection.
+type Subgroup struct {
+	UnitLink int `json:"unitLink"`
+	Priority int `json:"priority"`
+	Count    int `json:"count"`
+}
+
+// SelectionDeltaEvent changes the active selection or a control group.
+// RemoveMask marks the units removed, then AddSubgroups and AddUnitTags
+// list the units added.
+type SelectionDeltaEvent struct {
+	EventHeader
+	ControlGroupId int        `json:"controlGroupId"` // ActiveSelection for the active selection
+	SubgroupIndex  int        `json:"subgroupIndex"`
+	RemoveMask     BitArray   `json:"removeMask"`
+	AddSubgroups   []Subgroup `json:"addSubgroups"`
+	AddUnitTags    []int64    `json:"addUnitTags"`
+}
+
+// ControlGroupUpdateEvent sets, appends to or recalls a control group.
+type ControlGroupUpdateEvent struct {
+	EventHeader
+	Index  int       `json:"index"`
+	Update int       `json:"update"`
+	Mask   *BitArray `json:"mask"`
+}
+
+// CameraUpdateEvent moves a player's camera.  Distance, pitch and yaw
+// are nil when they didn't change.
+type CameraUpdateEvent struct {
+	EventHeader
+	Target   Point `json:"target"`
+	Distance *int  `json:"distance"`
+	Pitch    *int  `json:"pitch"`
+	Yaw      *int  `json:"yaw"`
+}
+
+// ResourceTradeEvent sends resources to an ally.  Resources are the
+// amounts of minerals, vespene, terrazine and the custom resource.
+// RecipientId is the recipient as the protocol records it, it isn't
+// shifted like the sender's UserId.
+type ResourceTradeEvent struct {
+	EventHeader
+	RecipientId int     `json:"recipientId"`
+	Resources   []int64 `json:"resources"`
+}
+
+// PlayerLeaveEvent is written when a player leaves the game.
+type PlayerLeaveEvent struct {
+	EventHeader
+}
+
+// PauseEvent is written when a player pauses the game.
+type PauseEvent struct {
+	EventHeader
+}
+
+// ResumeEvent is written when a player resumes a paused game.
+type ResumeEvent struct {
+	EventHeader
+}
+
+// GameEventReader returns the events in replay.game.events in order.
+type GameEventReader struct {
+	reader *eventReader
+}
+
+// GameEvents reads the commands, selections, camera moves and so on
+// that players made during the game.  Events are decoded as Next is
+// called, so a long replay doesn't need all its events in memory.
+func (replay *Replay) GameEvents() (events *GameEventReader, err error) {
+	data, err := replay.mpq.ReadFile("replay.game.events")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read game events: %v", err)
+	}
+
+	decoder := NewBitPackedDecoder(data, replay.Protocol.TypeInfos)
+	reader, err := newEventReader(replay, GameEventStream, decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GameEventReader{reader: reader}, nil
+}
+
+// Next returns the next event, or io.EOF after the last one.  An event
+// ID the protocol doesn't list is an error that ends the stream, the
+// size of its payload isn't known so the events after it can't be found.
+func (events *GameEventReader) Next() (event Event, err error) {
+	header, payload, err := events.reader.next()
+	if err != nil {
+		return nil, err
+	}
+
+	return newGameEvent(header, payload), nil
+}
+
+func newGameEvent(header EventHeader, payload interface{}) Event {
+	switch header.Name {
+	case "Cmd":
+		return newCmdEvent(header, payload)
+	case "SelectionDelta":
+		return newSelectionDeltaEvent(header, payload)
+	case "ControlGroupUpdate":
+		event := &ControlGroupUpdateEvent{EventHeader: header}
+		event.Index = int(lookupInt(payload, "m_controlGroupIndex"))
+		event.Update = int(lookupInt(payload, "m_controlGroupUpdate"))
+		if mask, ok := lookup(payload, "m_mask").(BitArray); ok {
+			event.Mask = &mask
+		}
+		return event
+	case "CameraUpdate":
+		event := &CameraUpdateEvent{EventHeader: header}
+		event.Target.X = float64(lookupInt(payload, "m_target", "x")) / 256
+		event.Target.Y = float64(lookupInt(payload, "m_target", "y")) / 256
+		event.Distance = optionalInt(lookup(payload, "m_distance"))
+		event.Pitch = optionalInt(lookup(payload, "m_pitch"))
+		event.Yaw = optionalInt(lookup(payload, "m_yaw"))
+		return event
+	case "ResourceTrade":
+		event := &ResourceTradeEvent{EventHeader: header}
+		event.RecipientId = int(lookupInt(payload, "m_recipientId"))
+		resources, _ := lookup(payload, "m_resources").([]interface{})
+		for _, amount := range resources {
+			event.Resources = append(event.Resources, unwrapInt(amount))
+		}
+		return event
+	case "PlayerLeave":
+		return &PlayerLeaveEvent{EventHeader: header}
+	case "Pause":
+		return &PauseEvent{EventHeader: header}
+	case "Resume":
+		return &ResumeEvent{EventHeader: header}
+	}
+
+	return &GenericEvent{EventHeader: header, Data: payload}
+}
+
+func newCmdEvent(header EventHeader, payload interface{}) *CmdEvent {
+	event := &CmdEvent{EventHeader: header}
+	event.Flags = lookupInt(payload, "m_cmdFlags")
+
+	abil := lookup(payload, "m_abil")
+	if abil != nil && lookupInt(abil, "m_abilLink") != noAbilityLink {
+		event.Ability = &Ability{
+			Link:     int(lookupInt(abil, "m_abilLink")),
+			CmdIndex: int(lookupInt(abil, "m_abilCmdIndex")),
+		}
+	}
+
+	data := lookup(payload, "m_data")
+	if lookup(data, "TargetPoint") != nil {
+		event.Point = newPoint(lookup(payload, "m_point"))
+	}
+	if unit := lookup(data, "TargetUnit"); unit != nil {
+		event.Point = newPoint(lookup(payload, "m_point"))
+		event.Unit = &UnitTarget{
+			Tag:  lookupInt(unit, "m_tag"),
+			Link: int(lookupInt(unit, "m_snapshotUnitLink")),
+		}
+	}
+
+	return event
+}
+
+func newSelectionDeltaEvent(header EventHeader, payload interface{}) *SelectionDeltaEvent {
+	event := &SelectionDeltaEvent{EventHeader: header}
+	event.ControlGroupId = int(lookupInt(payload, "m_controlGroupId"))
+
+	delta := lookup(payload, "m_delta")
+	event.SubgroupIndex = int(lookupInt(delta, "m_subgroupIndex"))
+	event.RemoveMask, _ = lookup(delta, "m_removeMask").(BitArray)
+
+	subgroups, _ := lookup(delta, "m_addSubgroups").([]interface{})
+	for _, subgroup := range subgroups {
+		event.AddSubgroups = append(event.AddSubgroups, Subgroup{
+			UnitLink: int(lookupInt(subgroup, "m_unitLink")),
+			Priority: int(lookupInt(subgroup, "m_subgroupPriority")),
+			Count:    int(lookupInt(subgroup, "m_count")),
+		})
+	}
+
+	tags, _ := lookup(delta, "m_addUnitTags").([]interface{})
+	for _, tag := range tags {
+		event.AddUnitTags = append(event.AddUnitTags, unwrapInt(tag))
+	}
+
+	return event
+}
+
+func newPoint(value interface{}) *Point {
+	return &Point{
+		X: fixedPoint(lookup(value, "x")),
+		Y: fixedPoint(lookup(value, "y")),
+		Z: fixedPoint(lookup(value, "z")),
+	}
+}
+
+// fixedPoint converts a coordinate with 12 fractional bits.  The
+// coordinates of early builds store their lowest bit first, so they
+// decode as a struct of the low bit and the rest.
+func fixedPoint(value interface{}) float64 {
+	if fields, ok := value.(Struct); ok {
+		value = lookupInt(fields, "m_highBits")<<1 | lookupInt(fields, "m_lowBit")
+	}
+
+	coordinate, _ := value.(int64)
+	return float64(coordinate) / 4096
+}
+
+func optionalInt(value interface{}) *int {
+	if value == nil {
+		return nil
+	}
+
+	result := int(unwrapInt(value))
+	return &result
+}
@@ -0,0 +1,156 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"io"
+	. "launchpad.net/gocheck"
+	"os"
+	"time"
+)
+
+type GameEventsSuite struct{}
+
+var _ = Suite(&GameEventsSuite{})
+
+func (s *GameEventsSuite) TestGameEvents(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	replay, err := NewReplay(reader)
+	c.Assert(err, IsNil)
+
+	events, err := replay.GameEvents()
+	c.Assert(err, IsNil)
+
+	counts := map[string]int{}
+	var firstCmd, unitCmd *CmdEvent
+	var firstSelection *SelectionDeltaEvent
+	var firstCamera *CameraUpdateEvent
+	var trade *ResourceTradeEvent
+	var last Event
+	for {
+		event, err := events.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		counts[event.Header().Name]++
+		last = event
+
+		switch event := event.(type) {
+		case *CmdEvent:
+			if firstCmd == nil {
+				firstCmd = event
+			}
+			if unitCmd == nil && event.Unit != nil {
+				unitCmd = event
+			}
+		case *SelectionDeltaEvent:
+			if firstSelection == nil {
+				firstSelection = event
+			}
+		case *CameraUpdateEvent:
+			if firstCamera == nil {
+				firstCamera = event
+			}
+		case *ResourceTradeEvent:
+			trade = event
+		}
+	}
+
+	c.Check(counts, DeepEquals, map[string]int{
+		"PlayerJoin":          4,
+		"UserFinishedLoading": 1,
+		"CameraUpdate":        11690,
+		"SelectionDelta":      1778,
+		"Cmd":                 4226,
+		"ControlGroupUpdate":  794,
+		"ResourceTrade":       1,
+		"PlayerLeave":         1,
+	})
+
+	c.Check(last, DeepEquals, &PlayerLeaveEvent{EventHeader{
+		Loop: 22571, Time: replay.Duration, UserId: 1, Name: "PlayerLeave"}})
+
+	c.Assert(firstCmd, NotNil)
+	c.Check(firstCmd.Loop, Equals, 16)
+	c.Check(firstCmd.UserId, Equals, 2)
+	c.Check(firstCmd.Ability, DeepEquals, &Ability{Link: 2058, CmdIndex: 0})
+	c.Check(firstCmd.Point, IsNil)
+	c.Check(firstCmd.Unit, IsNil)
+
+	c.Assert(unitCmd, NotNil)
+	c.Check(unitCmd.Loop, Equals, 33)
+	c.Check(unitCmd.Ability, IsNil)
+	c.Check(unitCmd.Point, DeepEquals, &Point{X: 145, Y: 43.5, Z: 9.99609375})
+	c.Check(unitCmd.Unit, DeepEquals, &UnitTarget{Tag: 243269633, Link: 3859})
+
+	c.Assert(firstSelection, NotNil)
+	c.Check(firstSelection.ControlGroupId, Equals, ActiveSelection)
+	c.Check(firstSelection.AddSubgroups, DeepEquals, []Subgroup{{UnitLink: 44, Priority: 1, Count: 1}})
+	c.Check(firstSelection.AddUnitTags, DeepEquals, []int64{47448065})
+
+	c.Assert(firstCamera, NotNil)
+	c.Check(firstCamera.Loop, Equals, 2)
+	c.Check(firstCamera.Target, Equals, Point{X: 138, Y: 44.2578125})
+	c.Check(*firstCamera.Distance, Equals, 8704)
+
+	c.Assert(trade, NotNil)
+	c.Check(trade.UserId, Equals, 3)
+	c.Check(trade.RecipientId, Equals, 3)
+	c.Check(trade.Resources, DeepEquals, []int64{950, 0, 0, 0})
+}
+
+func (s *GameEventsSuite) TestLoopTime(c *C) {
+	replay := &Replay{GameSpeed: SpeedFaster}
+	c.Check(replay.LoopTime(0), Equals, time.Duration(0))
+	c.Check(replay.LoopTime(22400), Equals, 1000*time.Second)
+}
+
+func (s *GameEventsSuite) TestPauseResume(c *C) {
+	protocol, err := LookupProtocol(15405)
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"Pause", "Resume"} {
+		found := false
+		for _, eventType := range protocol.Events[GameEventStream] {
+			if eventType.Name == name {
+				found = true
+			}
+		}
+		c.Check(found, Equals, true, Commentf("%v", name))
+	}
+
+	header := EventHeader{Loop: 100, UserId: 1, Name: "Pause"}
+	c.Check(newGameEvent(header, Struct{}), DeepEquals, &PauseEvent{header})
+	header.Name = "Resume"
+	c.Check(newGameEvent(header, Struct{}), DeepEquals, &ResumeEvent{header})
+}
@@ -0,0 +1,113 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Game loops per second of game time at normal speed.
+const LoopsPerSecond = 16
+
+// Version is the StarCraft II version a replay was recorded with.
+// BaseBuild is the build of the data the game was running on, it's the
+// same as Build unless a patch only changed the executable.
+type Version struct {
+	Major     int `xml:"major" json:"major"`
+	Minor     int `xml:"minor" json:"minor"`
+	Revision  int `xml:"revision" json:"revision"`
+	Build     int `xml:"build" json:"build"`
+	BaseBuild int `xml:"baseBuild" json:"baseBuild"`
+}
+
+func (version Version) String() string {
+	return fmt.Sprintf("%v.%v.%v.%v", version.Major, version.Minor,
+		version.Revision, version.Build)
+}
+
+var NoHeader error = errors.New("Replay has no header in its MPQ user data")
+
+// speedFactors holds how much faster than normal speed each game speed
+// runs.
+var speedFactors = map[int]float64{
+	SpeedSlower: 0.6,
+	SpeedSlow:   0.8,
+	SpeedNormal: 1.0,
+	SpeedFast:   1.2,
+	SpeedFaster: 1.4,
+}
+
+func (replay *Replay) loadHeader() (err error) {
+	if !replay.mpq.HasUserData {
+		return NoHeader
+	}
+
+	header, _, err := newSerializedValue(replay.mpq.UserData.Content)
+	if err != nil {
+		return fmt.Errorf("Unable to read replay header: %v", err)
+	}
+	if !header.isKey() || !header.field(1).isKey() {
+		return fmt.Errorf("Unable to read replay header: unexpected structure")
+	}
+
+	version := header.field(1)
+	replay.Version.Major = int(version.field(1).asInt64())
+	replay.Version.Minor = int(version.field(2).asInt64())
+	replay.Version.Revision = int(version.field(3).asInt64())
+	replay.Version.Build = int(version.field(4).asInt64())
+	replay.Version.BaseBuild = replay.Version.Build
+	if version.field(5) != nil {
+		replay.Version.BaseBuild = int(version.field(5).asInt64())
+	}
+
+	replay.GameLoops = int(header.field(3).asInt64())
+
+	return nil
+}
+
+// setDuration converts the game loops to real time once the game speed
+// is known.
+func (replay *Replay) setDuration() {
+	replay.Duration = replay.LoopTime(replay.GameLoops)
+}
+
+// LoopTime converts a game loop, such as an event's, to the real time
+// since the game started.  Replays with an unknown speed are treated as
+// normal speed.
+func (replay *Replay) LoopTime(loop int) time.Duration {
+	factor, ok := speedFactors[replay.GameSpeed]
+	if !ok {
+		factor = 1.0
+	}
+
+	seconds := float64(loop) / LoopsPerSecond / factor
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,239 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Lobby slot controls.
+const (
+	SlotUnknown = iota
+	SlotOpen
+	SlotClosed
+	SlotHuman
+	SlotComputer
+)
+
+// How a lobby slot takes part in the game.
+const (
+	ObserveNone = iota
+	ObserveSpectator
+	ObserveReferee
+)
+
+// Highest leagues reached, as later builds record them.
+const (
+	LeagueUnknown = iota
+	LeagueBronze
+	LeagueSilver
+	LeagueGold
+	LeaguePlatinum
+	LeagueDiamond
+	LeagueMaster
+	LeagueGrandmaster
+)
+
+// GameOptions are the options the lobby was created with.  Fog,
+// Observers and UserDifficulty are the lobby's raw settings.
+type GameOptions struct {
+	LockTeams             bool `xml:"lockTeams" json:"lockTeams"`
+	TeamsTogether         bool `xml:"teamsTogether" json:"teamsTogether"`
+	AdvancedSharedControl bool `xml:"advancedSharedControl" json:"advancedSharedControl"`
+	RandomRaces           bool `xml:"randomRaces" json:"randomRaces"`
+	BattleNet             bool `xml:"battleNet" json:"battleNet"`
+	Amm                   bool `xml:"amm" json:"amm"`
+	Ranked                bool `xml:"ranked" json:"ranked"`
+	Competitive           bool `xml:"competitive" json:"competitive"` // Later builds only
+	Cooperative           bool `xml:"cooperative" json:"cooperative"` // Later builds only
+	NoVictoryOrDefeat     bool `xml:"noVictoryOrDefeat" json:"noVictoryOrDefeat"`
+	Fog                   int  `xml:"fog" json:"fog"`
+	Observers             int  `xml:"observers" json:"observers"`
+	UserDifficulty        int  `xml:"userDifficulty" json:"userDifficulty"`
+}
+
+// CacheHandle names a file the game depends on, such as the map or a
+// mod, in the Battle.net depot.
+type CacheHandle struct {
+	Type   string `xml:"type" json:"type"` // The file extension, e.g. "s2ma" for maps
+	Region string `xml:"region" json:"region"`
+	Hash   string `xml:"hash" json:"hash"`
+}
+
+// URL returns where the file can be downloaded from the depot.
+func (handle CacheHandle) URL() string {
+	return fmt.Sprintf("http://%v.depot.battle.net:1119/%v.%v",
+		strings.ToLower(handle.Region), handle.Hash, handle.Type)
+}
+
+// LobbySlot is a slot in the game lobby, including open, closed and
+// observer slots.
+type LobbySlot struct {
+	Control   int     `xml:"control" json:"control"`
+	UserId    *int    `xml:"userId" json:"userId"` // Nil for slots without a user, counts from 0
+	TeamId    int     `xml:"teamId" json:"teamId"`
+	ColorPref *int    `xml:"colorPref" json:"colorPref"`
+	Handicap  int     `xml:"handicap" json:"handicap"`
+	Observe   int     `xml:"observe" json:"observe"`
+	Role      int     `xml:"role" json:"role"`
+	Rewards   []int64 `xml:"rewards>reward" json:"rewards"`
+}
+
+func (replay *Replay) loadInitData() (err error) {
+	typeId, ok := replay.Protocol.Types["initData"]
+	if !ok {
+		return nil
+	}
+
+	data, err := replay.mpq.ReadFile("replay.initData")
+	if err != nil {
+		return fmt.Errorf("Unable to read init data: %v", err)
+	}
+
+	decoder := NewBitPackedDecoder(data, replay.Protocol.TypeInfos)
+	value, err := decoder.Instance(typeId)
+	if err != nil {
+		return fmt.Errorf("Unable to read init data: %v", err)
+	}
+
+	lobby := lookup(value, "m_syncLobbyState")
+	replay.loadGameDescription(lookup(lobby, "m_gameDescription"))
+	replay.loadLobbyState(lookup(lobby, "m_lobbyState"))
+
+	replay.assignSlots()
+	replay.loadUserInitialData(lookup(lobby, "m_userInitialData"))
+
+	return nil
+}
+
+// loadUserInitialData sets the players' clan tags and leagues, which
+// are left alone when the protocol's user type doesn't have them.  The
+// users are indexed by user ID, observers included, so each player's
+// user is found through its lobby slot.
+func (replay *Replay) loadUserInitialData(data interface{}) {
+	users, _ := data.([]interface{})
+	for _, player := range replay.Players {
+		if player.slot == nil || player.slot.UserId == nil {
+			continue
+		}
+		if userId := *player.slot.UserId; userId >= 0 && userId < len(users) {
+			player.ClanTag = lookupString(users[userId], "m_clanTag")
+			player.HighestLeague = int(unwrapInt(lookup(users[userId], "m_highestLeague")))
+		}
+	}
+}
+
+// assignSlots finds the lobby slot of each player in replay.details.
+// Builds that record it give the working set slot ID, otherwise the
+// players are the filled participant slots in order, as observers and
+// open or closed slots aren't in replay.details.
+func (replay *Replay) assignSlots() {
+	participants := make([]*LobbySlot, 0)
+	for idx, slot := range replay.Slots {
+		if replay.slotRole(slot, idx+1) == RoleParticipant {
+			participants = append(participants, slot)
+		}
+	}
+
+	for idx, player := range replay.Players {
+		player.slot = nil
+		if slotId := player.workingSetSlotId; slotId != nil {
+			if *slotId >= 0 && *slotId < len(replay.Slots) {
+				player.slot = replay.Slots[*slotId]
+			}
+		} else if idx < len(participants) {
+			player.slot = participants[idx]
+		}
+	}
+}
+
+func (replay *Replay) loadGameDescription(description interface{}) {
+	options := lookup(description, "m_gameOptions")
+	flag := func(name string) bool {
+		value, _ := lookup(options, name).(bool)
+		return value
+	}
+	replay.GameOptions = GameOptions{
+		LockTeams:             flag("m_lockTeams"),
+		TeamsTogether:         flag("m_teamsTogether"),
+		AdvancedSharedControl: flag("m_advancedSharedControl"),
+		RandomRaces:           flag("m_randomRaces"),
+		BattleNet:             flag("m_battleNet"),
+		Amm:                   flag("m_amm"),
+		Ranked:                flag("m_ranked"),
+		Competitive:           flag("m_competitive"),
+		Cooperative:           flag("m_cooperative"),
+		NoVictoryOrDefeat:     flag("m_noVictoryOrDefeat"),
+		Fog:                   int(lookupInt(options, "m_fog")),
+		Observers:             int(lookupInt(options, "m_observers")),
+		UserDifficulty:        int(lookupInt(options, "m_userDifficulty")),
+	}
+
+	replay.MapSizeX = int(lookupInt(description, "m_mapSizeX"))
+	replay.MapSizeY = int(lookupInt(description, "m_mapSizeY"))
+
+	replay.CacheHandles = make([]CacheHandle, 0)
+	handles, _ := lookup(description, "m_cacheHandles").([]interface{})
+	for _, handle := range handles {
+		if data, ok := handle.([]byte); ok && len(data) == 40 {
+			replay.CacheHandles = append(replay.CacheHandles, CacheHandle{
+				Type:   strings.Trim(string(data[0:4]), "\x00"),
+				Region: strings.Trim(string(data[4:8]), "\x00"),
+				Hash:   hex.EncodeToString(data[8:]),
+			})
+		}
+	}
+}
+
+func (replay *Replay) loadLobbyState(state interface{}) {
+	replay.RandomSeed = lookupInt(state, "m_randomSeed")
+
+	replay.Slots = make([]*LobbySlot, 0)
+	slots, _ := lookup(state, "m_slots").([]interface{})
+	for _, value := range slots {
+		control := strconv.FormatInt(lookupInt(value, "m_control"), 10)
+		slot := &LobbySlot{
+			Control:   replay.Protocol.enum("control", control),
+			UserId:    optionalInt(lookup(value, "m_userId")),
+			TeamId:    int(lookupInt(value, "m_teamId")),
+			ColorPref: optionalInt(lookup(value, "m_colorPref")),
+			Handicap:  int(lookupInt(value, "m_handicap")),
+			Observe:   int(lookupInt(value, "m_observe")),
+			Rewards:   make([]int64, 0),
+		}
+		rewards, _ := lookup(value, "m_rewards").([]interface{})
+		for _, reward := range rewards {
+			slot.Rewards = append(slot.Rewards, unwrapInt(reward))
+		}
+		replay.Slots = append(replay.Slots, slot)
+	}
+}
@@ -0,0 +1,128 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type InitDataSuite struct {
+	replay *Replay
+}
+
+var _ = Suite(&InitDataSuite{})
+
+func (s *InitDataSuite) SetUpSuite(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	s.replay, err = NewReplay(reader)
+	c.Assert(err, IsNil)
+}
+
+func (s *InitDataSuite) TestGameOptions(c *C) {
+	c.Check(s.replay.GameOptions, DeepEquals, GameOptions{
+		LockTeams: true,
+		BattleNet: true,
+		Amm:       true,
+		Ranked:    true,
+	})
+	c.Check(s.replay.RandomSeed, Equals, int64(3566640770))
+	c.Check(s.replay.MapSizeX, Equals, 176)
+	c.Check(s.replay.MapSizeY, Equals, 184)
+}
+
+func (s *InitDataSuite) TestCacheHandles(c *C) {
+	c.Assert(s.replay.CacheHandles, HasLen, 5)
+	for _, handle := range s.replay.CacheHandles {
+		c.Check(handle.Type, Equals, "s2ma")
+		c.Check(handle.Region, Equals, "US")
+		c.Check(handle.Hash, HasLen, 64)
+	}
+
+	handle := CacheHandle{Type: "s2ma", Region: "US", Hash: "abc123"}
+	c.Check(handle.URL(), Equals, "http://us.depot.battle.net:1119/abc123.s2ma")
+}
+
+func (s *InitDataSuite) TestSlots(c *C) {
+	c.Assert(s.replay.Slots, HasLen, 4)
+	for idx, slot := range s.replay.Slots {
+		c.Check(slot.Control, Equals, SlotHuman)
+		c.Assert(slot.UserId, NotNil)
+		c.Check(*slot.UserId, Equals, idx)
+		c.Check(slot.TeamId, Equals, idx/2)
+		c.Assert(slot.ColorPref, NotNil)
+		c.Check(*slot.ColorPref, Equals, idx+1)
+		c.Check(slot.Handicap, Equals, 100)
+		c.Check(slot.Observe, Equals, ObserveNone)
+	}
+}
+
+func (s *InitDataSuite) TestPlayers(c *C) {
+	// Build 15405 predates clan tags and leagues in replay.initData
+	for _, player := range s.replay.Players {
+		c.Check(player.ClanTag, Equals, "")
+		c.Check(player.HighestLeague, Equals, LeagueUnknown)
+	}
+}
+
+func (s *InitDataSuite) TestUserInitialData(c *C) {
+	userIds := []int{0, 1, 2}
+	replay := &Replay{
+		Players: []*Player{{Name: "One"}, {Name: "Two"}},
+		Slots: []*LobbySlot{
+			{Control: SlotHuman, UserId: &userIds[0]},
+			{Control: SlotHuman, UserId: &userIds[1], Observe: ObserveSpectator},
+			{Control: SlotHuman, UserId: &userIds[2]},
+			{Control: SlotOpen},
+		},
+	}
+	users := []interface{}{
+		Struct{"m_clanTag": []byte("ONE"), "m_highestLeague": int64(LeagueGold)},
+		Struct{"m_clanTag": []byte("OBS"), "m_highestLeague": int64(LeagueMaster)},
+		Struct{"m_clanTag": []byte("TWO"), "m_highestLeague": int64(LeagueSilver)},
+	}
+
+	// The observer's user sits between the players' users
+	replay.assignSlots()
+	replay.loadUserInitialData(users)
+	c.Check(replay.Players[0].ClanTag, Equals, "ONE")
+	c.Check(replay.Players[0].HighestLeague, Equals, LeagueGold)
+	c.Check(replay.Players[1].ClanTag, Equals, "TWO")
+	c.Check(replay.Players[1].HighestLeague, Equals, LeagueSilver)
+
+	// A working set slot ID picks the slot directly
+	slotId := 1
+	replay.Players[1].workingSetSlotId = &slotId
+	replay.assignSlots()
+	replay.loadUserInitialData(users)
+	c.Check(replay.Players[1].ClanTag, Equals, "OBS")
+}
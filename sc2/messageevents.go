@@ -0,0 +1,188 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Who a chat message or ping was sent to.
+const (
+	RecipientUnknown = iota
+	RecipientAll
+	RecipientAllies
+	RecipientObservers
+)
+
+// ChatMessage is a line of in-game chat.
+type ChatMessage struct {
+	EventHeader
+	Recipient int    `json:"recipient"`
+	Text      string `json:"text"`
+}
+
+// PingMessage is a ping on the minimap.
+type PingMessage struct {
+	EventHeader
+	Recipient int   `json:"recipient"`
+	Point     Point `json:"point"`
+}
+
+// What a player announced to their allies.
+const (
+	AnnounceNone = iota
+	AnnounceAbility
+	AnnounceBehavior
+	AnnounceVitals
+)
+
+// PlayerAnnounceMessage is a player alerting their allies to a unit's
+// ability, behavior or vitals, e.g. that an ability is ready.  Only the
+// field for the kind of announcement is set.
+type PlayerAnnounceMessage struct {
+	EventHeader
+	Kind         int      `json:"kind"`
+	Ability      *Ability `json:"ability"`
+	BehaviorLink int      `json:"behaviorLink"`
+	VitalType    int      `json:"vitalType"`
+	AnnounceLink int      `json:"announceLink"`
+	UnitTag      int64    `json:"unitTag"`
+	OtherUnitTag int64    `json:"otherUnitTag"`
+}
+
+// LoadingProgressMessage reports how far, in percent, a user is through
+// loading the game.
+type LoadingProgressMessage struct {
+	EventHeader
+	Progress int `json:"progress"`
+}
+
+// MessageEventReader returns the events in replay.message.events in
+// order.
+type MessageEventReader struct {
+	reader *eventReader
+}
+
+// MessageEvents reads the chat, pings and other messages users sent
+// during the game.
+func (replay *Replay) MessageEvents() (events *MessageEventReader, err error) {
+	data, err := replay.mpq.ReadFile("replay.message.events")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read message events: %v", err)
+	}
+
+	decoder := NewBitPackedDecoder(data, replay.Protocol.TypeInfos)
+	reader, err := newEventReader(replay, MessageEventStream, decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MessageEventReader{reader: reader}, nil
+}
+
+// Next returns the next event, or io.EOF after the last one.  Unknown
+// event IDs end the stream as they do for GameEventReader.
+func (events *MessageEventReader) Next() (event Event, err error) {
+	header, payload, err := events.reader.next()
+	if err != nil {
+		return nil, err
+	}
+
+	return events.newMessageEvent(header, payload), nil
+}
+
+// Messages returns all the message events, there are few enough of
+// them to read at once.
+func (replay *Replay) Messages() (messages []Event, err error) {
+	events, err := replay.MessageEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		event, err := events.Next()
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, event)
+	}
+}
+
+func (events *MessageEventReader) newMessageEvent(header EventHeader, payload interface{}) Event {
+	protocol := events.reader.protocol
+	recipient := protocol.enum("recipient",
+		strconv.FormatInt(lookupInt(payload, "m_recipient"), 10))
+
+	switch header.Name {
+	case "Chat":
+		return &ChatMessage{EventHeader: header, Recipient: recipient,
+			Text: lookupString(payload, "m_string")}
+	case "Ping":
+		event := &PingMessage{EventHeader: header, Recipient: recipient}
+		event.Point.X = fixedPoint(lookup(payload, "m_point", "x"))
+		event.Point.Y = fixedPoint(lookup(payload, "m_point", "y"))
+		return event
+	case "LoadingProgress":
+		return &LoadingProgressMessage{EventHeader: header,
+			Progress: int(lookupInt(payload, "m_progress"))}
+	case "PlayerAnnounce":
+		return newPlayerAnnounceMessage(header, payload)
+	}
+
+	return &GenericEvent{EventHeader: header, Data: payload}
+}
+
+func newPlayerAnnounceMessage(header EventHeader, payload interface{}) *PlayerAnnounceMessage {
+	event := &PlayerAnnounceMessage{EventHeader: header}
+	event.AnnounceLink = int(lookupInt(payload, "m_announceLink"))
+	event.UnitTag = lookupInt(payload, "m_unitTag")
+	event.OtherUnitTag = lookupInt(payload, "m_otherUnitTag")
+
+	announcement := lookup(payload, "m_announcement")
+	if ability := lookup(announcement, "Ability"); ability != nil {
+		event.Kind = AnnounceAbility
+		event.Ability = &Ability{
+			Link:     int(lookupInt(ability, "m_abilLink")),
+			CmdIndex: int(lookupInt(ability, "m_abilCmdIndex")),
+		}
+	} else if behavior := lookup(announcement, "Behavior"); behavior != nil {
+		event.Kind = AnnounceBehavior
+		event.BehaviorLink = int(lookupInt(behavior, "m_behaviorLink"))
+	} else if vitals := lookup(announcement, "Vitals"); vitals != nil {
+		event.Kind = AnnounceVitals
+		event.VitalType = int(lookupInt(vitals, "m_vitalType"))
+	}
+
+	return event
+}
@@ -0,0 +1,154 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type MessageEventsSuite struct{}
+
+var _ = Suite(&MessageEventsSuite{})
+
+func (s *MessageEventsSuite) TestMessages(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	replay, err := NewReplay(reader)
+	c.Assert(err, IsNil)
+
+	messages, err := replay.Messages()
+	c.Assert(err, IsNil)
+
+	var chat []*ChatMessage
+	var pings []*PingMessage
+	progress := map[int]int{}
+	for _, message := range messages {
+		switch message := message.(type) {
+		case *ChatMessage:
+			chat = append(chat, message)
+		case *PingMessage:
+			pings = append(pings, message)
+		case *LoadingProgressMessage:
+			progress[message.UserId] = message.Progress
+		default:
+			c.Errorf("Unexpected message %#v", message)
+		}
+	}
+
+	c.Check(progress, DeepEquals, map[int]int{0: 100, 2: 100, 3: 100})
+
+	c.Assert(chat, HasLen, 2)
+	c.Check(chat[0], DeepEquals, &ChatMessage{
+		EventHeader: EventHeader{Loop: 8076, Time: replay.LoopTime(8076), UserId: 0, Name: "Chat"},
+		Recipient:   RecipientAll,
+		Text:        "NOOO",
+	})
+	c.Check(chat[1].Text, Equals, "STEVEN NOO")
+	c.Check(replay.UserPlayer(chat[1].UserId).Name, Equals, "TehPartE")
+
+	c.Assert(pings, HasLen, 13)
+	c.Check(pings[0].Loop, Equals, 7184)
+	c.Check(pings[0].Recipient, Equals, RecipientAllies)
+	c.Check(pings[0].Point, Equals, Point{X: 89.9619140625, Y: 16.34716796875})
+	c.Check(pings[12].UserId, Equals, 1)
+}
+
+func (s *MessageEventsSuite) TestUserPlayer(c *C) {
+	// The observer's user isn't a player in replay.details
+	replay := lobbyReplay(Game1v1,
+		&LobbySlot{Control: SlotHuman},
+		&LobbySlot{Control: SlotHuman, Observe: ObserveSpectator},
+		&LobbySlot{Control: SlotHuman})
+	replay.Players = []*Player{{Name: "One"}, {Name: "Two"}}
+	replay.assignSlots()
+	c.Check(replay.UserPlayer(0).Name, Equals, "One")
+	c.Check(replay.UserPlayer(1), IsNil)
+	c.Check(replay.UserPlayer(2).Name, Equals, "Two")
+	c.Check(replay.UserPlayer(3), IsNil)
+	c.Check(replay.UserPlayer(-1), IsNil)
+
+	// Without slots the users follow replay.details
+	replay = &Replay{Players: []*Player{{Name: "One"}, {Name: "Two"}}}
+	c.Check(replay.UserPlayer(1).Name, Equals, "Two")
+	c.Check(replay.UserPlayer(2), IsNil)
+	c.Check(replay.UserPlayer(-1), IsNil)
+}
+
+func (s *MessageEventsSuite) TestRecipients(c *C) {
+	protocol, err := LookupProtocol(15405)
+	c.Assert(err, IsNil)
+	events := &MessageEventReader{reader: &eventReader{protocol: protocol}}
+
+	recipients := []int{}
+	for value := int64(0); value < 4; value++ {
+		message := events.newMessageEvent(EventHeader{Name: "Chat"}, Struct{
+			"m_recipient": value, "m_string": []byte("gg"),
+		})
+		recipients = append(recipients, message.(*ChatMessage).Recipient)
+	}
+	c.Check(recipients, DeepEquals, []int{
+		RecipientAll, RecipientAllies, RecipientObservers, RecipientUnknown,
+	})
+}
+
+func (s *MessageEventsSuite) TestPlayerAnnounce(c *C) {
+	protocol, err := LookupProtocol(15405)
+	c.Assert(err, IsNil)
+	eventType, found := protocol.EventType(MessageEventStream, 5)
+	c.Assert(found, Equals, true)
+	c.Check(eventType.Name, Equals, "PlayerAnnounce")
+
+	events := &MessageEventReader{reader: &eventReader{protocol: protocol}}
+	header := EventHeader{Loop: 960, UserId: 2, Name: "PlayerAnnounce"}
+	message := events.newMessageEvent(header, Struct{
+		"m_announcement": Struct{"Ability": Struct{"m_abilLink": int64(318), "m_abilCmdIndex": int64(1)}},
+		"m_announceLink": int64(42),
+		"m_otherUnitTag": int64(0),
+		"m_unitTag":      int64(47448065),
+	})
+	c.Check(message, DeepEquals, &PlayerAnnounceMessage{
+		EventHeader:  header,
+		Kind:         AnnounceAbility,
+		Ability:      &Ability{Link: 318, CmdIndex: 1},
+		AnnounceLink: 42,
+		UnitTag:      47448065,
+	})
+
+	message = events.newMessageEvent(header, Struct{
+		"m_announcement": Struct{"Vitals": Struct{"m_vitalType": int64(2)}},
+	})
+	c.Check(message.(*PlayerAnnounceMessage).Kind, Equals, AnnounceVitals)
+	c.Check(message.(*PlayerAnnounceMessage).VitalType, Equals, 2)
+
+	message = events.newMessageEvent(header, Struct{"m_announcement": Struct{"None": nil}})
+	c.Check(message.(*PlayerAnnounceMessage).Kind, Equals, AnnounceNone)
+}
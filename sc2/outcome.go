@@ -0,0 +1,134 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"io"
+)
+
+// Winners returns the participants who won the game.  When
+// replay.details doesn't have the outcomes they are inferred with
+// ResolveOutcomes first, so the result is empty if nobody is known to
+// have won.
+func (replay *Replay) Winners() (winners []*Player, err error) {
+	err = replay.ResolveOutcomes()
+	if err != nil {
+		return nil, err
+	}
+
+	winners = make([]*Player, 0)
+	for _, player := range replay.Participants() {
+		if player.Outcome == OutcomeWin {
+			winners = append(winners, player)
+		}
+	}
+
+	return winners, nil
+}
+
+// ResolveOutcomes fills in the players' outcomes from the order they
+// left the game when replay.details doesn't have them, as in older
+// replays and replays saved by observers.  A team loses when its last
+// player leaves and the team left standing wins.  If more than one team
+// is still standing when the replay ends the outcomes stay unknown.
+func (replay *Replay) ResolveOutcomes() (err error) {
+	if replay.outcomesResolved {
+		return nil
+	}
+	for _, player := range replay.Participants() {
+		if player.Outcome != OutcomeUnknown {
+			replay.outcomesResolved = true
+			return nil
+		}
+	}
+
+	events, err := replay.GameEvents()
+	if err != nil {
+		return err
+	}
+
+	left := make([]*Player, 0)
+	for {
+		event, err := events.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if leave, ok := event.(*PlayerLeaveEvent); ok {
+			left = append(left, replay.UserPlayer(leave.UserId))
+		}
+	}
+
+	inferOutcomes(replay.Teams(), left)
+	replay.outcomesResolved = true
+
+	return nil
+}
+
+// inferOutcomes sets the outcomes of the teams' players from the
+// players who left the game, in the order they left.
+func inferOutcomes(teams []*Team, left []*Player) {
+	remaining := make(map[int]int)
+	for _, team := range teams {
+		remaining[team.Id] = len(team.Players)
+	}
+
+	standing := len(teams)
+	seen := make(map[*Player]bool)
+	for _, player := range left {
+		if standing <= 1 {
+			break
+		}
+		if player == nil || player.Role != RoleParticipant || seen[player] {
+			continue
+		}
+		seen[player] = true
+
+		remaining[player.Team]--
+		if remaining[player.Team] == 0 {
+			standing--
+		}
+	}
+	if standing != 1 {
+		return
+	}
+
+	for _, team := range teams {
+		outcome := OutcomeWin
+		if remaining[team.Id] == 0 {
+			outcome = OutcomeLoss
+		}
+		team.Outcome = outcome
+		for _, player := range team.Players {
+			player.Outcome = outcome
+		}
+	}
+}
@@ -0,0 +1,132 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type OutcomeSuite struct{}
+
+var _ = Suite(&OutcomeSuite{})
+
+// teamReplay makes a replay of participants on the given teams.
+func teamReplay(teams ...int) *Replay {
+	replay := new(Replay)
+	for idx, team := range teams {
+		replay.Players = append(replay.Players, &Player{
+			Id: int64(idx), Team: team, Role: RoleParticipant,
+		})
+	}
+
+	return replay
+}
+
+func (s *OutcomeSuite) TestDetailsOutcomes(c *C) {
+	replay := teamReplay(0, 0, 1, 1)
+	for idx, outcome := range []int{OutcomeLoss, OutcomeLoss, OutcomeWin, OutcomeWin} {
+		replay.Players[idx].Outcome = outcome
+	}
+
+	// Known outcomes are used without reading the game events
+	winners, err := replay.Winners()
+	c.Assert(err, IsNil)
+	c.Check(winners, DeepEquals, replay.Players[2:])
+
+	teams := replay.Teams()
+	c.Check(teams[0].Outcome, Equals, OutcomeLoss)
+	c.Check(teams[1].Outcome, Equals, OutcomeWin)
+
+	replay.Players[3].Outcome = OutcomeTie
+	c.Check(replay.Teams()[1].Outcome, Equals, OutcomeUnknown)
+}
+
+func (s *OutcomeSuite) TestInferOutcomes(c *C) {
+	replay := teamReplay(0, 0, 1, 1)
+	players := replay.Players
+
+	// The second team's players leave after the game was decided
+	inferOutcomes(replay.Teams(), []*Player{players[1], players[0], players[0], players[3]})
+	outcomes := []int{}
+	for _, player := range players {
+		outcomes = append(outcomes, player.Outcome)
+	}
+	c.Check(outcomes, DeepEquals, []int{OutcomeLoss, OutcomeLoss, OutcomeWin, OutcomeWin})
+
+	// Nothing is decided while two teams are standing
+	replay = teamReplay(0, 1, 2)
+	inferOutcomes(replay.Teams(), []*Player{replay.Players[2], nil})
+	for _, player := range replay.Players {
+		c.Check(player.Outcome, Equals, OutcomeUnknown)
+	}
+
+	// Observers leaving don't decide a game
+	replay = teamReplay(0, 1)
+	replay.Players[1].Role = RoleObserver
+	replay.Players = append(replay.Players, &Player{Team: 1, Role: RoleParticipant})
+	inferOutcomes(replay.Teams(), []*Player{replay.Players[1]})
+	c.Check(replay.Players[0].Outcome, Equals, OutcomeUnknown)
+}
+
+func (s *OutcomeSuite) TestObserverLeaves(c *C) {
+	replay := lobbyReplay(Game1v1,
+		&LobbySlot{Control: SlotHuman, TeamId: 0},
+		&LobbySlot{Control: SlotHuman, Observe: ObserveSpectator},
+		&LobbySlot{Control: SlotHuman, TeamId: 1})
+	loadLobby(replay)
+
+	// The observer, user 1, leaves before the game ends
+	inferOutcomes(replay.Teams(), []*Player{replay.UserPlayer(1)})
+	for _, player := range replay.Players {
+		c.Check(player.Outcome, Equals, OutcomeUnknown)
+	}
+
+	inferOutcomes(replay.Teams(), []*Player{replay.UserPlayer(1), replay.UserPlayer(2)})
+	c.Check(replay.Players[0].Outcome, Equals, OutcomeWin)
+	c.Check(replay.Players[1].Outcome, Equals, OutcomeLoss)
+}
+
+func (s *OutcomeSuite) TestReplayOutcomes(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	replay, err := NewReplay(reader)
+	c.Assert(err, IsNil)
+
+	// replay.details has no outcomes and only totsgerber leaves, while
+	// a teammate is still playing
+	winners, err := replay.Winners()
+	c.Assert(err, IsNil)
+	c.Check(winners, HasLen, 0)
+	for _, team := range replay.Teams() {
+		c.Check(team.Outcome, Equals, OutcomeUnknown)
+	}
+}
@@ -28,10 +28,16 @@
 
 package sc2
 
+import (
+	"encoding/xml"
+)
+
 const (
 	PlayerUnknown = iota
 	PlayerHuman
 	PlayerComputer
+	PlayerOpen
+	PlayerClosed
 )
 
 const (
@@ -52,6 +58,13 @@ const (
 	DifficultyInsane
 )
 
+const (
+	OutcomeUnknown = iota
+	OutcomeWin
+	OutcomeLoss
+	OutcomeTie
+)
+
 const (
 	ColorUnknown = iota
 	ColorRed
@@ -72,52 +85,62 @@ const (
 )
 
 type Player struct {
-	Name string
-	Id   int64
+	XMLName xml.Name `xml:"player" json:"-"`
+
+	Name string `xml:"name" json:"name"`
+	Id   int64  `xml:"id" json:"id"`
+	// ClanTag and HighestLeague are only read when the protocol's
+	// initData type has m_clanTag and m_highestLeague.  None of the
+	// built-in protocols do, so they stay empty unless a protocol for a
+	// later build is loaded.
+	ClanTag string `xml:"clanTag" json:"clanTag"`
 
-	Type int
+	Type int `xml:"type" json:"type"`
 
-	Team       int
-	Color      Color
-	NamedColor int
+	Team       int   `xml:"team" json:"team"` // Counting from 0, the lobby's teams rather than replay.details'
+	Role       int   `xml:"role" json:"role"`
+	Color      Color `xml:"color" json:"color"`
+	NamedColor int   `xml:"namedColor" json:"namedColor"`
 
-	ChosenRace int
-	ActualRace int
-	Difficulty int
-	Handicap   int
+	ChosenRace int `xml:"chosenRace" json:"chosenRace"`
+	ActualRace int `xml:"actualRace" json:"actualRace"`
+	Difficulty int `xml:"difficulty" json:"difficulty"`
+	Handicap   int `xml:"handicap" json:"handicap"`
 
-	Outcome int
+	HighestLeague int `xml:"highestLeague" json:"highestLeague"` // LeagueUnknown if not read, see ClanTag
+
+	Outcome int `xml:"outcome" json:"outcome"`
+
+	workingSetSlotId *int       // The player's index in Replay.Slots, in builds that record it
+	slot             *LobbySlot // Nil without replay.initData
 }
 
-func newPlayer(value *serializedValue) (player *Player, err error) {
+func newPlayer(protocol *Protocol, value *serializedValue) (player *Player, err error) {
 	player = new(Player)
-	player.load(value)
+	player.load(protocol, value)
 
 	return
 }
 
-func (player *Player) load(value *serializedValue) (err error) {
-	raceMap := map[string]int{
-		"Protoss": RaceProtoss,
-		"Terran":  RaceTerran,
-		"Zerg":    RaceZerg,
-	}
+func (player *Player) load(protocol *Protocol, value *serializedValue) (err error) {
+	player.Name = protocol.field(value, "player.name").asString()
+	player.Id = protocol.field(value, "player.id").asInt64()
+
+	color := protocol.field(value, "player.color")
+	player.Color.A = int(protocol.field(color, "color.a").asInt64())
+	player.Color.R = int(protocol.field(color, "color.r").asInt64())
+	player.Color.G = int(protocol.field(color, "color.g").asInt64())
+	player.Color.B = int(protocol.field(color, "color.b").asInt64())
+
+	player.Team = int(protocol.field(value, "player.team").asInt64())
+	player.Handicap = int(protocol.field(value, "player.handicap").asInt64())
+	player.Outcome = int(protocol.field(value, "player.outcome").asInt64())
+	player.ActualRace = protocol.enum("raceName", protocol.field(value, "player.race").asString())
 
-	player.Name = value.i(0).asString()
-	player.Id = value.i(1).i(3).asInt64()
-	player.Color.A = int(value.i(3).i(0).asInt64())
-	player.Color.R = int(value.i(3).i(1).asInt64())
-	player.Color.G = int(value.i(3).i(2).asInt64())
-	player.Color.B = int(value.i(3).i(3).asInt64())
-	player.Team = int(value.i(5).asInt64())
-	player.Handicap = int(value.i(6).asInt64())
-	player.Outcome = int(value.i(8).asInt64())
-
-	val, exists := raceMap[value.i(2).asString()]
-	if !exists {
-		val = RaceUnknown
+	if slotId := protocol.field(value, "player.workingSetSlotId"); slotId != nil {
+		id := int(slotId.asInt64())
+		player.workingSetSlotId = &id
 	}
-	player.ActualRace = val
 
 	return
 }
@@ -0,0 +1,325 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Protocol describes how the replay files written by a range of base
+// builds are laid out.  Protocols are plain data so new ones can be
+// loaded from JSON with LoadProtocol instead of changing the package.
+type Protocol struct {
+	Name     string `json:"name"`
+	MinBuild int    `json:"minBuild"`
+	MaxBuild int    `json:"maxBuild"` // Inclusive, 0 for no upper limit
+	// Fallback protocols are only used for builds no other protocol
+	// covers and may overlap them.  They describe the files whose layout
+	// doesn't change between builds, such as replay.details and
+	// replay.attributes.events.
+	Fallback bool `json:"fallback"`
+
+	// Fields maps a field name such as "details.mapName" to the keys
+	// leading to it in a serialized structure.
+	Fields map[string][]int64 `json:"fields"`
+	// Enums maps an enum name such as "race" to the values the game
+	// writes and the package constants they stand for.
+	Enums map[string]map[string]int `json:"enums"`
+	// Attributes names the IDs in replay.attributes.events and how their
+	// values decode, attributes come and go between builds.
+	Attributes map[int]AttributeType `json:"attributes"`
+	// Types names the type info of a whole file decoded with TypeInfos,
+	// such as "initData" for replay.initData.
+	Types map[string]int `json:"types"`
+	// Headers describes what precedes each event in an event stream,
+	// keyed by the stream name such as "game".
+	Headers map[string]StreamHeader `json:"headers"`
+	// Events lists the event types in each event stream, keyed by the
+	// stream name.
+	Events map[string][]EventType `json:"events"`
+	// TypeInfos is the type table used to decode the protocol's bit
+	// packed and versioned data, EventType.TypeId indexes it.
+	TypeInfos []TypeInfo `json:"typeInfos"`
+}
+
+// EventType names an event ID within an event stream.  TypeId is the
+// type info describing the event's payload.
+type EventType struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	TypeId int    `json:"typeId"`
+}
+
+// UnknownBuildError is returned when no registered protocol, fallbacks
+// included, covers the base build a replay was recorded with.
+type UnknownBuildError struct {
+	Build int
+}
+
+func (e *UnknownBuildError) Error() string {
+	return fmt.Sprintf("no replay protocol registered for build %v", e.Build)
+}
+
+// The fields every protocol has to describe, the replay can't be read
+// without them.
+var requiredFields = []string{
+	"details.players",
+	"details.mapName",
+	"details.timestamp",
+	"details.timezone",
+	"player.name",
+	"player.id",
+	"player.race",
+	"player.color",
+	"player.team",
+	"player.handicap",
+	"player.outcome",
+	"color.a",
+	"color.r",
+	"color.g",
+	"color.b",
+}
+
+var protocolsLock sync.RWMutex
+var protocols []*Protocol
+
+func init() {
+	for _, builtin := range []string{builtinProtocols, builtinFallbackProtocols} {
+		err := registerBuiltinProtocols(builtin)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterProtocol makes a protocol available to NewReplay, replacing a
+// registered protocol with the same name.  It fails if the protocol is
+// missing required fields or its builds overlap another protocol's that
+// is or isn't a fallback as well.
+func RegisterProtocol(protocol *Protocol) (err error) {
+	err = protocol.validate()
+	if err != nil {
+		return
+	}
+
+	protocolsLock.Lock()
+	defer protocolsLock.Unlock()
+
+	registered := []*Protocol{protocol}
+	for _, existing := range protocols {
+		if existing.Name == protocol.Name {
+			continue
+		}
+		if existing.Fallback == protocol.Fallback && existing.overlaps(protocol) {
+			return fmt.Errorf("Protocol %v overlaps the builds of protocol %v",
+				protocol.Name, existing.Name)
+		}
+		registered = append(registered, existing)
+	}
+
+	sort.Slice(registered, func(i, j int) bool {
+		if registered[i].MinBuild == registered[j].MinBuild {
+			return !registered[i].Fallback && registered[j].Fallback
+		}
+		return registered[i].MinBuild < registered[j].MinBuild
+	})
+	protocols = registered
+
+	return nil
+}
+
+// LoadProtocol reads a JSON protocol, or a JSON array of them, and
+// registers what it read.
+func LoadProtocol(reader io.Reader) (loaded []*Protocol, err error) {
+	var raw json.RawMessage
+	err = json.NewDecoder(reader).Decode(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read protocol: %v", err)
+	}
+
+	if len(raw) > 0 && raw[0] == '[' {
+		err = json.Unmarshal(raw, &loaded)
+	} else {
+		protocol := new(Protocol)
+		err = json.Unmarshal(raw, protocol)
+		loaded = []*Protocol{protocol}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read protocol: %v", err)
+	}
+
+	for _, protocol := range loaded {
+		err = RegisterProtocol(protocol)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return loaded, nil
+}
+
+// LoadProtocolFile registers the protocols in a JSON file.
+func LoadProtocolFile(path string) (loaded []*Protocol, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadProtocol(file)
+}
+
+// LookupProtocol returns the protocol covering a base build, or the
+// fallback protocol covering it if there isn't one.
+func LookupProtocol(build int) (protocol *Protocol, err error) {
+	protocolsLock.RLock()
+	defer protocolsLock.RUnlock()
+
+	var fallback *Protocol
+	for _, protocol := range protocols {
+		if !protocol.covers(build) {
+			continue
+		}
+		if !protocol.Fallback {
+			return protocol, nil
+		}
+		fallback = protocol
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+
+	return nil, &UnknownBuildError{Build: build}
+}
+
+// Protocols returns the registered protocols ordered by build.
+func Protocols() (registered []*Protocol) {
+	protocolsLock.RLock()
+	defer protocolsLock.RUnlock()
+
+	return append(registered, protocols...)
+}
+
+func (protocol *Protocol) validate() (err error) {
+	if protocol.Name == "" {
+		return fmt.Errorf("Protocol has no name")
+	}
+	if protocol.MinBuild < 0 || (protocol.MaxBuild != 0 && protocol.MaxBuild < protocol.MinBuild) {
+		return fmt.Errorf("Protocol %v has an invalid build range %v-%v",
+			protocol.Name, protocol.MinBuild, protocol.MaxBuild)
+	}
+
+	for id, info := range protocol.TypeInfos {
+		err = info.validate(len(protocol.TypeInfos))
+		if err != nil {
+			return fmt.Errorf("Protocol %v has an invalid type info %v: %v",
+				protocol.Name, id, err)
+		}
+	}
+	for id, attrType := range protocol.Attributes {
+		err = attrType.validate(protocol.Enums)
+		if err != nil {
+			return fmt.Errorf("Protocol %v has an invalid attribute %v: %v",
+				protocol.Name, id, err)
+		}
+	}
+	for name, typeId := range protocol.Types {
+		if typeId < 0 || typeId >= len(protocol.TypeInfos) {
+			return fmt.Errorf("Protocol %v has an unknown type info for %v", protocol.Name, name)
+		}
+	}
+	for stream, header := range protocol.Headers {
+		err = header.validate(len(protocol.TypeInfos))
+		if err != nil {
+			return fmt.Errorf("Protocol %v has an invalid %v stream header: %v",
+				protocol.Name, stream, err)
+		}
+	}
+	for stream, eventTypes := range protocol.Events {
+		for _, eventType := range eventTypes {
+			if eventType.TypeId < 0 || eventType.TypeId >= len(protocol.TypeInfos) {
+				return fmt.Errorf("Protocol %v has an unknown type info for %v event %v",
+					protocol.Name, stream, eventType.Name)
+			}
+		}
+	}
+
+	for _, name := range requiredFields {
+		if _, found := protocol.Fields[name]; !found {
+			return fmt.Errorf("Protocol %v is missing field %v", protocol.Name, name)
+		}
+	}
+
+	return nil
+}
+
+func (protocol *Protocol) covers(build int) bool {
+	return build >= protocol.MinBuild &&
+		(protocol.MaxBuild == 0 || build <= protocol.MaxBuild)
+}
+
+func (protocol *Protocol) overlaps(other *Protocol) bool {
+	return protocol.covers(other.MinBuild) || other.covers(protocol.MinBuild)
+}
+
+// field follows the keys of a named field from value.
+func (protocol *Protocol) field(value *serializedValue, name string) *serializedValue {
+	keys, found := protocol.Fields[name]
+	if !found {
+		return nil
+	}
+
+	for _, key := range keys {
+		value = value.field(key)
+	}
+
+	return value
+}
+
+// enum returns the constant for a value the game wrote, or 0 (the
+// unknown value of every enum) if it isn't listed.
+func (protocol *Protocol) enum(name string, value string) int {
+	return protocol.Enums[name][value]
+}
+
+// EventType returns the event type with an ID in an event stream.
+func (protocol *Protocol) EventType(stream string, id int) (eventType *EventType, found bool) {
+	for idx := range protocol.Events[stream] {
+		if protocol.Events[stream][idx].Id == id {
+			return &protocol.Events[stream][idx], true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,240 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/aphistic/go.Zamara/mpq"
+	. "launchpad.net/gocheck"
+	"os"
+	"strings"
+)
+
+type ProtocolSuite struct{}
+
+var _ = Suite(&ProtocolSuite{})
+
+// removeProtocol takes a protocol registered by a test back out of the
+// registry.
+func removeProtocol(name string) {
+	protocolsLock.Lock()
+	defer protocolsLock.Unlock()
+
+	kept := []*Protocol{}
+	for _, protocol := range protocols {
+		if protocol.Name != name {
+			kept = append(kept, protocol)
+		}
+	}
+	protocols = kept
+}
+
+// testProtocolJson returns a copy of the built-in protocol's JSON, with
+// the shared base filled in, under a new name and build range.
+func testProtocolJson(name string, builds string) string {
+	base := strings.TrimSuffix(strings.TrimPrefix(builtinBase, "{"), "}")
+	json := strings.Replace(builtinProtocols, `"wol-15405"`, `"`+name+`"`, 1)
+	json = strings.Replace(json, `"minBuild": 15405,
+	"maxBuild": 16939,`, builds+","+base+",", 1)
+	return json
+}
+
+func (s *ProtocolSuite) TestLookupBuiltin(c *C) {
+	protocol, err := LookupProtocol(15405)
+	c.Assert(err, IsNil)
+	c.Check(protocol.Name, Equals, "wol-15405")
+	c.Check(protocol.enum("race", "Prot"), Equals, RaceProtoss)
+	c.Check(protocol.enum("race", "Nope"), Equals, RaceUnknown)
+
+	// Later builds only get the fallbacks' details, attributes and
+	// tracker events
+	fallbacks := map[int]string{
+		16940: "fallback-15405", 25603: "fallback-15405",
+		25604: "fallback-25604", 90001: "fallback-25604",
+	}
+	for build, name := range fallbacks {
+		protocol, err = LookupProtocol(build)
+		c.Assert(err, IsNil)
+		c.Check(protocol.Name, Equals, name)
+		c.Check(protocol.Fallback, Equals, true)
+		c.Check(protocol.Types, HasLen, 0)
+		c.Check(protocol.enum("race", "Prot"), Equals, RaceProtoss)
+	}
+
+	_, err = LookupProtocol(15404)
+	c.Check(err, DeepEquals, &UnknownBuildError{Build: 15404})
+	c.Check(err.Error(), Equals, "no replay protocol registered for build 15404")
+}
+
+func (s *ProtocolSuite) TestBuiltinBase(c *C) {
+	base := new(Protocol)
+	c.Assert(json.Unmarshal([]byte(builtinBase), base), IsNil)
+
+	// Every built-in protocol gets its own copy of the shared base
+	colors := map[string]map[string]int{}
+	for _, protocol := range Protocols() {
+		c.Check(protocol.Fields, DeepEquals, base.Fields, Commentf(protocol.Name))
+		c.Check(protocol.Enums, DeepEquals, base.Enums, Commentf(protocol.Name))
+		c.Check(protocol.Attributes, DeepEquals, base.Attributes, Commentf(protocol.Name))
+		colors[protocol.Name] = protocol.Enums["color"]
+	}
+	c.Assert(colors, HasLen, 3)
+
+	colors["wol-15405"]["test"] = 1
+	defer delete(colors["wol-15405"], "test")
+	c.Check(colors["fallback-15405"]["test"], Equals, 0)
+	c.Check(colors["fallback-25604"]["test"], Equals, 0)
+}
+
+func (s *ProtocolSuite) TestLoadProtocol(c *C) {
+	defer removeProtocol("test-90000")
+
+	json := testProtocolJson("test-90000", `"minBuild": 90000, "maxBuild": 0`)
+	loaded, err := LoadProtocol(strings.NewReader(json))
+	c.Assert(err, IsNil)
+	c.Assert(loaded, HasLen, 1)
+	c.Check(loaded[0].Fields["player.id"], DeepEquals, []int64{1, 4})
+
+	protocol, err := LookupProtocol(123456)
+	c.Assert(err, IsNil)
+	c.Check(protocol, Equals, loaded[0])
+
+	names := []string{}
+	for _, protocol := range Protocols() {
+		names = append(names, protocol.Name)
+	}
+	c.Check(names, DeepEquals, []string{"wol-15405", "fallback-15405", "fallback-25604", "test-90000"})
+
+	// Registering the same name again replaces the protocol
+	json = testProtocolJson("test-90000", `"minBuild": 91000, "maxBuild": 0`)
+	_, err = LoadProtocol(strings.NewReader(json))
+	c.Assert(err, IsNil)
+	protocol, err = LookupProtocol(90500)
+	c.Assert(err, IsNil)
+	c.Check(protocol.Name, Equals, "fallback-25604")
+}
+
+func (s *ProtocolSuite) TestRegisterInvalid(c *C) {
+	json := testProtocolJson("test-overlap", `"minBuild": 16000, "maxBuild": 17000`)
+	_, err := LoadProtocol(strings.NewReader(json))
+	c.Check(err, ErrorMatches, "Protocol test-overlap overlaps the builds of protocol wol-15405")
+
+	json = testProtocolJson("test-fallback", `"minBuild": 95000, "maxBuild": 0, "fallback": true`)
+	_, err = LoadProtocol(strings.NewReader(json))
+	c.Check(err, ErrorMatches, "Protocol test-fallback overlaps the builds of protocol fallback-25604")
+
+	json = testProtocolJson("test-range", `"minBuild": 95000, "maxBuild": 94000`)
+	_, err = LoadProtocol(strings.NewReader(json))
+	c.Check(err, ErrorMatches, "Protocol test-range has an invalid build range 95000-94000")
+
+	err = RegisterProtocol(&Protocol{Name: "test-fields", MinBuild: 95000})
+	c.Check(err, ErrorMatches, "Protocol test-fields is missing field details.players")
+
+	json = testProtocolJson("test-header", `"minBuild": 95000, "maxBuild": 0`)
+	json = strings.Replace(json, `"deltaTypeId": 4`, `"deltaTypeId": 400`, 1)
+	_, err = LoadProtocol(strings.NewReader(json))
+	c.Check(err, ErrorMatches, "Protocol test-header has an invalid game stream header: unknown type 400")
+
+	json = testProtocolJson("test-types", `"minBuild": 95000, "maxBuild": 0`)
+	json = strings.Replace(json, `"initData": 72`, `"initData": 700`, 1)
+	_, err = LoadProtocol(strings.NewReader(json))
+	c.Check(err, ErrorMatches, "Protocol test-types has an unknown type info for initData")
+
+	json = testProtocolJson("test-attributes", `"minBuild": 95000, "maxBuild": 0`)
+	json = strings.Replace(json, `"enum": "teamCount"`, `"enum": "nope"`, 1)
+	_, err = LoadProtocol(strings.NewReader(json))
+	c.Check(err, ErrorMatches, "Protocol test-attributes has an invalid attribute 2000: unknown enum nope")
+
+	_, err = LoadProtocol(strings.NewReader("{"))
+	c.Check(err, ErrorMatches, "Unable to read protocol: .*")
+
+	c.Check(Protocols(), HasLen, 3)
+}
+
+func (s *ProtocolSuite) TestReplayUnknownBuild(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+	original, err := mpq.NewMpq(reader)
+	c.Assert(err, IsNil)
+
+	// Swap the base build 15405 for 14000, before any protocol
+	header := bytes.Replace(original.UserData.Content,
+		[]byte{0x09, 0xda, 0xf0, 0x01}, []byte{0x09, 0xe0, 0xda, 0x01}, 1)
+
+	buffer := new(bytes.Buffer)
+	w := mpq.NewWriter(buffer)
+	w.SetUserData(header)
+	c.Assert(w.Close(), IsNil)
+
+	archive, err := mpq.NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+
+	_, err = NewReplayFromMpq(archive)
+	c.Check(err, DeepEquals, &UnknownBuildError{Build: 14000})
+}
+
+func (s *ProtocolSuite) TestReplayFallback(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+	original, err := mpq.NewMpq(reader)
+	c.Assert(err, IsNil)
+
+	// Swap the base build 15405 for 90001
+	header := bytes.Replace(original.UserData.Content,
+		[]byte{0x09, 0xda, 0xf0, 0x01}, []byte{0x09, 0xa2, 0xfe, 0x0a}, 1)
+
+	buffer := new(bytes.Buffer)
+	w := mpq.NewWriter(buffer)
+	w.SetUserData(header)
+	for _, filename := range []string{"replay.details", "replay.attributes.events", "replay.game.events"} {
+		data, err := original.ReadFile(filename)
+		c.Assert(err, IsNil)
+		c.Assert(w.AddFile(filename, data), IsNil)
+	}
+	c.Assert(w.Close(), IsNil)
+
+	archive, err := mpq.NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+
+	replay, err := NewReplayFromMpq(archive)
+	c.Assert(err, IsNil)
+	c.Check(replay.Version.BaseBuild, Equals, 90001)
+	c.Check(replay.Protocol.Name, Equals, "fallback-25604")
+	c.Check(replay.MapName, Equals, "Discord IV")
+	c.Check(replay.GameType, Equals, Game2v2)
+	c.Assert(replay.Players, HasLen, 4)
+	c.Check(replay.Players[0].Name, Equals, "TehPartE")
+	c.Check(replay.Slots, HasLen, 0)
+
+	_, err = replay.GameEvents()
+	c.Check(err, ErrorMatches, "Protocol fallback-25604 doesn't describe the game event stream")
+}
@@ -0,0 +1,319 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// registerBuiltinProtocols registers a JSON array of built-in protocols,
+// each read on top of its own copy of builtinBase.
+func registerBuiltinProtocols(builtin string) (err error) {
+	var entries []json.RawMessage
+	err = json.Unmarshal([]byte(builtin), &entries)
+	if err != nil {
+		return fmt.Errorf("Unable to read protocol: %v", err)
+	}
+
+	for _, entry := range entries {
+		protocol := new(Protocol)
+		err = json.Unmarshal([]byte(builtinBase), protocol)
+		if err == nil {
+			err = json.Unmarshal(entry, protocol)
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to read protocol: %v", err)
+		}
+
+		err = RegisterProtocol(protocol)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// builtinBase is what the built-in protocols share: the fields of
+// replay.details, the enums and the attributes.  Each built-in protocol
+// is read on top of it, so only lists what its builds add or change.
+const builtinBase = `{
+	"fields": {
+		"details.players": [0],
+		"details.mapName": [1],
+		"details.timestamp": [5],
+		"details.timezone": [6],
+		"player.name": [0],
+		"player.id": [1, 4],
+		"player.race": [2],
+		"player.color": [3],
+		"player.team": [5],
+		"player.handicap": [6],
+		"player.outcome": [8],
+		"color.a": [0],
+		"color.r": [1],
+		"color.g": [2],
+		"color.b": [3]
+	},
+	"enums": {
+		"raceName": {"Terran": 2, "Protoss": 3, "Zerg": 4},
+		"playerType": {"Humn": 1, "Comp": 2, "Open": 3, "Clsd": 4},
+		"race": {"RAND": 1, "Terr": 2, "Prot": 3, "Zerg": 4},
+		"difficulty": {
+			"VyEy": 1, "Easy": 2, "Medi": 3, "Hard": 4, "VyHd": 5, "Insa": 6
+		},
+		"color": {
+			"tc01": 1, "tc02": 2, "tc03": 3, "tc04": 4, "tc05": 5,
+			"tc06": 6, "tc07": 7, "tc08": 8, "tc09": 9, "tc10": 10,
+			"tc11": 11, "tc12": 12, "tc13": 13, "tc14": 14, "tc15": 15
+		},
+		"gameType": {
+			"1v1": 1, "2v2": 2, "3v3": 3, "4v4": 4, "FFA": 5, "6v6": 6, "Cust": 7,
+			"5v5": 8
+		},
+		"gameSpeed": {"Slor": 1, "Slow": 2, "Norm": 3, "Fast": 4, "Fasr": 5},
+		"gameCategory": {"Priv": 1, "Amm": 2, "Pub": 3},
+		"recipient": {"0": 1, "1": 2, "2": 3},
+		"control": {"0": 1, "1": 2, "2": 3, "3": 4},
+		"teamCount": {"t2": 2, "t3": 3, "t4": 4, "t5": 5, "t6": 6},
+		"team": {
+			"T1": 1, "T2": 2, "T3": 3, "T4": 4, "T5": 5, "T6": 6,
+			"T7": 7, "T8": 8, "T9": 9, "T10": 10, "T11": 11, "T12": 12
+		},
+		"playerMode": {"Part": 1, "Watc": 2},
+		"observerType": {"Obs": 1, "Ref": 2}
+	},
+	"attributes": {
+		"500": {"name": "playerType", "kind": "enum", "enum": "playerType"},
+		"1000": {"name": "rules", "kind": "string"},
+		"1001": {"name": "premadeGame", "kind": "bool"},
+		"2000": {"name": "customTeams", "kind": "enum", "enum": "teamCount"},
+		"2001": {"name": "gameType", "kind": "enum", "enum": "gameType"},
+		"2002": {"name": "teams1v1", "kind": "enum", "enum": "team"},
+		"2003": {"name": "teams2v2", "kind": "enum", "enum": "team"},
+		"2004": {"name": "teams3v3", "kind": "enum", "enum": "team"},
+		"2005": {"name": "teams4v4", "kind": "enum", "enum": "team"},
+		"2006": {"name": "teamsFfa", "kind": "enum", "enum": "team"},
+		"2007": {"name": "teams5v5", "kind": "enum", "enum": "team"},
+		"2008": {"name": "teams6v6", "kind": "enum", "enum": "team"},
+		"2011": {"name": "teamsCustom2", "kind": "enum", "enum": "team"},
+		"2012": {"name": "teamsCustom3", "kind": "enum", "enum": "team"},
+		"3000": {"name": "gameSpeed", "kind": "enum", "enum": "gameSpeed"},
+		"3001": {"name": "race", "kind": "enum", "enum": "race"},
+		"3002": {"name": "color", "kind": "enum", "enum": "color"},
+		"3003": {"name": "handicap", "kind": "int"},
+		"3004": {"name": "difficulty", "kind": "enum", "enum": "difficulty"},
+		"3006": {"name": "lobbyDelay", "kind": "int"},
+		"3007": {"name": "playerMode", "kind": "enum", "enum": "playerMode"},
+		"3008": {"name": "observerType", "kind": "enum", "enum": "observerType"},
+		"3009": {"name": "gameCategory", "kind": "enum", "enum": "gameCategory"},
+		"3010": {"name": "lockedAlliances", "kind": "bool"}
+	}
+}`
+
+// builtinProtocols are registered when the package loads.  They use the
+// same JSON that LoadProtocol reads, enum values are the package
+// constants.
+const builtinProtocols = `[
+{
+	"name": "wol-15405",
+	"minBuild": 15405,
+	"maxBuild": 16939,
+	"types": {
+		"initData": 72
+	},
+	"headers": {
+		"game": {"deltaTypeId": 4, "userTypeId": 6, "idTypeId": 7, "userIdBase": 1},
+		"message": {"deltaTypeId": 4, "userTypeId": 6, "idTypeId": 9, "userIdBase": 1}
+	},
+	"events": {
+		"game": [
+			{"id": 5, "name": "UserFinishedLoading", "typeId": 8},
+			{"id": 11, "name": "PlayerJoin", "typeId": 10},
+			{"id": 17, "name": "Pause", "typeId": 8},
+			{"id": 18, "name": "Resume", "typeId": 8},
+			{"id": 25, "name": "PlayerLeave", "typeId": 8},
+			{"id": 27, "name": "Cmd", "typeId": 25},
+			{"id": 28, "name": "SelectionDelta", "typeId": 31},
+			{"id": 29, "name": "ControlGroupUpdate", "typeId": 34},
+			{"id": 31, "name": "ResourceTrade", "typeId": 37},
+			{"id": 49, "name": "CameraUpdate", "typeId": 40}
+		],
+		"message": [
+			{"id": 0, "name": "Chat", "typeId": 42},
+			{"id": 1, "name": "Ping", "typeId": 44},
+			{"id": 2, "name": "LoadingProgress", "typeId": 45},
+			{"id": 5, "name": "PlayerAnnounce", "typeId": 77}
+		]
+	},
+	"typeInfos": [
+		{"kind": "int", "bounds": [0, 6]},
+		{"kind": "int", "bounds": [0, 14]},
+		{"kind": "int", "bounds": [0, 22]},
+		{"kind": "int", "bounds": [0, 32]},
+		{"kind": "choice", "bounds": [0, 2], "fields": [{"name": "m_uint6", "type": 0, "tag": 0}, {"name": "m_uint14", "type": 1, "tag": 1}, {"name": "m_uint22", "type": 2, "tag": 2}, {"name": "m_uint32", "type": 3, "tag": 3}]},
+		{"kind": "int", "bounds": [0, 5]},
+		{"kind": "struct", "fields": [{"name": "m_userId", "type": 5}]},
+		{"kind": "int", "bounds": [0, 7]},
+		{"kind": "struct", "fields": []},
+		{"kind": "int", "bounds": [0, 4]},
+		{"kind": "struct", "fields": [{"name": "m_flags", "type": 9}]},
+		{"kind": "int", "bounds": [0, 1]},
+		{"kind": "int", "bounds": [-1073741824, 31]},
+		{"kind": "struct", "fields": [{"name": "m_lowBit", "type": 11}, {"name": "m_highBits", "type": 12}]},
+		{"kind": "struct", "fields": [{"name": "x", "type": 13}, {"name": "y", "type": 13}, {"name": "z", "type": 13}]},
+		{"kind": "int", "bounds": [0, 8]},
+		{"kind": "int", "bounds": [0, 24]},
+		{"kind": "int", "bounds": [0, 44]},
+		{"kind": "struct", "fields": [{"name": "m_targetUnitFlags", "type": 15}, {"name": "m_timer", "type": 15}, {"name": "m_reserved", "type": 16}, {"name": "m_padding", "type": 17}]},
+		{"kind": "int", "bounds": [0, 16]},
+		{"kind": "struct", "fields": [{"name": "m_targetUnitFlags", "type": 15}, {"name": "m_timer", "type": 15}, {"name": "m_reserved", "type": 16}, {"name": "m_tag", "type": 3}, {"name": "m_snapshotUnitLink", "type": 19}]},
+		{"kind": "choice", "bounds": [0, 16], "fields": [{"name": "None", "type": 18, "tag": 7}, {"name": "TargetPoint", "type": 18, "tag": 15}, {"name": "TargetUnit", "type": 20, "tag": 12295}, {"name": "TargetUnit", "type": 20, "tag": 20487}]},
+		{"kind": "int", "bounds": [0, 21]},
+		{"kind": "struct", "fields": [{"name": "m_abilLink", "type": 22}, {"name": "m_abilCmdIndex", "type": 15}]},
+		{"kind": "int", "bounds": [0, 31]},
+		{"kind": "struct", "fields": [{"name": "m_cmdFlags", "type": 24}, {"name": "m_abil", "type": 23}, {"name": "m_data", "type": 21}, {"name": "m_point", "type": 14}, {"name": "m_unknown", "type": 9}]},
+		{"kind": "bitarray", "bounds": [0, 8]},
+		{"kind": "struct", "fields": [{"name": "m_unitLink", "type": 19}, {"name": "m_subgroupPriority", "type": 15}, {"name": "m_count", "type": 15}]},
+		{"kind": "array", "bounds": [0, 8], "type": 27},
+		{"kind": "array", "bounds": [0, 8], "type": 3},
+		{"kind": "struct", "fields": [{"name": "m_subgroupIndex", "type": 15}, {"name": "m_removeMask", "type": 26}, {"name": "m_addSubgroups", "type": 28}, {"name": "m_addUnitTags", "type": 29}]},
+		{"kind": "struct", "fields": [{"name": "m_controlGroupId", "type": 9}, {"name": "m_delta", "type": 30}]},
+		{"kind": "int", "bounds": [0, 2]},
+		{"kind": "optional", "type": 26},
+		{"kind": "struct", "fields": [{"name": "m_controlGroupIndex", "type": 9}, {"name": "m_controlGroupUpdate", "type": 32}, {"name": "m_mask", "type": 33}]},
+		{"kind": "int", "bounds": [-2147483648, 32]},
+		{"kind": "array", "bounds": [0, 3], "type": 35},
+		{"kind": "struct", "fields": [{"name": "m_recipientId", "type": 9}, {"name": "m_resources", "type": 36}]},
+		{"kind": "struct", "fields": [{"name": "x", "type": 19}, {"name": "y", "type": 19}]},
+		{"kind": "optional", "type": 19},
+		{"kind": "struct", "fields": [{"name": "m_target", "type": 38}, {"name": "m_distance", "type": 39}, {"name": "m_pitch", "type": 39}, {"name": "m_yaw", "type": 39}]},
+		{"kind": "blob", "bounds": [0, 11]},
+		{"kind": "struct", "fields": [{"name": "m_recipient", "type": 32}, {"name": "m_string", "type": 41}]},
+		{"kind": "struct", "fields": [{"name": "x", "type": 35}, {"name": "y", "type": 35}]},
+		{"kind": "struct", "fields": [{"name": "m_recipient", "type": 32}, {"name": "m_point", "type": 43}]},
+		{"kind": "struct", "fields": [{"name": "m_progress", "type": 35}]},
+		{"kind": "bool"},
+		{"kind": "blob", "bounds": [0, 8]},
+		{"kind": "optional", "type": 15},
+		{"kind": "struct", "fields": [{"name": "m_race", "type": 48}]},
+		{"kind": "optional", "type": 9},
+		{"kind": "struct", "fields": [{"name": "m_team", "type": 50}]},
+		{"kind": "struct", "fields": [{"name": "m_name", "type": 47}, {"name": "m_randomSeed", "type": 3}, {"name": "m_racePreference", "type": 49}, {"name": "m_teamPreference", "type": 51}, {"name": "m_testMap", "type": 46}, {"name": "m_testAuto", "type": 46}, {"name": "m_examine", "type": 46}, {"name": "m_customInterface", "type": 46}]},
+		{"kind": "array", "bounds": [0, 5], "type": 52},
+		{"kind": "blob", "bounds": [0, 10]},
+		{"kind": "struct", "fields": [{"name": "m_lockTeams", "type": 46}, {"name": "m_teamsTogether", "type": 46}, {"name": "m_advancedSharedControl", "type": 46}, {"name": "m_randomRaces", "type": 46}, {"name": "m_battleNet", "type": 46}, {"name": "m_amm", "type": 46}, {"name": "m_ranked", "type": 46}, {"name": "m_noVictoryOrDefeat", "type": 46}, {"name": "m_fog", "type": 32}, {"name": "m_observers", "type": 32}, {"name": "m_userDifficulty", "type": 32}]},
+		{"kind": "int", "bounds": [0, 3]},
+		{"kind": "int", "bounds": [1, 4]},
+		{"kind": "int", "bounds": [1, 8]},
+		{"kind": "bitarray", "bounds": [0, 6]},
+		{"kind": "bitarray", "bounds": [0, 2]},
+		{"kind": "struct", "fields": [{"name": "m_allowedColors", "type": 59}, {"name": "m_allowedRaces", "type": 26}, {"name": "m_allowedDifficulty", "type": 59}, {"name": "m_allowedControls", "type": 26}, {"name": "m_allowedObserveTypes", "type": 60}]},
+		{"kind": "array", "bounds": [0, 5], "type": 61},
+		{"kind": "blob", "bounds": [40, 0]},
+		{"kind": "array", "bounds": [0, 5], "type": 63},
+		{"kind": "struct", "fields": [{"name": "m_randomValue", "type": 3}, {"name": "m_gameCacheName", "type": 54}, {"name": "m_gameOptions", "type": 55}, {"name": "m_gameSpeed", "type": 56}, {"name": "m_gameType", "type": 56}, {"name": "m_maxUsers", "type": 5}, {"name": "m_maxObservers", "type": 5}, {"name": "m_maxPlayers", "type": 5}, {"name": "m_maxTeams", "type": 57}, {"name": "m_maxColors", "type": 0}, {"name": "m_maxRaces", "type": 58}, {"name": "m_maxControls", "type": 15}, {"name": "m_mapSizeX", "type": 15}, {"name": "m_mapSizeY", "type": 15}, {"name": "m_mapFileSyncChecksum", "type": 3}, {"name": "m_mapFileName", "type": 41}, {"name": "m_mapAuthorName", "type": 47}, {"name": "m_modFileSyncChecksum", "type": 3}, {"name": "m_slotDescriptions", "type": 62}, {"name": "m_defaultDifficulty", "type": 0}, {"name": "m_cacheHandles", "type": 64}, {"name": "m_isBlizzardMap", "type": 46}, {"name": "m_isPremadeFFA", "type": 46}]},
+		{"kind": "int", "bounds": [0, 7]},
+		{"kind": "array", "bounds": [0, 5], "type": 3},
+		{"kind": "struct", "fields": [{"name": "m_control", "type": 15}, {"name": "m_userId", "type": 50}, {"name": "m_teamId", "type": 9}, {"name": "m_colorPref", "type": 50}, {"name": "m_unknown", "type": 19}, {"name": "m_handicap", "type": 66}, {"name": "m_observe", "type": 32}, {"name": "m_rewards", "type": 67}]},
+		{"kind": "array", "bounds": [0, 5], "type": 68},
+		{"kind": "struct", "fields": [{"name": "m_phase", "type": 56}, {"name": "m_maxUsers", "type": 5}, {"name": "m_maxObservers", "type": 5}, {"name": "m_slots", "type": 69}, {"name": "m_randomSeed", "type": 3}, {"name": "m_hostUserId", "type": 50}, {"name": "m_isSinglePlayer", "type": 46}, {"name": "m_gameDuration", "type": 3}, {"name": "m_defaultDifficulty", "type": 0}]},
+		{"kind": "struct", "fields": [{"name": "m_userInitialData", "type": 53}, {"name": "m_gameDescription", "type": 65}, {"name": "m_lobbyState", "type": 70}]},
+		{"kind": "struct", "fields": [{"name": "m_syncLobbyState", "type": 71}]},
+		{"kind": "null"},
+		{"kind": "struct", "fields": [{"name": "m_behaviorLink", "type": 19}]},
+		{"kind": "struct", "fields": [{"name": "m_vitalType", "type": 32}]},
+		{"kind": "choice", "bounds": [0, 2], "fields": [{"name": "None", "type": 73, "tag": 0}, {"name": "Ability", "type": 23, "tag": 1}, {"name": "Behavior", "type": 74, "tag": 2}, {"name": "Vitals", "type": 75, "tag": 3}]},
+		{"kind": "struct", "fields": [{"name": "m_announcement", "type": 76}, {"name": "m_announceLink", "type": 19}, {"name": "m_otherUnitTag", "type": 3}, {"name": "m_unitTag", "type": 3}]}
+	]
+}
+]`
+
+// builtinFallbackProtocols are used for builds after the built-in
+// protocols.  replay.details, replay.attributes.events and
+// replay.tracker.events kept their layout through later builds, so they
+// only describe those and the replay's other files can't be read
+// without a protocol for its build.  Tracker events start at 25604.
+const builtinFallbackProtocols = `[
+{
+	"name": "fallback-15405",
+	"minBuild": 15405,
+	"maxBuild": 25603,
+	"fallback": true
+},
+{
+	"name": "fallback-25604",
+	"minBuild": 25604,
+	"maxBuild": 0,
+	"fallback": true,
+	"headers": {
+		"tracker": {"deltaTypeId": 5, "userTypeId": -1, "idTypeId": 0}
+	},
+	"events": {
+		"tracker": [
+			{"id": 0, "name": "PlayerStats", "typeId": 9},
+			{"id": 1, "name": "UnitBorn", "typeId": 12},
+			{"id": 2, "name": "UnitDied", "typeId": 15},
+			{"id": 3, "name": "UnitOwnerChange", "typeId": 16},
+			{"id": 4, "name": "UnitTypeChange", "typeId": 17},
+			{"id": 5, "name": "Upgrade", "typeId": 18},
+			{"id": 6, "name": "UnitInit", "typeId": 12},
+			{"id": 7, "name": "UnitDone", "typeId": 19},
+			{"id": 8, "name": "UnitPositions", "typeId": 21},
+			{"id": 9, "name": "PlayerSetup", "typeId": 22}
+		]
+	},
+	"typeInfos": [
+		{"kind": "int", "bounds": [0, 7]},
+		{"kind": "int", "bounds": [0, 6]},
+		{"kind": "int", "bounds": [0, 14]},
+		{"kind": "int", "bounds": [0, 22]},
+		{"kind": "int", "bounds": [0, 32]},
+		{"kind": "choice", "bounds": [0, 2], "fields": [{"name": "m_uint6", "type": 1, "tag": 0}, {"name": "m_uint14", "type": 2, "tag": 1}, {"name": "m_uint22", "type": 3, "tag": 2}, {"name": "m_uint32", "type": 4, "tag": 3}]},
+		{"kind": "int", "bounds": [0, 4]},
+		{"kind": "int", "bounds": [-2147483648, 32]},
+		{"kind": "struct", "fields": [{"name": "m_scoreValueMineralsCurrent", "type": 7, "tag": 0}, {"name": "m_scoreValueVespeneCurrent", "type": 7, "tag": 1}, {"name": "m_scoreValueMineralsCollectionRate", "type": 7, "tag": 2}, {"name": "m_scoreValueVespeneCollectionRate", "type": 7, "tag": 3}, {"name": "m_scoreValueWorkersActiveCount", "type": 7, "tag": 4}, {"name": "m_scoreValueMineralsUsedInProgressArmy", "type": 7, "tag": 5}, {"name": "m_scoreValueMineralsUsedInProgressEconomy", "type": 7, "tag": 6}, {"name": "m_scoreValueMineralsUsedInProgressTechnology", "type": 7, "tag": 7}, {"name": "m_scoreValueVespeneUsedInProgressArmy", "type": 7, "tag": 8}, {"name": "m_scoreValueVespeneUsedInProgressEconomy", "type": 7, "tag": 9}, {"name": "m_scoreValueVespeneUsedInProgressTechnology", "type": 7, "tag": 10}, {"name": "m_scoreValueMineralsUsedCurrentArmy", "type": 7, "tag": 11}, {"name": "m_scoreValueMineralsUsedCurrentEconomy", "type": 7, "tag": 12}, {"name": "m_scoreValueMineralsUsedCurrentTechnology", "type": 7, "tag": 13}, {"name": "m_scoreValueVespeneUsedCurrentArmy", "type": 7, "tag": 14}, {"name": "m_scoreValueVespeneUsedCurrentEconomy", "type": 7, "tag": 15}, {"name": "m_scoreValueVespeneUsedCurrentTechnology", "type": 7, "tag": 16}, {"name": "m_scoreValueMineralsLostArmy", "type": 7, "tag": 17}, {"name": "m_scoreValueMineralsLostEconomy", "type": 7, "tag": 18}, {"name": "m_scoreValueMineralsLostTechnology", "type": 7, "tag": 19}, {"name": "m_scoreValueVespeneLostArmy", "type": 7, "tag": 20}, {"name": "m_scoreValueVespeneLostEconomy", "type": 7, "tag": 21}, {"name": "m_scoreValueVespeneLostTechnology", "type": 7, "tag": 22}, {"name": "m_scoreValueMineralsKilledArmy", "type": 7, "tag": 23}, {"name": "m_scoreValueMineralsKilledEconomy", "type": 7, "tag": 24}, {"name": "m_scoreValueMineralsKilledTechnology", "type": 7, "tag": 25}, {"name": "m_scoreValueVespeneKilledArmy", "type": 7, "tag": 26}, {"name": "m_scoreValueVespeneKilledEconomy", "type": 7, "tag": 27}, {"name": "m_scoreValueVespeneKilledTechnology", "type": 7, "tag": 28}, {"name": "m_scoreValueFoodUsed", "type": 7, "tag": 29}, {"name": "m_scoreValueFoodMade", "type": 7, "tag": 30}, {"name": "m_scoreValueMineralsUsedActiveForces", "type": 7, "tag": 31}, {"name": "m_scoreValueVespeneUsedActiveForces", "type": 7, "tag": 32}, {"name": "m_scoreValueMineralsFriendlyFireArmy", "type": 7, "tag": 33}, {"name": "m_scoreValueMineralsFriendlyFireEconomy", "type": 7, "tag": 34}, {"name": "m_scoreValueMineralsFriendlyFireTechnology", "type": 7, "tag": 35}, {"name": "m_scoreValueVespeneFriendlyFireArmy", "type": 7, "tag": 36}, {"name": "m_scoreValueVespeneFriendlyFireEconomy", "type": 7, "tag": 37}, {"name": "m_scoreValueVespeneFriendlyFireTechnology", "type": 7, "tag": 38}]},
+		{"kind": "struct", "fields": [{"name": "m_playerId", "type": 6, "tag": 0}, {"name": "m_stats", "type": 8, "tag": 1}]},
+		{"kind": "blob", "bounds": [0, 8]},
+		{"kind": "int", "bounds": [0, 8]},
+		{"kind": "struct", "fields": [{"name": "m_unitTagIndex", "type": 4, "tag": 0}, {"name": "m_unitTagRecycle", "type": 4, "tag": 1}, {"name": "m_unitTypeName", "type": 10, "tag": 2}, {"name": "m_controlPlayerId", "type": 6, "tag": 3}, {"name": "m_upkeepPlayerId", "type": 6, "tag": 4}, {"name": "m_x", "type": 11, "tag": 5}, {"name": "m_y", "type": 11, "tag": 6}]},
+		{"kind": "optional", "type": 6},
+		{"kind": "optional", "type": 4},
+		{"kind": "struct", "fields": [{"name": "m_unitTagIndex", "type": 4, "tag": 0}, {"name": "m_unitTagRecycle", "type": 4, "tag": 1}, {"name": "m_killerPlayerId", "type": 13, "tag": 2}, {"name": "m_x", "type": 11, "tag": 3}, {"name": "m_y", "type": 11, "tag": 4}, {"name": "m_killerUnitTagIndex", "type": 14, "tag": 5}, {"name": "m_killerUnitTagRecycle", "type": 14, "tag": 6}]},
+		{"kind": "struct", "fields": [{"name": "m_unitTagIndex", "type": 4, "tag": 0}, {"name": "m_unitTagRecycle", "type": 4, "tag": 1}, {"name": "m_controlPlayerId", "type": 6, "tag": 2}, {"name": "m_upkeepPlayerId", "type": 6, "tag": 3}]},
+		{"kind": "struct", "fields": [{"name": "m_unitTagIndex", "type": 4, "tag": 0}, {"name": "m_unitTagRecycle", "type": 4, "tag": 1}, {"name": "m_unitTypeName", "type": 10, "tag": 2}]},
+		{"kind": "struct", "fields": [{"name": "m_playerId", "type": 6, "tag": 0}, {"name": "m_upgradeTypeName", "type": 10, "tag": 1}, {"name": "m_count", "type": 7, "tag": 2}]},
+		{"kind": "struct", "fields": [{"name": "m_unitTagIndex", "type": 4, "tag": 0}, {"name": "m_unitTagRecycle", "type": 4, "tag": 1}]},
+		{"kind": "array", "bounds": [0, 10], "type": 7},
+		{"kind": "struct", "fields": [{"name": "m_firstUnitIndex", "type": 4, "tag": 0}, {"name": "m_items", "type": 20, "tag": 1}]},
+		{"kind": "struct", "fields": [{"name": "m_playerId", "type": 6, "tag": 0}, {"name": "m_type", "type": 4, "tag": 1}, {"name": "m_userId", "type": 14, "tag": 2}, {"name": "m_slotId", "type": 14, "tag": 3}]}
+	]
+}
+]`
@@ -29,7 +29,7 @@
 package sc2
 
 import (
-	"fmt"
+	"encoding/xml"
 	"github.com/aphistic/go.Zamara/mpq"
 	"io"
 	"time"
@@ -44,6 +44,7 @@ const (
 	GameFfa
 	Game6v6
 	GameCustom
+	Game5v5
 )
 
 const (
@@ -63,21 +64,39 @@ const (
 )
 
 type Replay struct {
+	XMLName xml.Name `xml:"replay" json:"-"`
+
 	mpq *mpq.Mpq
 
-	MapName string
+	outcomesResolved bool
+
+	Version   Version       `xml:"version" json:"version"`
+	Protocol  *Protocol     `xml:"-" json:"-"`
+	GameLoops int           `xml:"gameLoops" json:"gameLoops"`
+	Duration  time.Duration `xml:"duration" json:"duration"` // Real time, from the loops and game speed
+
+	MapName string `xml:"mapName" json:"mapName"`
+
+	Timestamp time.Time `xml:"timestamp" json:"timestamp"`
 
-	Timestamp time.Time
+	GameType     int `xml:"gameType" json:"gameType"`
+	GameSpeed    int `xml:"gameSpeed" json:"gameSpeed"`
+	GameCategory int `xml:"gameCategory" json:"gameCategory"`
 
-	GameType     int
-	GameSpeed    int
-	GameCategory int
+	GameOptions  GameOptions   `xml:"gameOptions" json:"gameOptions"`
+	RandomSeed   int64         `xml:"randomSeed" json:"randomSeed"`
+	MapSizeX     int           `xml:"mapSizeX" json:"mapSizeX"`
+	MapSizeY     int           `xml:"mapSizeY" json:"mapSizeY"`
+	CacheHandles []CacheHandle `xml:"cacheHandles>cacheHandle" json:"cacheHandles"`
+	Slots        []*LobbySlot  `xml:"slots>slot" json:"slots"` // Lobby slots, from replay.initData
 
-	Players []*Player
+	// Attributes are the lobby settings in replay.attributes.events.
+	Attributes Attributes `xml:"attributes" json:"attributes"`
+
+	Players []*Player `xml:"players>player" json:"players"`
 }
 
 func NewReplay(reader io.ReadSeeker) (replay *Replay, err error) {
-	fmt.Printf("")
 	replay = new(Replay)
 	err = replay.load(reader)
 	if err != nil {
@@ -87,14 +106,39 @@ func NewReplay(reader io.ReadSeeker) (replay *Replay, err error) {
 	return replay, nil
 }
 
+// NewReplayFromMpq reads a replay from an MPQ that's already open, so
+// the caller can share it or configure it first (e.g. enable its cache).
+func NewReplayFromMpq(archive *mpq.Mpq) (replay *Replay, err error) {
+	replay = new(Replay)
+	err = replay.loadMpq(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	return replay, nil
+}
+
 func (replay *Replay) load(reader io.ReadSeeker) (err error) {
+	archive, err := mpq.NewMpq(reader)
+	if err != nil {
+		return
+	}
+
+	return replay.loadMpq(archive)
+}
+
+func (replay *Replay) loadMpq(archive *mpq.Mpq) (err error) {
 	replay.Players = make([]*Player, 0)
+	replay.mpq = archive
 
-	replay.mpq, err = mpq.NewMpq(reader)
+	err = replay.loadHeader()
+	if err != nil {
+		return
+	}
+	replay.Protocol, err = LookupProtocol(replay.Version.BaseBuild)
 	if err != nil {
 		return
 	}
-
 	err = replay.loadDetails()
 	if err != nil {
 		return
@@ -103,19 +147,46 @@ func (replay *Replay) load(reader io.ReadSeeker) (err error) {
 	if err != nil {
 		return
 	}
+	err = replay.loadInitData()
+	if err != nil {
+		return
+	}
+	replay.loadPlayerAttributes()
+	replay.loadTeams()
+	replay.setDuration()
 
 	return
 }
 
-func (replay *Replay) loadDetails() (err error) {
-	file, err := replay.mpq.File("replay.details")
-	if err != nil {
-		return
+// UserPlayer returns the player who sent events as a user, or nil for
+// users that aren't in replay.details, such as observers and the
+// system.  User IDs count from 0 as LobbySlot.UserId does and are found
+// through the lobby slots, or without slots taken to follow the order
+// of replay.details.
+func (replay *Replay) UserPlayer(userId int) *Player {
+	if len(replay.Slots) == 0 {
+		if userId < 0 || userId >= len(replay.Players) {
+			return nil
+		}
+		return replay.Players[userId]
 	}
 
-	buffer := make([]byte, file.FileSize)
-	_, err = replay.mpq.Read(buffer)
-	if err != nil && err != mpq.EOF {
+	slot := replay.userSlot(userId)
+	if slot == nil {
+		return nil
+	}
+	for _, player := range replay.Players {
+		if player.slot == slot {
+			return player
+		}
+	}
+
+	return nil
+}
+
+func (replay *Replay) loadDetails() (err error) {
+	buffer, err := replay.mpq.ReadFile("replay.details")
+	if err != nil {
 		return
 	}
 
@@ -124,20 +195,22 @@ func (replay *Replay) loadDetails() (err error) {
 		return
 	}
 
-	totalPlayers := value.i(0).size()
+	protocol := replay.Protocol
+	players := protocol.field(value, "details.players")
+	totalPlayers := players.size()
 	replay.Players = make([]*Player, totalPlayers)
 	for idx := int64(0); idx < totalPlayers; idx++ {
-		player, _ := newPlayer(value.i(0).i(idx))
+		player, _ := newPlayer(protocol, players.i(idx))
 		replay.Players[idx] = player
 	}
 
-	replay.MapName = value.i(1).asString()
+	replay.MapName = protocol.field(value, "details.mapName").asString()
 
-	tzo := value.i(6).asInt64()
+	tzo := protocol.field(value, "details.timezone").asInt64()
 	tzo = tzo / 10000000
 	loc := time.FixedZone("unknown", int(tzo))
 
-	ts := value.i(5).asInt64()
+	ts := protocol.field(value, "details.timestamp").asInt64()
 	ts = (ts - 116444735995904000) / 10000000
 	utc := time.Unix(ts, 0).UTC()
 	t := time.Date(utc.Year(), utc.Month(), utc.Day(),
@@ -149,14 +222,10 @@ func (replay *Replay) loadDetails() (err error) {
 }
 
 func (replay *Replay) loadAttributes() (err error) {
-	file, err := replay.mpq.File("replay.attributes.events")
-	if err != nil {
-		return
-	}
+	replay.Attributes = make(Attributes)
 
-	buffer := make([]byte, file.FileSize)
-	_, err = replay.mpq.Read(buffer)
-	if err != nil && err != mpq.EOF {
+	buffer, err := replay.mpq.ReadFile("replay.attributes.events")
+	if err != nil {
 		return
 	}
 
@@ -166,10 +235,9 @@ func (replay *Replay) loadAttributes() (err error) {
 	}
 
 	for idx := 0; idx < len(attrs.attributes); idx++ {
-		attr := attrs.attributes[idx]
-		if attr.isPlayer() {
-			replay.processPlayerAttribute(attr)
-		} else {
+		attr := newAttribute(replay.Protocol, attrs.attributes[idx])
+		replay.Attributes.add(attr)
+		if !attr.isPlayer() {
 			replay.processGlobalAttribute(attr)
 		}
 	}
@@ -177,123 +245,51 @@ func (replay *Replay) loadAttributes() (err error) {
 	return
 }
 
-func (replay *Replay) processPlayerAttribute(attr *replayAttribute) (err error) {
-	playerIdx := attr.playerId - 1
-
-	typeMap := map[string]int{
-		"Humn": PlayerHuman,
-		"Comp": PlayerComputer,
-	}
-	raceMap := map[string]int{
-		"RAND": RaceRandom,
-		"Terr": RaceTerran,
-		"Prot": RaceProtoss,
-		"Zerg": RaceZerg,
-	}
-	difficultyMap := map[string]int{
-		"VyEy": DifficultyVeryEasy,
-		"Easy": DifficultyEasy,
-		"Medi": DifficultyMedium,
-		"Hard": DifficultyHard,
-		"VyHd": DifficultyVeryHard,
-		"Insa": DifficultyInsane,
-	}
-	colorMap := map[string]int{
-		"tc01": ColorRed,
-		"tc02": ColorBlue,
-		"tc03": ColorTeal,
-		"tc04": ColorPurple,
-		"tc05": ColorYellow,
-		"tc06": ColorOrange,
-		"tc07": ColorGreen,
-		"tc08": ColorLightPink,
-		"tc09": ColorViolet,
-		"tc10": ColorLightGrey,
-		"tc11": ColorDarkGreen,
-		"tc12": ColorBrown,
-		"tc13": ColorLightGreen,
-		"tc14": ColorDarkGrey,
-		"tc15": ColorPink,
+// loadPlayerAttributes sets the players' attributes once their lobby
+// slots are known, as player attributes are scoped by slot.
+func (replay *Replay) loadPlayerAttributes() {
+	for idx, player := range replay.Players {
+		scope := replay.playerScope(idx, player)
+		for _, scopes := range replay.Attributes {
+			if attr := scopes[scope]; attr != nil {
+				replay.processPlayerAttribute(player, attr)
+			}
+		}
 	}
+}
 
-	switch attr.id {
+func (replay *Replay) processPlayerAttribute(player *Player, attr *Attribute) (err error) {
+	switch attr.Id {
 	case AttrPType:
-		val, exists := typeMap[attr.strValue]
-		if !exists {
-			val = PlayerUnknown
-		}
-		replay.Players[playerIdx].Type = val
+		player.Type = attr.Value
 		break
 	case AttrPChosenRace:
-		val, exists := raceMap[attr.strValue]
-		if !exists {
-			val = RaceUnknown
-		}
-		replay.Players[playerIdx].ChosenRace = val
+		player.ChosenRace = attr.Value
 		break
 	case AttrPDifficulty:
-		val, exists := difficultyMap[attr.strValue]
-		if !exists {
-			val = DifficultyUnknown
-		}
-		replay.Players[playerIdx].Difficulty = val
+		player.Difficulty = attr.Value
+		break
+	case AttrPHandicap:
+		player.Handicap = attr.Value
 		break
 	case AttrPNamedColor:
-		val, exists := colorMap[attr.strValue]
-		if !exists {
-			val = ColorUnknown
-		}
-		replay.Players[playerIdx].NamedColor = val
+		player.NamedColor = attr.Value
 		break
 	}
 
 	return
 }
 
-func (replay *Replay) processGlobalAttribute(attr *replayAttribute) (err error) {
-	typeMap := map[string]int{
-		"1v1":  Game1v1,
-		"2v2":  Game2v2,
-		"3v3":  Game3v3,
-		"4v4":  Game4v4,
-		"FFA":  GameFfa,
-		"6v6":  Game6v6,
-		"Cust": GameCustom,
-	}
-	speedMap := map[string]int{
-		"Slor": SpeedSlower,
-		"Slow": SpeedSlow,
-		"Norm": SpeedNormal,
-		"Fast": SpeedFast,
-		"Fasr": SpeedFaster,
-	}
-	categoryMap := map[string]int{
-		"Priv": CategoryPrivate,
-		"Amm":  CategoryLadder,
-		"Pub":  CategoryPublic,
-	}
-
-	switch attr.id {
+func (replay *Replay) processGlobalAttribute(attr *Attribute) (err error) {
+	switch attr.Id {
 	case AttrGGameType:
-		val, exists := typeMap[attr.strValue]
-		if !exists {
-			val = GameUnknown
-		}
-		replay.GameType = val
+		replay.GameType = attr.Value
 		break
 	case AttrGGameSpeed:
-		val, exists := speedMap[attr.strValue]
-		if !exists {
-			val = SpeedUnknown
-		}
-		replay.GameSpeed = val
+		replay.GameSpeed = attr.Value
 		break
 	case AttrGGameCategory:
-		val, exists := categoryMap[attr.strValue]
-		if !exists {
-			val = CategoryUnknown
-		}
-		replay.GameCategory = val
+		replay.GameCategory = attr.Value
 		break
 	}
 
@@ -29,6 +29,10 @@
 package sc2
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/aphistic/go.Zamara/mpq"
 	. "launchpad.net/gocheck"
 	"os"
 	"time"
@@ -47,6 +51,12 @@ func (s *ReplaySuite) TestNewReplay(c *C) {
 
 	c.Check(replay.MapName, Equals, "Discord IV")
 
+	// Header
+	c.Check(replay.Version, Equals, Version{1, 0, 1, 16195, 15405})
+	c.Check(replay.Version.String(), Equals, "1.0.1.16195")
+	c.Check(replay.GameLoops, Equals, 22571)
+	c.Check(replay.Duration.Truncate(time.Second), Equals, 16*time.Minute+47*time.Second)
+
 	tz := time.FixedZone("unknown", -14400)
 	time := time.Date(2010, 7, 31, 3, 56, 54, 0, tz)
 	c.Check(replay.Timestamp, DeepEquals, time)
@@ -86,7 +96,7 @@ func (s *ReplaySuite) TestNewReplay(c *C) {
 	c.Check(player.Color.R, Equals, 0)
 	c.Check(player.Color.G, Equals, 66)
 	c.Check(player.Color.B, Equals, 255)
-	c.Check(player.Team, Equals, 1)
+	c.Check(player.Team, Equals, 0)
 	c.Check(player.Handicap, Equals, 100)
 	c.Check(player.Outcome, Equals, 0)
 
@@ -118,7 +128,89 @@ func (s *ReplaySuite) TestNewReplay(c *C) {
 	c.Check(player.Color.R, Equals, 84)
 	c.Check(player.Color.G, Equals, 0)
 	c.Check(player.Color.B, Equals, 129)
-	c.Check(player.Team, Equals, 0)
-	c.Check(player.Handicap, Equals, 0) // TODO: Confirm value
+	c.Check(player.Team, Equals, 1)
+	c.Check(player.Handicap, Equals, 100)
 	c.Check(player.Outcome, Equals, 0)
 }
+
+func (s *ReplaySuite) TestNewReplayFromMpq(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	archive, err := mpq.NewMpq(reader)
+	c.Assert(err, IsNil)
+	archive.EnableCache(1024 * 1024)
+
+	replay, err := NewReplayFromMpq(archive)
+	c.Assert(err, IsNil)
+	c.Check(replay.MapName, Equals, "Discord IV")
+	c.Check(len(replay.Players), Equals, 4)
+	c.Check(archive.CacheStats().Misses, Equals, uint64(3))
+}
+
+func (s *ReplaySuite) TestReplayJson(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	replay, err := NewReplay(reader)
+	c.Assert(err, IsNil)
+
+	data, err := json.Marshal(replay)
+	c.Assert(err, IsNil)
+
+	decoded := make(map[string]interface{})
+	c.Assert(json.Unmarshal(data, &decoded), IsNil)
+	c.Check(decoded["mapName"], Equals, "Discord IV")
+	c.Check(decoded["timestamp"], Equals, "2010-07-31T03:56:54-04:00")
+	c.Check(decoded["gameType"], Equals, float64(Game2v2))
+
+	players := decoded["players"].([]interface{})
+	c.Assert(players, HasLen, 4)
+	player := players[0].(map[string]interface{})
+	c.Check(player["name"], Equals, "TehPartE")
+	c.Check(player["color"], DeepEquals, map[string]interface{}{
+		"a": float64(255), "r": float64(180), "g": float64(20), "b": float64(30),
+	})
+}
+
+func (s *ReplaySuite) TestReplayXml(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	replay, err := NewReplay(reader)
+	c.Assert(err, IsNil)
+
+	data, err := xml.Marshal(replay)
+	c.Assert(err, IsNil)
+
+	var decoded struct {
+		MapName string `xml:"mapName"`
+		Players []struct {
+			Name  string `xml:"name"`
+			Color struct {
+				R int `xml:"r"`
+			} `xml:"color"`
+		} `xml:"players>player"`
+	}
+	c.Assert(xml.Unmarshal(data, &decoded), IsNil)
+	c.Check(decoded.MapName, Equals, "Discord IV")
+	c.Assert(decoded.Players, HasLen, 4)
+	c.Check(decoded.Players[1].Name, Equals, "totsgerber")
+	c.Check(decoded.Players[0].Color.R, Equals, 180)
+}
+
+func (s *ReplaySuite) TestReplayWithoutHeader(c *C) {
+	buffer := new(bytes.Buffer)
+	w := mpq.NewWriter(buffer)
+	c.Assert(w.AddFile("replay.details", []byte{}), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	archive, err := mpq.NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+
+	_, err = NewReplayFromMpq(archive)
+	c.Check(err, Equals, NoHeader)
+}
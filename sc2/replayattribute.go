@@ -30,23 +30,170 @@ package sc2
 
 import (
 	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// The scope of global attributes, player attributes are scoped to the
+// player's ID counting from 1.
+const AttrScopeGlobal = 0x10
+
 const (
-	AttrGGameType     = 0x07D1
-	AttrGGameSpeed    = 0x0BB8
-	AttrGGameCategory = 0x0BC1
+	AttrGRules           = 0x03E8
+	AttrGPremadeGame     = 0x03E9
+	AttrGCustomTeams     = 0x07D0
+	AttrGGameType        = 0x07D1
+	AttrGGameSpeed       = 0x0BB8
+	AttrGLobbyDelay      = 0x0BBE
+	AttrGGameCategory    = 0x0BC1
+	AttrGLockedAlliances = 0x0BC2
 )
 
 const (
-	AttrPType       = 0x01F4
-	AttrPChosenRace = 0x0BB9
-	AttrPDifficulty = 0x0BBC
-	AttrPHandicap   = 0x0BBB
-	AttrPNamedColor = 0x0BBA
+	AttrPType         = 0x01F4
+	AttrPTeams1v1     = 0x07D2
+	AttrPTeams2v2     = 0x07D3
+	AttrPTeams3v3     = 0x07D4
+	AttrPTeams4v4     = 0x07D5
+	AttrPTeamsFfa     = 0x07D6
+	AttrPTeams5v5     = 0x07D7
+	AttrPTeams6v6     = 0x07D8
+	AttrPTeamsCustom2 = 0x07DB
+	AttrPTeamsCustom3 = 0x07DC
+	AttrPChosenRace   = 0x0BB9
+	AttrPNamedColor   = 0x0BBA
+	AttrPHandicap     = 0x0BBB
+	AttrPDifficulty   = 0x0BBC
+	AttrPPlayerMode   = 0x0BBF
+	AttrPObserverType = 0x0BC0
 )
 
+// Player modes, the values of AttrPPlayerMode.
+const (
+	PlayerModeUnknown = iota
+	PlayerModeParticipant
+	PlayerModeWatcher
+)
+
+// Kinds of attribute values.
+const (
+	AttributeString = "string"
+	AttributeEnum   = "enum"
+	AttributeInt    = "int"
+	AttributeBool   = "bool"
+)
+
+// AttributeType names an attribute ID in a protocol.  Kind is how its
+// value decodes, Enum is the protocol enum enum values are looked up in.
+type AttributeType struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	Enum string `json:"enum"`
+}
+
+func (attrType *AttributeType) validate(enums map[string]map[string]int) (err error) {
+	switch attrType.Kind {
+	case AttributeString, AttributeInt, AttributeBool:
+	case AttributeEnum:
+		if _, found := enums[attrType.Enum]; !found {
+			return fmt.Errorf("unknown enum %v", attrType.Enum)
+		}
+	default:
+		return fmt.Errorf("unknown kind %v", attrType.Kind)
+	}
+
+	return nil
+}
+
+// Attribute is a lobby setting from replay.attributes.events.  Raw is
+// the value as the game wrote it.  Value is what it decodes to, the
+// package constant for enums, the number for ints and 1 for yes, or 0
+// when the protocol doesn't know the attribute.
+type Attribute struct {
+	Id    int    `xml:"id" json:"id"`
+	Scope int    `xml:"scope" json:"scope"`
+	Name  string `xml:"name" json:"name"` // Empty if the protocol doesn't know the attribute
+	Raw   string `xml:"raw" json:"raw"`
+	Value int    `xml:"value" json:"value"`
+}
+
+// Attributes holds a replay's attributes keyed by ID and then scope.
+type Attributes map[int]map[int]*Attribute
+
+// Get returns an attribute, or nil if the replay doesn't have it.
+func (attrs Attributes) Get(id int, scope int) *Attribute {
+	return attrs[id][scope]
+}
+
+// Global returns a global attribute, or nil if the replay doesn't have
+// it.
+func (attrs Attributes) Global(id int) *Attribute {
+	return attrs.Get(id, AttrScopeGlobal)
+}
+
+// List returns the attributes sorted by ID and then scope.
+func (attrs Attributes) List() []*Attribute {
+	list := make([]*Attribute, 0)
+	for _, scopes := range attrs {
+		for _, attr := range scopes {
+			list = append(list, attr)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Id != list[j].Id {
+			return list[i].Id < list[j].Id
+		}
+		return list[i].Scope < list[j].Scope
+	})
+
+	return list
+}
+
+// MarshalXML writes the attributes as a list, XML has no maps.
+func (attrs Attributes) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	list := struct {
+		Attributes []*Attribute `xml:"attribute"`
+	}{attrs.List()}
+	return encoder.EncodeElement(list, start)
+}
+
+func (attrs Attributes) add(attr *Attribute) {
+	if attrs[attr.Id] == nil {
+		attrs[attr.Id] = make(map[int]*Attribute)
+	}
+	attrs[attr.Id][attr.Scope] = attr
+}
+
+// newAttribute decodes a raw attribute with the protocol's table.
+func newAttribute(protocol *Protocol, raw *replayAttribute) *Attribute {
+	attr := &Attribute{
+		Id:    int(raw.id),
+		Scope: int(raw.playerId),
+		Raw:   raw.strValue,
+	}
+
+	attrType, found := protocol.Attributes[attr.Id]
+	if !found {
+		return attr
+	}
+	attr.Name = attrType.Name
+	switch attrType.Kind {
+	case AttributeEnum:
+		attr.Value = protocol.enum(attrType.Enum, attr.Raw)
+	case AttributeInt:
+		attr.Value, _ = strconv.Atoi(attr.Raw)
+	case AttributeBool:
+		if attr.Raw == "yes" {
+			attr.Value = 1
+		}
+	}
+
+	return attr
+}
+
 type replayAttribute struct {
 	header   uint32
 	id       uint32
@@ -90,10 +237,10 @@ func (attr *replayAttribute) load(data []byte) (size int64, err error) {
 	return 13, nil
 }
 
-func (attr *replayAttribute) isGlobal() (result bool) {
-	return attr.playerId == 0x10
+func (attr *Attribute) isGlobal() (result bool) {
+	return attr.Scope == AttrScopeGlobal
 }
 
-func (attr *replayAttribute) isPlayer() (result bool) {
+func (attr *Attribute) isPlayer() (result bool) {
 	return !attr.isGlobal()
 }
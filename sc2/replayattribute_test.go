@@ -0,0 +1,92 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"encoding/xml"
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type ReplayAttributeSuite struct {
+	replay *Replay
+}
+
+var _ = Suite(&ReplayAttributeSuite{})
+
+func (s *ReplayAttributeSuite) SetUpSuite(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	s.replay, err = NewReplay(reader)
+	c.Assert(err, IsNil)
+}
+
+func (s *ReplayAttributeSuite) TestAttributes(c *C) {
+	attrs := s.replay.Attributes
+	c.Check(attrs.List(), HasLen, 80)
+
+	c.Check(attrs.Get(AttrPChosenRace, 2), DeepEquals, &Attribute{
+		Id: AttrPChosenRace, Scope: 2, Name: "race", Raw: "Zerg", Value: RaceZerg,
+	})
+	c.Check(attrs.Get(AttrPHandicap, 4).Value, Equals, 100)
+	c.Check(attrs.Get(AttrPTeams2v2, 3).Value, Equals, 2)
+	c.Check(attrs.Get(AttrPPlayerMode, 1).Value, Equals, PlayerModeParticipant)
+	c.Check(attrs.Get(AttrPObserverType, 1).Value, Equals, ObserveSpectator)
+	c.Check(attrs.Get(AttrPChosenRace, AttrScopeGlobal), IsNil)
+
+	c.Check(attrs.Global(AttrGGameType).Value, Equals, Game2v2)
+	c.Check(attrs.Global(AttrGCustomTeams).Value, Equals, 2)
+	c.Check(attrs.Global(AttrGLobbyDelay).Value, Equals, 7)
+	c.Check(attrs.Global(AttrGPremadeGame).Value, Equals, 1)
+	c.Check(attrs.Global(AttrGLockedAlliances).Value, Equals, 1)
+	c.Check(attrs.Global(AttrGRules).Raw, Equals, "Dflt")
+
+	// Attributes the protocol doesn't know are kept raw
+	unknown := attrs.Get(0x07E1, 4)
+	c.Check(unknown.Name, Equals, "")
+	c.Check(unknown.Raw, Equals, "T4")
+	c.Check(unknown.Value, Equals, 0)
+}
+
+func (s *ReplayAttributeSuite) TestAttributesXml(c *C) {
+	attrs := Attributes{}
+	attrs.add(&Attribute{Id: AttrGGameSpeed, Scope: AttrScopeGlobal, Raw: "Fasr"})
+	attrs.add(&Attribute{Id: AttrPType, Scope: 2, Raw: "Humn"})
+	attrs.add(&Attribute{Id: AttrPType, Scope: 1, Raw: "Comp"})
+
+	data, err := xml.Marshal(attrs)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "<Attributes>"+
+		"<attribute><id>500</id><scope>1</scope><name></name><raw>Comp</raw><value>0</value></attribute>"+
+		"<attribute><id>500</id><scope>2</scope><name></name><raw>Humn</raw><value>0</value></attribute>"+
+		"<attribute><id>3000</id><scope>16</scope><name></name><raw>Fasr</raw><value>0</value></attribute>"+
+		"</Attributes>")
+}
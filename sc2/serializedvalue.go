@@ -34,20 +34,25 @@ import (
 	"fmt"
 )
 
+// Type tags of the versioned encoding used by serialized blobs.
 const (
-	ValueString byte = 0x02
-	ValueArray       = 0x04
-	ValueKey         = 0x05
-	ValueInt8        = 0x06
-	ValueInt32       = 0x07
-	ValueIntVlf      = 0x09
+	ValueArray    byte = 0x00
+	ValueBitArray      = 0x01
+	ValueString        = 0x02 // Blob
+	ValueChoice        = 0x03
+	ValueOptional      = 0x04
+	ValueKey           = 0x05 // Struct
+	ValueInt8          = 0x06
+	ValueInt32         = 0x07
+	ValueInt64         = 0x08
+	ValueIntVlf        = 0x09
 )
 
 var OutOfRange error = errors.New("Out of range")
 
 type serializedValue struct {
 	members []*serializedValue
-	keys    []byte
+	keys    []int64
 
 	valueType byte
 
@@ -67,62 +72,43 @@ func newSerializedValue(data []byte) (value *serializedValue, size int64, err er
 
 func (value *serializedValue) load(data []byte) (size int64, err error) {
 	value.members = []*serializedValue{}
-	value.keys = []byte{}
+	value.keys = []int64{}
 
 	if len(data) < 1 {
-		return
+		return 0, OutOfRange
 	}
 
+	var dataSize int64
 	switch data[0] {
-	case ValueString:
-		dataSize, err := value.loadString(data[1:])
-		if err != nil {
-			return 0, err
-		}
-		size += dataSize
-		break
 	case ValueArray:
-		dataSize, err := value.loadArray(data[1:])
-		if err != nil {
-			return 0, err
-		}
-		size += dataSize
-		break
+		dataSize, err = value.loadArray(data[1:])
+	case ValueBitArray:
+		dataSize, err = value.loadBitArray(data[1:])
+	case ValueString:
+		dataSize, err = value.loadString(data[1:])
+	case ValueChoice:
+		dataSize, err = value.loadChoice(data[1:])
+	case ValueOptional:
+		dataSize, err = value.loadOptional(data[1:])
 	case ValueKey:
-		dataSize, err := value.loadKey(data[1:])
-		if err != nil {
-			return 0, err
-		}
-		size += dataSize
-		break
+		dataSize, err = value.loadKey(data[1:])
 	case ValueInt8:
-		dataSize, err := value.loadInt8(data[1:])
-		if err != nil {
-			return 0, err
-		}
-		size += dataSize
-		break
+		dataSize, err = value.loadInt8(data[1:])
 	case ValueInt32:
-		dataSize, err := value.loadInt32(data[1:])
-		if err != nil {
-			return 0, err
-		}
-		size += dataSize
-		break
+		dataSize, err = value.loadInt32(data[1:])
+	case ValueInt64:
+		dataSize, err = value.loadInt64(data[1:])
 	case ValueIntVlf:
-		dataSize, err := value.loadIntVlf(data[1:])
-		if err != nil {
-			return 0, err
-		}
-		size += dataSize
-		break
+		dataSize, err = value.loadIntVlf(data[1:])
 	default:
-		err = fmt.Errorf("Unknown serialization type: %v", data[0])
-		break
+		return 0, fmt.Errorf("Unknown serialization type: %v", data[0])
+	}
+	if err != nil {
+		return 0, err
 	}
-	size++ // Add one for the "type" byte that's skipped
 
-	return size, nil
+	// Add one for the "type" byte that's skipped
+	return dataSize + 1, nil
 }
 
 func (value *serializedValue) loadString(data []byte) (size int64, err error) {
@@ -130,18 +116,24 @@ func (value *serializedValue) loadString(data []byte) (size int64, err error) {
 
 	var length int64
 	length, amountRead := value.readIntVlf(data)
+	if amountRead == 0 || length < 0 || amountRead+length > int64(len(data)) {
+		return 0, OutOfRange
+	}
 	value.stringValue = string(data[amountRead : amountRead+length])
 
-	return length + 1, nil
+	return amountRead + length, nil
 }
 
 func (value *serializedValue) loadArray(data []byte) (size int64, err error) {
 	value.valueType = ValueArray
 
-	offset := int64(2)
-	elements, readSize := value.readIntVlf(data[offset:])
-	offset += readSize
-	value.keys = make([]byte, elements)
+	elements, offset := value.readIntVlf(data)
+	// Each element takes at least a byte, so a count larger than
+	// what's left can't be valid
+	if offset == 0 || elements < 0 || elements > int64(len(data))-offset {
+		return 0, OutOfRange
+	}
+	value.keys = make([]int64, elements)
 	value.members = make([]*serializedValue, elements)
 
 	for idx := int64(0); idx < elements; idx++ {
@@ -150,22 +142,86 @@ func (value *serializedValue) loadArray(data []byte) (size int64, err error) {
 			return 0, err
 		}
 		offset += readSize
+		value.keys[idx] = idx
 		value.members[idx] = newValue
 	}
 
 	return offset, nil
 }
 
+// loadBitArray keeps the bytes of the array in stringValue and its
+// length in bits in intValue.
+func (value *serializedValue) loadBitArray(data []byte) (size int64, err error) {
+	value.valueType = ValueBitArray
+
+	length, amountRead := value.readIntVlf(data)
+	byteLength := (length + 7) / 8
+	if amountRead == 0 || length < 0 || amountRead+byteLength > int64(len(data)) {
+		return 0, OutOfRange
+	}
+	value.intValue = length
+	value.stringValue = string(data[amountRead : amountRead+byteLength])
+
+	return amountRead + byteLength, nil
+}
+
+func (value *serializedValue) loadChoice(data []byte) (size int64, err error) {
+	tag, offset := value.readIntVlf(data)
+	if offset == 0 {
+		return 0, OutOfRange
+	}
+
+	newValue, readSize, err := newSerializedValue(data[offset:])
+	if err != nil {
+		return 0, err
+	}
+	value.valueType = ValueChoice
+	value.keys = []int64{tag}
+	value.members = []*serializedValue{newValue}
+
+	return offset + readSize, nil
+}
+
+// loadOptional loads a value that's present in place of the optional,
+// so callers don't see the difference.  Missing values keep the
+// optional type with no members.
+func (value *serializedValue) loadOptional(data []byte) (size int64, err error) {
+	if len(data) < 1 {
+		return 0, OutOfRange
+	}
+
+	if data[0] == 0 {
+		value.valueType = ValueOptional
+		return 1, nil
+	}
+
+	size, err = value.load(data[1:])
+	if err != nil {
+		return 0, err
+	}
+
+	return size + 1, nil
+}
+
 func (value *serializedValue) loadKey(data []byte) (size int64, err error) {
 	value.valueType = ValueKey
 
 	elements, offset := value.readIntVlf(data)
-	value.keys = make([]byte, elements)
+	// Each element takes at least a byte, so a count larger than
+	// what's left can't be valid
+	if offset == 0 || elements < 0 || elements > int64(len(data))-offset {
+		return 0, OutOfRange
+	}
+	value.keys = make([]int64, elements)
 	value.members = make([]*serializedValue, elements)
 
 	for idx := int32(0); int64(idx) < elements; idx++ {
-		value.keys[idx] = data[offset]
-		offset++
+		key, readSize := value.readIntVlf(data[offset:])
+		if readSize == 0 {
+			return 0, OutOfRange
+		}
+		value.keys[idx] = key
+		offset += readSize
 
 		newValue, readSize, err := newSerializedValue(data[offset:])
 		if err != nil {
@@ -180,6 +236,9 @@ func (value *serializedValue) loadKey(data []byte) (size int64, err error) {
 
 func (value *serializedValue) loadInt8(data []byte) (size int64, err error) {
 	value.valueType = ValueInt8
+	if len(data) < 1 {
+		return 0, OutOfRange
+	}
 	value.intValue = int64(data[0])
 
 	return 1, nil
@@ -187,25 +246,46 @@ func (value *serializedValue) loadInt8(data []byte) (size int64, err error) {
 
 func (value *serializedValue) loadInt32(data []byte) (size int64, err error) {
 	value.valueType = ValueInt32
-
+	if len(data) < 4 {
+		return 0, OutOfRange
+	}
 	value.intValue = int64(binary.LittleEndian.Uint32(data[:4]))
 
 	return 4, nil
 }
 
+func (value *serializedValue) loadInt64(data []byte) (size int64, err error) {
+	value.valueType = ValueInt64
+	if len(data) < 8 {
+		return 0, OutOfRange
+	}
+
+	value.intValue = int64(binary.LittleEndian.Uint64(data[:8]))
+
+	return 8, nil
+}
+
 func (value *serializedValue) loadIntVlf(data []byte) (size int64, err error) {
 	value.valueType = ValueIntVlf
 
 	value.intValue, size = value.readIntVlf(data)
+	if size == 0 {
+		return 0, OutOfRange
+	}
 
 	return size, nil
 }
 
+// readIntVlf reads a variable length integer, size is 0 if the data
+// ends before the integer does.
 func (value *serializedValue) readIntVlf(data []byte) (result int64, size int64) {
 	var currentByte byte
 	var byteCount uint32 = 0
 
 	for {
+		if int(byteCount) >= len(data) || byteCount > 9 {
+			return 0, 0
+		}
 		currentByte = data[byteCount]
 		result += (int64(currentByte & 0x7F)) << (7 * byteCount)
 		byteCount++
@@ -223,40 +303,47 @@ func (value *serializedValue) readIntVlf(data []byte) (result int64, size int64)
 	return result, int64(byteCount)
 }
 
-// Type checks
+// Type checks, all of these apart from isNull are false for nil values
 func (value *serializedValue) isString() (result bool) {
-	return value.valueType == ValueString
+	return value != nil && value.valueType == ValueString
 }
 func (value *serializedValue) isArray() (result bool) {
-	return value.valueType == ValueArray
+	return value != nil && value.valueType == ValueArray
 }
 func (value *serializedValue) isKey() (result bool) {
-	return value.valueType == ValueKey
+	return value != nil && value.valueType == ValueKey
 }
 func (value *serializedValue) isInt8() (result bool) {
-	return value.valueType == ValueInt8
+	return value != nil && value.valueType == ValueInt8
 }
 func (value *serializedValue) isInt32() (result bool) {
-	return value.valueType == ValueInt32
+	return value != nil && value.valueType == ValueInt32
 }
 func (value *serializedValue) isInt64() (result bool) {
-	return value.valueType == ValueIntVlf
+	return value != nil && (value.valueType == ValueIntVlf || value.valueType == ValueInt64)
+}
+func (value *serializedValue) isNull() (result bool) {
+	return value == nil || value.valueType == ValueOptional
 }
 
 // Get values
 func (value *serializedValue) size() (size int64) {
+	if value == nil {
+		return 0
+	}
+
 	switch value.valueType {
-	case ValueArray:
-		fallthrough
-	case ValueKey:
+	case ValueArray, ValueKey, ValueChoice:
 		return int64(len(value.members))
 	case ValueString:
 		return int64(len(value.stringValue))
+	case ValueBitArray:
+		return value.intValue
 	case ValueInt8:
 		return 1
 	case ValueInt32:
 		return 4
-	case ValueIntVlf:
+	case ValueInt64, ValueIntVlf:
 		return 8
 	}
 
@@ -268,25 +355,56 @@ func (value *serializedValue) i(index int64) (item *serializedValue) {
 }
 
 func (value *serializedValue) item(index int64) (item *serializedValue) {
-	if index < 0 || index >= int64(len(value.members)) {
+	if value == nil || index < 0 || index >= int64(len(value.members)) {
 		return nil
 	}
 
 	return value.members[index]
 }
 
+// field returns the member of a keyed structure with the given key.
+// Calling it on nil or a missing key returns nil, so lookups can be
+// chained.
+func (value *serializedValue) field(key int64) (item *serializedValue) {
+	if value == nil {
+		return nil
+	}
+
+	for idx, memberKey := range value.keys {
+		if memberKey == key && idx < len(value.members) {
+			return value.members[idx]
+		}
+	}
+
+	return nil
+}
+
+// The value getters return zero values for nil, such as a missing
+// field.
 func (value *serializedValue) asString() (result string) {
+	if value == nil {
+		return ""
+	}
 	return value.stringValue
 }
 
 func (value *serializedValue) asInt8() (result int8) {
+	if value == nil {
+		return 0
+	}
 	return int8(value.intValue)
 }
 
 func (value *serializedValue) asInt32() (result int32) {
+	if value == nil {
+		return 0
+	}
 	return int32(value.intValue)
 }
 
 func (value *serializedValue) asInt64() (result int64) {
+	if value == nil {
+		return 0
+	}
 	return value.intValue
 }
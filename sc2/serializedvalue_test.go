@@ -29,6 +29,8 @@
 package sc2
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	. "launchpad.net/gocheck"
 	"os"
 )
@@ -170,3 +172,54 @@ func (s *SerializedValueSuite) TestDeserializeData(c *C) {
 	c.Check(value.i(6).isInt64(), Equals, true)
 	c.Check(value.i(6).asInt64(), Equals, int64(-144000000000))
 }
+
+func (s *SerializedValueSuite) TestDecodeSerialized(c *C) {
+	data, err := ioutil.ReadFile("testdata/serialized.dat")
+	c.Assert(err, IsNil)
+
+	decoded, err := DecodeSerialized(data)
+	c.Assert(err, IsNil)
+
+	root, ok := decoded.(SerializedStruct)
+	c.Assert(ok, Equals, true)
+	c.Check(root[0].Key, Equals, int64(0))
+	players, ok := root[0].Value.([]interface{})
+	c.Assert(ok, Equals, true)
+	c.Check(players, HasLen, 4)
+
+	player := players[0].(SerializedStruct)
+	c.Check(player[0].Value, Equals, "TehPartE")
+	c.Check(player[2].Key, Equals, int64(2))
+	c.Check(player[2].Value, Equals, "Protoss")
+
+	encoded, err := json.Marshal(player[3].Value)
+	c.Assert(err, IsNil)
+	c.Check(string(encoded), Equals, `{"0":255,"1":180,"2":20,"3":30}`)
+}
+
+func (s *SerializedValueSuite) TestDecodeTruncated(c *C) {
+	data, err := ioutil.ReadFile("testdata/serialized.dat")
+	c.Assert(err, IsNil)
+
+	for _, size := range []int{0, 1, 10, 20, len(data) / 2, len(data) - 1} {
+		_, err = DecodeSerialized(data[:size])
+		c.Check(err, NotNil, Commentf("%v bytes", size))
+	}
+
+	_, err = DecodeSerialized([]byte{0x01})
+	c.Check(err, NotNil)
+}
+
+func (s *SerializedValueSuite) TestDecodeOversizedLength(c *C) {
+	// Arrays and structs claiming more elements than there are bytes
+	// left are rejected before anything is allocated for them
+	huge := []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}
+	for _, valueType := range []byte{ValueArray, ValueKey} {
+		_, err := DecodeSerialized(append([]byte{valueType}, huge...))
+		c.Check(err, ErrorMatches, ".*Out of range", Commentf("type %v", valueType))
+	}
+
+	// Two elements need at least two bytes
+	_, err := DecodeSerialized([]byte{ValueArray, 0x04, ValueInt8})
+	c.Check(err, ErrorMatches, ".*Out of range")
+}
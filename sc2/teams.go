@@ -0,0 +1,190 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"sort"
+)
+
+// How a lobby slot or player takes part in the game.
+const (
+	RoleNone = iota // An open or closed slot
+	RoleParticipant
+	RoleObserver
+	RoleReferee
+)
+
+// teamAttributes are the attributes holding the players' teams in each
+// game type.
+var teamAttributes = map[int]int{
+	Game1v1: AttrPTeams1v1,
+	Game2v2: AttrPTeams2v2,
+	Game3v3: AttrPTeams3v3,
+	Game4v4: AttrPTeams4v4,
+	Game5v5: AttrPTeams5v5,
+	Game6v6: AttrPTeams6v6,
+	GameFfa: AttrPTeamsFfa,
+}
+
+// customTeamAttributes are the team attributes of custom games, keyed
+// by the number of teams in AttrGCustomTeams.
+var customTeamAttributes = map[int]int{
+	2: AttrPTeamsCustom2,
+	3: AttrPTeamsCustom3,
+}
+
+// Team is the players on one team.  Observers and referees aren't on a
+// team.  Outcome is the outcome its players share, or OutcomeUnknown.
+type Team struct {
+	Id      int       `xml:"id" json:"id"` // Counting from 0, as Player.Team does
+	Players []*Player `xml:"players>player" json:"players"`
+	Outcome int       `xml:"outcome" json:"outcome"`
+}
+
+// Teams returns the teams in the order of their IDs.  Outcomes
+// inferred by ResolveOutcomes are only included once it has run.
+func (replay *Replay) Teams() []*Team {
+	teams := make([]*Team, 0)
+	byId := make(map[int]*Team)
+	for _, player := range replay.Participants() {
+		team, found := byId[player.Team]
+		if !found {
+			team = &Team{Id: player.Team, Outcome: player.Outcome}
+			byId[player.Team] = team
+			teams = append(teams, team)
+		}
+		team.Players = append(team.Players, player)
+		if player.Outcome != team.Outcome {
+			team.Outcome = OutcomeUnknown
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		return teams[i].Id < teams[j].Id
+	})
+
+	return teams
+}
+
+// Participants returns the players who played the game, leaving out
+// observers and referees.
+func (replay *Replay) Participants() []*Player {
+	players := make([]*Player, 0)
+	for _, player := range replay.Players {
+		if player.Role == RoleParticipant {
+			players = append(players, player)
+		}
+	}
+
+	return players
+}
+
+// userSlot returns the lobby slot of a user counting from 0, or nil if
+// the replay has no slots or the user isn't in one.
+func (replay *Replay) userSlot(userIdx int) *LobbySlot {
+	for _, slot := range replay.Slots {
+		if slot.UserId != nil && *slot.UserId == userIdx {
+			return slot
+		}
+	}
+
+	return nil
+}
+
+// playerScope returns the attribute scope of the player at idx in
+// replay.details: its lobby slot's index plus 1, or without a slot its
+// own index plus 1.
+func (replay *Replay) playerScope(idx int, player *Player) int {
+	for slotIdx, slot := range replay.Slots {
+		if slot == player.slot {
+			return slotIdx + 1
+		}
+	}
+
+	return idx + 1
+}
+
+// loadTeams sets the roles of the slots and players and the players'
+// teams once the attributes and replay.initData are read.  replay.details
+// doesn't have the lobby's team layout, so teams come from the team
+// attribute of the game type, then from the lobby slots.
+func (replay *Replay) loadTeams() {
+	for idx, slot := range replay.Slots {
+		slot.Role = replay.slotRole(slot, idx+1)
+	}
+
+	teamAttribute, found := teamAttributes[replay.GameType]
+	if replay.GameType == GameCustom {
+		if teams := replay.Attributes.Global(AttrGCustomTeams); teams != nil {
+			teamAttribute, found = customTeamAttributes[teams.Value]
+		}
+	}
+
+	for idx, player := range replay.Players {
+		slot := player.slot
+
+		player.Role = RoleParticipant
+		if slot != nil && slot.Role != RoleNone {
+			player.Role = slot.Role
+		}
+
+		team := replay.Attributes.Get(teamAttribute, replay.playerScope(idx, player))
+		if found && team != nil && team.Value > 0 {
+			player.Team = team.Value - 1
+		} else if slot != nil {
+			player.Team = slot.TeamId
+		}
+	}
+}
+
+// slotRole works out a slot's role from replay.initData and the player
+// mode attributes of the slot's scope.
+func (replay *Replay) slotRole(slot *LobbySlot, scope int) int {
+	if slot.Control != SlotHuman && slot.Control != SlotComputer {
+		return RoleNone
+	}
+
+	observe := slot.Observe
+	mode := replay.Attributes.Get(AttrPPlayerMode, scope)
+	if mode != nil && mode.Value == PlayerModeWatcher && observe == ObserveNone {
+		observe = ObserveSpectator
+		kind := replay.Attributes.Get(AttrPObserverType, scope)
+		if kind != nil && kind.Value == ObserveReferee {
+			observe = ObserveReferee
+		}
+	}
+
+	switch observe {
+	case ObserveSpectator:
+		return RoleObserver
+	case ObserveReferee:
+		return RoleReferee
+	}
+
+	return RoleParticipant
+}
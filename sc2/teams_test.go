@@ -0,0 +1,190 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type TeamsSuite struct{}
+
+var _ = Suite(&TeamsSuite{})
+
+func (s *TeamsSuite) TestTeams(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	replay, err := NewReplay(reader)
+	c.Assert(err, IsNil)
+
+	teams := replay.Teams()
+	c.Assert(teams, HasLen, 2)
+	names := [][]string{}
+	for idx, team := range teams {
+		c.Check(team.Id, Equals, idx)
+		members := []string{}
+		for _, player := range team.Players {
+			members = append(members, player.Name)
+		}
+		names = append(names, members)
+	}
+	c.Check(names, DeepEquals, [][]string{
+		{"TehPartE", "totsgerber"},
+		{"David", "Steven"},
+	})
+
+	for _, slot := range replay.Slots {
+		c.Check(slot.Role, Equals, RoleParticipant)
+	}
+	c.Check(replay.Participants(), HasLen, 4)
+}
+
+// lobbyReplay makes a replay of lobby slots, giving the human slots
+// users in order.
+func lobbyReplay(gameType int, slots ...*LobbySlot) *Replay {
+	replay := &Replay{GameType: gameType, Attributes: Attributes{}}
+	userId := 0
+	for _, slot := range slots {
+		if slot.Control == SlotHuman {
+			id := userId
+			slot.UserId = &id
+			userId++
+		}
+		replay.Slots = append(replay.Slots, slot)
+	}
+
+	return replay
+}
+
+// loadLobby adds a player for each participant slot, as replay.details
+// leaves observers out, and loads the players' slots and teams.
+func loadLobby(replay *Replay) {
+	for idx, slot := range replay.Slots {
+		if replay.slotRole(slot, idx+1) == RoleParticipant {
+			replay.Players = append(replay.Players, &Player{Id: int64(len(replay.Players))})
+		}
+	}
+	replay.assignSlots()
+	replay.loadPlayerAttributes()
+	replay.loadTeams()
+}
+
+func (s *TeamsSuite) TestObservers(c *C) {
+	replay := lobbyReplay(Game1v1,
+		&LobbySlot{Control: SlotHuman, TeamId: 0},
+		&LobbySlot{Control: SlotHuman, TeamId: 1},
+		&LobbySlot{Control: SlotHuman, Observe: ObserveSpectator},
+		&LobbySlot{Control: SlotHuman},
+		&LobbySlot{Control: SlotClosed},
+		&LobbySlot{Control: SlotOpen})
+	replay.Attributes.add(&Attribute{Id: AttrPPlayerMode, Scope: 4, Value: PlayerModeWatcher})
+	replay.Attributes.add(&Attribute{Id: AttrPObserverType, Scope: 4, Value: ObserveReferee})
+	loadLobby(replay)
+
+	roles := []int{}
+	for _, slot := range replay.Slots {
+		roles = append(roles, slot.Role)
+	}
+	c.Check(roles, DeepEquals, []int{
+		RoleParticipant, RoleParticipant, RoleObserver, RoleReferee, RoleNone, RoleNone,
+	})
+
+	c.Assert(replay.Players, HasLen, 2)
+	c.Check(replay.Participants(), DeepEquals, replay.Players)
+
+	teams := replay.Teams()
+	c.Assert(teams, HasLen, 2)
+	c.Check(teams[0].Players, DeepEquals, []*Player{replay.Players[0]})
+	c.Check(teams[1].Players, DeepEquals, []*Player{replay.Players[1]})
+}
+
+func (s *TeamsSuite) TestObserverSlots(c *C) {
+	// The observer's slot comes before the second player's, so the
+	// second player's attributes are in scope 3
+	replay := lobbyReplay(Game1v1,
+		&LobbySlot{Control: SlotHuman},
+		&LobbySlot{Control: SlotHuman, Observe: ObserveSpectator},
+		&LobbySlot{Control: SlotHuman},
+		&LobbySlot{Control: SlotClosed})
+	for scope, team := range map[int]int{1: 2, 2: 1, 3: 1} {
+		replay.Attributes.add(&Attribute{Id: AttrPTeams1v1, Scope: scope, Value: team})
+	}
+	replay.Attributes.add(&Attribute{Id: AttrPChosenRace, Scope: 3, Value: RaceZerg})
+	loadLobby(replay)
+
+	c.Assert(replay.Players, HasLen, 2)
+	c.Check(replay.Players[0].slot, Equals, replay.Slots[0])
+	c.Check(replay.Players[1].slot, Equals, replay.Slots[2])
+	c.Check(replay.Players[0].Team, Equals, 1)
+	c.Check(replay.Players[1].Team, Equals, 0)
+	c.Check(replay.Players[1].ChosenRace, Equals, RaceZerg)
+	c.Check(replay.Participants(), DeepEquals, replay.Players)
+
+	// A working set slot ID picks the slot, whatever the order
+	replay = lobbyReplay(Game1v1,
+		&LobbySlot{Control: SlotHuman},
+		&LobbySlot{Control: SlotHuman})
+	replay.Attributes.add(&Attribute{Id: AttrPTeams1v1, Scope: 1, Value: 1})
+	replay.Attributes.add(&Attribute{Id: AttrPTeams1v1, Scope: 2, Value: 2})
+	first, second := 1, 0
+	replay.Players = []*Player{{workingSetSlotId: &first}, {workingSetSlotId: &second}}
+	replay.assignSlots()
+	replay.loadTeams()
+	c.Check(replay.Players[0].Team, Equals, 1)
+	c.Check(replay.Players[1].Team, Equals, 0)
+}
+
+func (s *TeamsSuite) TestCustomTeams(c *C) {
+	replay := lobbyReplay(GameCustom,
+		&LobbySlot{Control: SlotHuman},
+		&LobbySlot{Control: SlotHuman},
+		&LobbySlot{Control: SlotHuman})
+	replay.Attributes.add(&Attribute{Id: AttrGCustomTeams, Scope: AttrScopeGlobal, Value: 3})
+	for scope, team := range []int{3, 1, 3} {
+		replay.Attributes.add(&Attribute{Id: AttrPTeamsCustom3, Scope: scope + 1, Value: team})
+	}
+	loadLobby(replay)
+
+	teams := replay.Teams()
+	c.Assert(teams, HasLen, 2)
+	c.Check(teams[0].Id, Equals, 0)
+	c.Check(teams[0].Players, DeepEquals, []*Player{replay.Players[1]})
+	c.Check(teams[1].Id, Equals, 2)
+	c.Check(teams[1].Players, DeepEquals, []*Player{replay.Players[0], replay.Players[2]})
+
+	// Without a team attribute the lobby slots' teams are used
+	replay = lobbyReplay(GameCustom,
+		&LobbySlot{Control: SlotHuman, TeamId: 1},
+		&LobbySlot{Control: SlotHuman, TeamId: 0})
+	loadLobby(replay)
+	c.Check(replay.Players[0].Team, Equals, 1)
+	c.Check(replay.Players[1].Team, Equals, 0)
+}
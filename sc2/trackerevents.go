@@ -0,0 +1,210 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"fmt"
+)
+
+// UnitTag combines a unit tag's index and recycle count into the tag
+// used by game events.
+func UnitTag(index int64, recycle int64) int64 {
+	return index<<18 | recycle
+}
+
+// UnitBornEvent is a unit that was created complete, e.g. a unit
+// trained from a building or one placed when the game started.
+type UnitBornEvent struct {
+	EventHeader
+	UnitTag         int64  `json:"unitTag"`
+	UnitTypeName    string `json:"unitTypeName"`
+	ControlPlayerId int    `json:"controlPlayerId"`
+	UpkeepPlayerId  int    `json:"upkeepPlayerId"`
+	X               int    `json:"x"`
+	Y               int    `json:"y"`
+}
+
+// UnitInitEvent is a building that was placed and started construction.
+// The matching UnitDoneEvent is written when it finishes.
+type UnitInitEvent struct {
+	EventHeader
+	UnitTag         int64  `json:"unitTag"`
+	UnitTypeName    string `json:"unitTypeName"`
+	ControlPlayerId int    `json:"controlPlayerId"`
+	UpkeepPlayerId  int    `json:"upkeepPlayerId"`
+	X               int    `json:"x"`
+	Y               int    `json:"y"`
+}
+
+// UnitDoneEvent is a unit from a UnitInitEvent that finished.
+type UnitDoneEvent struct {
+	EventHeader
+	UnitTag int64 `json:"unitTag"`
+}
+
+// UnitDiedEvent is a unit that died or was removed from the game.  The
+// killer is nil when there wasn't one.
+type UnitDiedEvent struct {
+	EventHeader
+	UnitTag        int64  `json:"unitTag"`
+	KillerPlayerId *int   `json:"killerPlayerId"`
+	KillerUnitTag  *int64 `json:"killerUnitTag"`
+	X              int    `json:"x"`
+	Y              int    `json:"y"`
+}
+
+// UpgradeEvent is an upgrade a player finished researching.
+type UpgradeEvent struct {
+	EventHeader
+	PlayerId        int    `json:"playerId"`
+	UpgradeTypeName string `json:"upgradeTypeName"`
+	Count           int    `json:"count"`
+}
+
+// PlayerStats is a player's economy at a point in the game.  Collection
+// rates are per minute of game time.
+type PlayerStats struct {
+	MineralsCurrent        int     `json:"mineralsCurrent"`
+	VespeneCurrent         int     `json:"vespeneCurrent"`
+	MineralsCollectionRate int     `json:"mineralsCollectionRate"`
+	VespeneCollectionRate  int     `json:"vespeneCollectionRate"`
+	WorkersActiveCount     int     `json:"workersActiveCount"`
+	FoodUsed               float64 `json:"foodUsed"`
+	FoodMade               float64 `json:"foodMade"`
+}
+
+// PlayerStatsEvent is written for each player every 10 seconds of game
+// time.
+type PlayerStatsEvent struct {
+	EventHeader
+	PlayerId int         `json:"playerId"`
+	Stats    PlayerStats `json:"stats"`
+}
+
+// TrackerEventReader returns the events in replay.tracker.events in
+// order.
+type TrackerEventReader struct {
+	reader *eventReader
+}
+
+// TrackerEvents reads the unit, upgrade and player stats events the
+// game tracked for replays.  Only replays from patch 2.0.8 (base build
+// 25604) on have them, and the replay's protocol has to describe the
+// tracker stream.
+func (replay *Replay) TrackerEvents() (events *TrackerEventReader, err error) {
+	data, err := replay.mpq.ReadFile("replay.tracker.events")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read tracker events: %v", err)
+	}
+
+	decoder := NewVersionedDecoder(data, replay.Protocol.TypeInfos)
+	reader, err := newEventReader(replay, TrackerEventStream, decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TrackerEventReader{reader: reader}, nil
+}
+
+// Next returns the next event, or io.EOF after the last one.  Unknown
+// event IDs end the stream as they do for GameEventReader.
+func (events *TrackerEventReader) Next() (event Event, err error) {
+	header, payload, err := events.reader.next()
+	if err != nil {
+		return nil, err
+	}
+
+	return newTrackerEvent(header, payload), nil
+}
+
+func newTrackerEvent(header EventHeader, payload interface{}) Event {
+	unitTag := UnitTag(lookupInt(payload, "m_unitTagIndex"),
+		lookupInt(payload, "m_unitTagRecycle"))
+
+	switch header.Name {
+	case "UnitBorn":
+		return &UnitBornEvent{
+			EventHeader:     header,
+			UnitTag:         unitTag,
+			UnitTypeName:    lookupString(payload, "m_unitTypeName"),
+			ControlPlayerId: int(lookupInt(payload, "m_controlPlayerId")),
+			UpkeepPlayerId:  int(lookupInt(payload, "m_upkeepPlayerId")),
+			X:               int(lookupInt(payload, "m_x")),
+			Y:               int(lookupInt(payload, "m_y")),
+		}
+	case "UnitInit":
+		return &UnitInitEvent{
+			EventHeader:     header,
+			UnitTag:         unitTag,
+			UnitTypeName:    lookupString(payload, "m_unitTypeName"),
+			ControlPlayerId: int(lookupInt(payload, "m_controlPlayerId")),
+			UpkeepPlayerId:  int(lookupInt(payload, "m_upkeepPlayerId")),
+			X:               int(lookupInt(payload, "m_x")),
+			Y:               int(lookupInt(payload, "m_y")),
+		}
+	case "UnitDone":
+		return &UnitDoneEvent{EventHeader: header, UnitTag: unitTag}
+	case "UnitDied":
+		event := &UnitDiedEvent{EventHeader: header, UnitTag: unitTag}
+		event.KillerPlayerId = optionalInt(lookup(payload, "m_killerPlayerId"))
+		index := lookup(payload, "m_killerUnitTagIndex")
+		recycle := lookup(payload, "m_killerUnitTagRecycle")
+		if index != nil && recycle != nil {
+			killer := UnitTag(unwrapInt(index), unwrapInt(recycle))
+			event.KillerUnitTag = &killer
+		}
+		event.X = int(lookupInt(payload, "m_x"))
+		event.Y = int(lookupInt(payload, "m_y"))
+		return event
+	case "Upgrade":
+		return &UpgradeEvent{
+			EventHeader:     header,
+			PlayerId:        int(lookupInt(payload, "m_playerId")),
+			UpgradeTypeName: lookupString(payload, "m_upgradeTypeName"),
+			Count:           int(lookupInt(payload, "m_count")),
+		}
+	case "PlayerStats":
+		stats := lookup(payload, "m_stats")
+		return &PlayerStatsEvent{
+			EventHeader: header,
+			PlayerId:    int(lookupInt(payload, "m_playerId")),
+			Stats: PlayerStats{
+				MineralsCurrent:        int(lookupInt(stats, "m_scoreValueMineralsCurrent")),
+				VespeneCurrent:         int(lookupInt(stats, "m_scoreValueVespeneCurrent")),
+				MineralsCollectionRate: int(lookupInt(stats, "m_scoreValueMineralsCollectionRate")),
+				VespeneCollectionRate:  int(lookupInt(stats, "m_scoreValueVespeneCollectionRate")),
+				WorkersActiveCount:     int(lookupInt(stats, "m_scoreValueWorkersActiveCount")),
+				FoodUsed:               fixedPoint(lookup(stats, "m_scoreValueFoodUsed")),
+				FoodMade:               fixedPoint(lookup(stats, "m_scoreValueFoodMade")),
+			},
+		}
+	}
+
+	return &GenericEvent{EventHeader: header, Data: payload}
+}
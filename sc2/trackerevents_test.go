@@ -0,0 +1,309 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sc2
+
+import (
+	"bytes"
+	"github.com/aphistic/go.Zamara/mpq"
+	"io"
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type TrackerEventsSuite struct{}
+
+var _ = Suite(&TrackerEventsSuite{})
+
+// A cut down tracker stream layout in the versioned encoding.
+var trackerTypeInfos = []TypeInfo{
+	{Kind: TypeInt, Bounds: [2]int64{0, 32}},
+	{Kind: TypeChoice, Bounds: [2]int64{0, 2}, Fields: []TypeField{
+		{Name: "m_uint6", Type: 0, Tag: 0},
+	}},
+	{Kind: TypeBlob, Bounds: [2]int64{0, 8}},
+	{Kind: TypeOptional, Type: 0},
+	{Kind: TypeStruct, Fields: []TypeField{
+		{Name: "m_unitTagIndex", Type: 0, Tag: 0},
+		{Name: "m_unitTagRecycle", Type: 0, Tag: 1},
+		{Name: "m_unitTypeName", Type: 2, Tag: 2},
+		{Name: "m_controlPlayerId", Type: 0, Tag: 3},
+		{Name: "m_upkeepPlayerId", Type: 0, Tag: 4},
+		{Name: "m_x", Type: 0, Tag: 5},
+		{Name: "m_y", Type: 0, Tag: 6},
+	}},
+	{Kind: TypeStruct, Fields: []TypeField{
+		{Name: "m_unitTagIndex", Type: 0, Tag: 0},
+		{Name: "m_unitTagRecycle", Type: 0, Tag: 1},
+		{Name: "m_killerPlayerId", Type: 3, Tag: 2},
+		{Name: "m_x", Type: 0, Tag: 3},
+		{Name: "m_y", Type: 0, Tag: 4},
+		{Name: "m_killerUnitTagIndex", Type: 3, Tag: 5},
+		{Name: "m_killerUnitTagRecycle", Type: 3, Tag: 6},
+	}},
+	{Kind: TypeStruct, Fields: []TypeField{
+		{Name: "m_scoreValueMineralsCurrent", Type: 0, Tag: 0},
+		{Name: "m_scoreValueWorkersActiveCount", Type: 0, Tag: 1},
+		{Name: "m_scoreValueFoodUsed", Type: 0, Tag: 2},
+	}},
+	{Kind: TypeStruct, Fields: []TypeField{
+		{Name: "m_playerId", Type: 0, Tag: 0},
+		{Name: "m_stats", Type: 6, Tag: 1},
+	}},
+}
+
+func (s *TrackerEventsSuite) TestTrackerEvents(c *C) {
+	protocol := &Protocol{
+		Name:      "test-tracker",
+		Headers:   map[string]StreamHeader{"tracker": {DeltaTypeId: 1, UserTypeId: -1, IdTypeId: 0}},
+		TypeInfos: trackerTypeInfos,
+		Events: map[string][]EventType{"tracker": {
+			{Id: 0, Name: "PlayerStats", TypeId: 7},
+			{Id: 1, Name: "UnitBorn", TypeId: 4},
+			{Id: 2, Name: "UnitDied", TypeId: 5},
+		}},
+	}
+	data := []byte{
+		// UnitBorn at loop 0
+		0x03, 0x00, 0x09, 0x00, // delta 0
+		0x09, 0x02, // id 1
+		0x05, 0x0e, // struct with 7 fields
+		0x00, 0x09, 0x2a, // index 21
+		0x02, 0x09, 0x02, // recycle 1
+		0x04, 0x02, 0x0a, 'P', 'r', 'o', 'b', 'e', // type name
+		0x06, 0x09, 0x02, // control player 1
+		0x08, 0x09, 0x02, // upkeep player 1
+		0x0a, 0x09, 0xb6, 0x01, // x 91
+		0x0c, 0x09, 0x30, // y 24
+
+		// UnitDied at loop 16 without a killer
+		0x03, 0x00, 0x09, 0x20, // delta 16
+		0x09, 0x04, // id 2
+		0x05, 0x0e, // struct with 7 fields
+		0x00, 0x09, 0x2a, // index 21
+		0x02, 0x09, 0x02, // recycle 1
+		0x04, 0x04, 0x00, // no killer player
+		0x06, 0x09, 0xb6, 0x01, // x 91
+		0x08, 0x09, 0x30, // y 24
+		0x0a, 0x04, 0x00, // no killer unit
+		0x0c, 0x04, 0x00,
+
+		// PlayerStats at loop 176
+		0x03, 0x00, 0x09, 0xc0, 0x02, // delta 160
+		0x09, 0x00, // id 0
+		0x05, 0x04, // struct with 2 fields
+		0x00, 0x09, 0x02, // player 1
+		0x02, 0x05, 0x06, // struct with 3 fields
+		0x00, 0x09, 0x90, 0x03, // minerals 200
+		0x02, 0x09, 0x18, // workers 12
+		0x04, 0x09, 0x80, 0x80, 0x07, // food used 14 * 4096
+	}
+
+	replay := &Replay{Protocol: protocol, GameSpeed: SpeedFaster}
+	reader, err := newEventReader(replay, TrackerEventStream, NewVersionedDecoder(data, trackerTypeInfos))
+	c.Assert(err, IsNil)
+	events := &TrackerEventReader{reader: reader}
+
+	event, err := events.Next()
+	c.Assert(err, IsNil)
+	c.Check(event, DeepEquals, &UnitBornEvent{
+		EventHeader:     EventHeader{Loop: 0, UserId: -1, Name: "UnitBorn"},
+		UnitTag:         UnitTag(21, 1),
+		UnitTypeName:    "Probe",
+		ControlPlayerId: 1,
+		UpkeepPlayerId:  1,
+		X:               91,
+		Y:               24,
+	})
+
+	event, err = events.Next()
+	c.Assert(err, IsNil)
+	died, ok := event.(*UnitDiedEvent)
+	c.Assert(ok, Equals, true)
+	c.Check(died.Loop, Equals, 16)
+	c.Check(died.Time, Equals, replay.LoopTime(16))
+	c.Check(died.UnitTag, Equals, int64(21<<18|1))
+	c.Check(died.KillerPlayerId, IsNil)
+	c.Check(died.KillerUnitTag, IsNil)
+
+	event, err = events.Next()
+	c.Assert(err, IsNil)
+	c.Check(event, DeepEquals, &PlayerStatsEvent{
+		EventHeader: EventHeader{Loop: 176, Time: replay.LoopTime(176), UserId: -1, Name: "PlayerStats"},
+		PlayerId:    1,
+		Stats:       PlayerStats{MineralsCurrent: 200, WorkersActiveCount: 12, FoodUsed: 14},
+	})
+
+	_, err = events.Next()
+	c.Check(err, Equals, io.EOF)
+}
+
+func (s *TrackerEventsSuite) TestKiller(c *C) {
+	event := newTrackerEvent(EventHeader{Name: "UnitDied"}, Struct{
+		"m_unitTagIndex":         int64(3),
+		"m_unitTagRecycle":       int64(2),
+		"m_killerPlayerId":       int64(2),
+		"m_killerUnitTagIndex":   int64(7),
+		"m_killerUnitTagRecycle": int64(1),
+	})
+
+	died := event.(*UnitDiedEvent)
+	c.Assert(died.KillerPlayerId, NotNil)
+	c.Check(*died.KillerPlayerId, Equals, 2)
+	c.Assert(died.KillerUnitTag, NotNil)
+	c.Check(*died.KillerUnitTag, Equals, UnitTag(7, 1))
+}
+
+func (s *TrackerEventsSuite) TestNoTrackerEvents(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	replay, err := NewReplay(reader)
+	c.Assert(err, IsNil)
+
+	_, err = replay.TrackerEvents()
+	c.Check(err, ErrorMatches, "Unable to read tracker events: .*")
+}
+
+func (s *TrackerEventsSuite) TestBuiltinTrackerProtocol(c *C) {
+	reader, err := os.Open("../mpq/testdata/replay1.SC2Replay")
+	c.Assert(err, IsNil)
+	defer reader.Close()
+	original, err := mpq.NewMpq(reader)
+	c.Assert(err, IsNil)
+
+	// Swap the base build 15405 for 25604, the first with tracker events
+	header := bytes.Replace(original.UserData.Content,
+		[]byte{0x09, 0xda, 0xf0, 0x01}, []byte{0x09, 0x88, 0x90, 0x03}, 1)
+
+	buffer := new(bytes.Buffer)
+	w := mpq.NewWriter(buffer)
+	w.SetUserData(header)
+	for _, filename := range []string{"replay.details", "replay.attributes.events"} {
+		data, err := original.ReadFile(filename)
+		c.Assert(err, IsNil)
+		c.Assert(w.AddFile(filename, data), IsNil)
+	}
+	c.Assert(w.AddFile("replay.tracker.events", []byte{
+		// UnitBorn at loop 0 with a creator field newer than the protocol
+		0x03, 0x00, 0x09, 0x00, // delta 0
+		0x09, 0x02, // id 1
+		0x05, 0x10, // struct with 8 fields
+		0x00, 0x09, 0x2a, // index 21
+		0x02, 0x09, 0x02, // recycle 1
+		0x04, 0x02, 0x0a, 'P', 'r', 'o', 'b', 'e', // type name
+		0x06, 0x09, 0x04, // control player 2
+		0x08, 0x09, 0x04, // upkeep player 2
+		0x0a, 0x09, 0xb6, 0x01, // x 91
+		0x0c, 0x09, 0x30, // y 24
+		0x0e, 0x09, 0x02, // creator index 1
+
+		// PlayerStats at loop 0
+		0x03, 0x00, 0x09, 0x00, // delta 0
+		0x09, 0x00, // id 0
+		0x05, 0x04, // struct with 2 fields
+		0x00, 0x09, 0x04, // player 2
+		0x02, 0x05, 0x06, // struct with 3 fields
+		0x00, 0x09, 0x90, 0x03, // minerals 200
+		0x08, 0x09, 0x18, // workers 12
+		0x3a, 0x09, 0x80, 0x80, 0x07, // food used 14 * 4096
+
+		// UnitOwnerChange at loop 32
+		0x03, 0x00, 0x09, 0x40, // delta 32
+		0x09, 0x06, // id 3
+		0x05, 0x08, // struct with 4 fields
+		0x00, 0x09, 0x2a, // index 21
+		0x02, 0x09, 0x02, // recycle 1
+		0x04, 0x09, 0x02, // control player 1
+		0x06, 0x09, 0x02, // upkeep player 1
+	}), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	archive, err := mpq.NewMpqFromBytes(buffer.Bytes())
+	c.Assert(err, IsNil)
+	replay, err := NewReplayFromMpq(archive)
+	c.Assert(err, IsNil)
+	c.Check(replay.Protocol.Name, Equals, "fallback-25604")
+
+	events, err := replay.TrackerEvents()
+	c.Assert(err, IsNil)
+
+	event, err := events.Next()
+	c.Assert(err, IsNil)
+	c.Check(event, DeepEquals, &UnitBornEvent{
+		EventHeader:     EventHeader{Loop: 0, UserId: -1, Name: "UnitBorn"},
+		UnitTag:         UnitTag(21, 1),
+		UnitTypeName:    "Probe",
+		ControlPlayerId: 2,
+		UpkeepPlayerId:  2,
+		X:               91,
+		Y:               24,
+	})
+
+	event, err = events.Next()
+	c.Assert(err, IsNil)
+	c.Check(event, DeepEquals, &PlayerStatsEvent{
+		EventHeader: EventHeader{Loop: 0, UserId: -1, Name: "PlayerStats"},
+		PlayerId:    2,
+		Stats:       PlayerStats{MineralsCurrent: 200, WorkersActiveCount: 12, FoodUsed: 14},
+	})
+
+	event, err = events.Next()
+	c.Assert(err, IsNil)
+	owner, ok := event.(*GenericEvent)
+	c.Assert(ok, Equals, true)
+	c.Check(owner.Name, Equals, "UnitOwnerChange")
+	c.Check(owner.Loop, Equals, 32)
+	c.Check(lookupInt(owner.Data, "m_controlPlayerId"), Equals, int64(1))
+
+	_, err = events.Next()
+	c.Check(err, Equals, io.EOF)
+}
+
+func (s *TrackerEventsSuite) TestUnknownEvent(c *C) {
+	protocol := &Protocol{
+		Name:      "test-tracker",
+		Headers:   map[string]StreamHeader{"tracker": {DeltaTypeId: 1, UserTypeId: -1, IdTypeId: 0}},
+		TypeInfos: trackerTypeInfos,
+		Events:    map[string][]EventType{"tracker": {{Id: 0, Name: "PlayerStats", TypeId: 7}}},
+	}
+	data := []byte{
+		0x03, 0x00, 0x09, 0x20, // delta 16
+		0x09, 0x0a, // id 5
+		0x05, 0x00, // empty struct
+	}
+
+	// Nothing after an unknown event can be read
+	replay := &Replay{Protocol: protocol, GameSpeed: SpeedFaster}
+	reader, err := newEventReader(replay, TrackerEventStream, NewVersionedDecoder(data, trackerTypeInfos))
+	c.Assert(err, IsNil)
+	events := &TrackerEventReader{reader: reader}
+	_, err = events.Next()
+	c.Check(err, ErrorMatches, "Unknown tracker event 5 at game loop 16")
+}
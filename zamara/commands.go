@@ -0,0 +1,226 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Exit codes used by every command
+const (
+	exitOk      = 0 // Success, or no differences or problems found
+	exitFailure = 1 // Failure, or differences or problems found
+	exitUsage   = 2 // Bad arguments
+)
+
+// command is run when its name, or its group and name, are the first
+// arguments, e.g. "zamara mpq check replay.SC2Replay".
+type command struct {
+	name        string
+	args        string // Arguments shown in the usage, after the flags
+	description string
+	run         func(args []string) int
+}
+
+// commandGroup is a set of commands under a common name such as "mpq".
+type commandGroup struct {
+	name        string
+	description string
+	commands    []*command
+}
+
+// Set up in init since the commands look themselves up for their usage.
+var commands []*command
+var commandGroups []*commandGroup
+
+func init() {
+	commands = []*command{
+		&command{"convert", "<input> <output>",
+			"Convert between MPQ, zip and tar archives.", convert},
+		&command{"help", "[group] [command]",
+			"Show help for a group or command.", help},
+	}
+
+	commandGroups = []*commandGroup{
+		&commandGroup{"mpq", "Work with MPQ archives.", []*command{
+			&command{"info", "<archive>",
+				"Show the header, user data and tables of an archive.", mpqInfo},
+			&command{"ls", "<archive>",
+				"List the files in an archive.", mpqLs},
+			&command{"extract", "<archive> <directory>",
+				"Extract the files in an archive.", mpqExtract},
+			&command{"cat", "<archive>!<file>",
+				"Write a file from an archive to stdout.", mpqCat},
+			&command{"hash", "<path>...",
+				"Show the hashes and file key of a path.", mpqHash},
+			&command{"check", "<archive>",
+				"Check the integrity of an archive.", mpqCheck},
+			&command{"diff", "<old archive> <new archive>",
+				"Compare two archives.", mpqDiff},
+			&command{"stats", "<archive>",
+				"Show compression and space usage of an archive.", mpqStatsCommand},
+		}},
+		&commandGroup{"sc2", "Work with StarCraft II replays.", []*command{
+			&command{"info", "<replay>",
+				"Show a summary of a replay and its players.", sc2Info},
+			&command{"players", "<replay>",
+				"Show the players in a replay.", sc2Players},
+			&command{"events", "<replay>",
+				"Show the game events in a replay.", sc2Events},
+			&command{"chat", "<replay>",
+				"Show the chat log of a replay.", sc2Chat},
+		}},
+	}
+}
+
+func findGroup(name string) *commandGroup {
+	for _, group := range commandGroups {
+		if group.name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+func findCommand(commands []*command, name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// runCommand runs the command named by the arguments and exits.
+func runCommand(args []string) {
+	if len(args) < 1 {
+		topUsage()
+		os.Exit(exitUsage)
+	}
+
+	if cmd := findCommand(commands, args[0]); cmd != nil {
+		os.Exit(cmd.run(args[1:]))
+	}
+
+	group := findGroup(args[0])
+	if group == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command: %v\n\n", args[0])
+		topUsage()
+		os.Exit(exitUsage)
+	}
+
+	if len(args) >= 2 {
+		if cmd := findCommand(group.commands, args[1]); cmd != nil {
+			os.Exit(cmd.run(args[2:]))
+		}
+		fmt.Fprintf(os.Stderr, "Unknown command: %v %v\n\n", args[0], args[1])
+	}
+
+	groupUsage(group)
+	os.Exit(exitUsage)
+}
+
+// newFlagSet returns the flags for a command, such as "mpq check",
+// with usage built from the command's arguments and description.
+func newFlagSet(path string) (flagSet *flag.FlagSet) {
+	names := strings.Fields(path)
+	var cmd *command
+	if len(names) == 1 {
+		cmd = findCommand(commands, names[0])
+	} else if group := findGroup(names[0]); group != nil {
+		cmd = findCommand(group.commands, names[1])
+	}
+
+	flagSet = flag.NewFlagSet(path, flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n\n")
+		fmt.Fprintf(os.Stderr, "  zamara %v [arguments] %v\n\n", path, cmd.args)
+		fmt.Fprintf(os.Stderr, "%v\n\n", cmd.description)
+		flagSet.PrintDefaults()
+	}
+
+	return flagSet
+}
+
+func topUsage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n\n")
+	fmt.Fprintf(os.Stderr, "  zamara <command> [arguments]\n")
+	fmt.Fprintf(os.Stderr, "  zamara <group> <command> [arguments]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10v %v\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nGroups:\n")
+	for _, group := range commandGroups {
+		fmt.Fprintf(os.Stderr, "  %-10v %v\n", group.name, group.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun \"zamara help <group>\" to list a group's commands.\n")
+	fmt.Fprintf(os.Stderr, "Exit codes are %v for success, %v for failure and %v for bad arguments.\n",
+		exitOk, exitFailure, exitUsage)
+	fmt.Fprintf(os.Stderr, "Running zamara with flags first uses the older -in/-format/-type interface.\n")
+}
+
+func groupUsage(group *commandGroup) {
+	fmt.Fprintf(os.Stderr, "Usage:\n\n")
+	fmt.Fprintf(os.Stderr, "  zamara %v <command> [arguments]\n\n", group.name)
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, cmd := range group.commands {
+		fmt.Fprintf(os.Stderr, "  %-10v %v\n", cmd.name, cmd.description)
+	}
+}
+
+func help(args []string) int {
+	switch len(args) {
+	case 0:
+		topUsage()
+		return exitOk
+	case 1:
+		if cmd := findCommand(commands, args[0]); cmd != nil {
+			newFlagSet(cmd.name).Usage()
+			return exitOk
+		}
+		if group := findGroup(args[0]); group != nil {
+			groupUsage(group)
+			return exitOk
+		}
+	case 2:
+		if group := findGroup(args[0]); group != nil {
+			if cmd := findCommand(group.commands, args[1]); cmd != nil {
+				newFlagSet(group.name + " " + cmd.name).Usage()
+				return exitOk
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command: %v\n", strings.Join(args, " "))
+	return exitUsage
+}
@@ -0,0 +1,141 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"github.com/aphistic/go.Zamara/mpq"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var convertCompression = map[string]byte{
+	"none": mpq.CompressNone,
+	"zlib": mpq.CompressZlib,
+}
+
+// archiveFormat guesses an archive's format from its extension,
+// treating anything that isn't a zip or tar as an MPQ.
+func archiveFormat(path string) string {
+	path = mpq.SplitNestedPath(path)[0]
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip":
+		return "zip"
+	case ".tar":
+		return "tar"
+	}
+	return "mpq"
+}
+
+func convert(args []string) int {
+	flagSet := newFlagSet("convert")
+	compression := flagSet.String("compression", "zlib", "Compression for files in a new MPQ. [none, zlib]")
+	usage := flagSet.Usage
+	flagSet.Usage = func() {
+		usage()
+		fmt.Fprintf(os.Stderr, "\nFormats are chosen by extension: .zip, .tar or anything else for MPQ.\n")
+		fmt.Fprintf(os.Stderr, "One of the input and output must be an MPQ.\n")
+	}
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 2 {
+		flagSet.Usage()
+		return exitUsage
+	}
+	input := flagSet.Arg(0)
+	output := expandPath(flagSet.Arg(1))
+
+	mask, found := convertCompression[strings.ToLower(*compression)]
+	if !found {
+		fmt.Fprintf(os.Stderr, "Unknown compression: %v\n", *compression)
+		return exitUsage
+	}
+
+	inputFormat := archiveFormat(input)
+	outputFormat := archiveFormat(output)
+	if (inputFormat == "mpq") == (outputFormat == "mpq") {
+		fmt.Fprintf(os.Stderr, "Unable to convert %v to %v\n", inputFormat, outputFormat)
+		return exitUsage
+	}
+
+	writer, err := os.Create(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to create output file: %v\n", err.Error())
+		return exitFailure
+	}
+
+	err = convertArchive(input, inputFormat, writer, outputFormat, mask)
+	closeErr := writer.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to convert %v: %v\n", input, err.Error())
+		os.Remove(output)
+		return exitFailure
+	}
+
+	return exitOk
+}
+
+func convertArchive(input string, inputFormat string, writer *os.File,
+	outputFormat string, compression byte) (err error) {
+	options := mpq.PackOptions{Compression: compression}
+
+	switch inputFormat {
+	case "zip":
+		reader, err := zip.OpenReader(expandPath(input))
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		return mpq.PackZip(&reader.Reader, writer, options)
+
+	case "tar":
+		reader, err := os.Open(expandPath(input))
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		return mpq.PackTar(reader, writer, options)
+	}
+
+	archive, err := openMpq(input)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	if outputFormat == "zip" {
+		return archive.WriteZip(writer)
+	}
+	return archive.WriteTar(writer)
+}
@@ -0,0 +1,106 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func handleJson(flags zamaraFlags) int {
+	switch flags.runType {
+	case "mpq":
+		return mpqJson(flags)
+	case "sc2":
+		return sc2Json(flags)
+	}
+
+	return exitUsage
+}
+
+// writeJson writes value as indented JSON to the output file, or to
+// stdout if there isn't one.
+func writeJson(flags zamaraFlags, value interface{}) int {
+	writer := os.Stdout
+	if len(flags.outputAbs) > 0 {
+		var err error
+		writer, err = os.Create(flags.outputAbs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"Unable to open output file: %v\n", flags.outputAbs)
+			return exitFailure
+		}
+	}
+
+	err := encodeJson(writer, value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "There was a problem writing the JSON.\n%v\n", err.Error())
+		if writer != os.Stdout {
+			writer.Close()
+		}
+		return exitFailure
+	}
+
+	if writer != os.Stdout {
+		err = writer.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem writing the JSON.\n%v\n", err.Error())
+			return exitFailure
+		}
+	}
+
+	if flags.verbose && len(flags.outputAbs) > 0 {
+		fmt.Fprintf(os.Stderr, "The JSON was written to %v\n", flags.output)
+	}
+
+	return exitOk
+}
+
+func mpqJson(flags zamaraFlags) int {
+	mpq, err := openMpq(flags.inputAbs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr,
+			"Unable to read MPQ (%v): %v\n", flags.input, err.Error())
+		return exitFailure
+	}
+
+	status := writeJson(flags, mpq)
+	err = mpq.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr,
+			"Unable to close MPQ (%v): %v\n", flags.input, err.Error())
+		return exitFailure
+	}
+
+	return status
+}
+
+func sc2Json(flags zamaraFlags) int {
+	return writeJson(flags, openReplay(flags))
+}
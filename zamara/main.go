@@ -51,7 +51,7 @@ var flags zamaraFlags
 func init() {
 	flag.StringVar(&flags.input, "in", "", "Input file.")
 	flag.StringVar(&flags.output, "out", "", "Output file or directory.")
-	flag.StringVar(&flags.format, "format", "stdout", "Output format. [stdout, json, xml, extract]")
+	flag.StringVar(&flags.format, "format", "stdout", "Output format. [stdout, json, xml, extract, stats]")
 	flag.StringVar(&flags.runType, "type", "sc2", "Output type, see below for options.")
 	flag.BoolVar(&flags.verbose, "v", false, "Verbose output.")
 }
@@ -66,8 +66,7 @@ func usage() {
 
 func validateUsage() {
 	// Make sure the input file exists
-	_, err := os.Stat(flags.input)
-	if err != nil {
+	if !mpqPathExists(flags.input) {
 		fmt.Fprintf(os.Stderr, "Unable to find input file %v\n", flags.input)
 		usage()
 	}
@@ -85,18 +84,25 @@ func validateUsage() {
 		break
 	case "extract":
 		break
+	case "stats":
+		break
 	default:
 		fmt.Fprintf(os.Stderr,
 			"Unrecognized output format: %v\n", flags.format)
-		os.Exit(1234)
+		os.Exit(exitUsage)
 	}
 }
 
 func main() {
+	// Anything that doesn't start with a flag is a subcommand, the
+	// older flag-only interface is kept for existing scripts.
+	if len(os.Args) < 2 || !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1:])
+	}
+
 	flag.Usage = usage
 	flag.Parse()
 
-	fmt.Println(expandPath(flags.output))
 	flags.inputAbs = expandPath(flags.input)
 	flags.outputAbs = expandPath(flags.output)
 
@@ -107,11 +113,15 @@ func main() {
 		extractMpq(flags)
 		break
 	case "stdout":
-		handleStdout(flags)
-		break
+		os.Exit(handleStdout(flags))
+	case "json":
+		os.Exit(handleJson(flags))
 	case "xml":
 		handleXml(flags)
 		break
+	case "stats":
+		handleStats(flags)
+		break
 	default:
 		break
 	}
@@ -0,0 +1,125 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/aphistic/go.Zamara/mpq"
+	"github.com/aphistic/go.Zamara/sc2"
+	"io"
+	"os"
+)
+
+func mpqCat(args []string) int {
+	flagSet := newFlagSet("mpq cat")
+	hexdump := flagSet.Bool("x", false, "Show the file as a hex dump.")
+	decode := flagSet.String("decode", "", "Decode a serialized blob such as replay.details. [tree, json]")
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 1 || (*hexdump && *decode != "") {
+		flagSet.Usage()
+		return exitUsage
+	}
+
+	switch *decode {
+	case "", "tree", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "Unrecognized decode format: %v\n", *decode)
+		return exitUsage
+	}
+
+	data, err := mpq.ReadNested(expandPath(flagSet.Arg(0)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read file (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+
+	switch {
+	case *decode != "":
+		decoded, err := sc2.DecodeSerialized(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err.Error())
+			return exitFailure
+		}
+
+		if *decode == "json" {
+			err = encodeJson(os.Stdout, decoded)
+		} else {
+			err = writeTree(os.Stdout, decoded, "")
+		}
+	case *hexdump:
+		dumper := hex.Dumper(os.Stdout)
+		_, err = dumper.Write(data)
+		if err == nil {
+			err = dumper.Close()
+		}
+	default:
+		_, err = os.Stdout.Write(data)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write file: %v\n", err.Error())
+		return exitFailure
+	}
+
+	return exitOk
+}
+
+// writeTree writes a decoded serialized value as an indented tree.
+func writeTree(w io.Writer, value interface{}, indent string) (err error) {
+	switch value := value.(type) {
+	case sc2.SerializedStruct:
+		fmt.Fprintf(w, "{\n")
+		for _, field := range value {
+			fmt.Fprintf(w, "%v  %v: ", indent, field.Key)
+			err = writeTree(w, field.Value, indent+"  ")
+			if err != nil {
+				return
+			}
+		}
+		_, err = fmt.Fprintf(w, "%v}\n", indent)
+	case []interface{}:
+		fmt.Fprintf(w, "[\n")
+		for idx, item := range value {
+			fmt.Fprintf(w, "%v  [%v] ", indent, idx)
+			err = writeTree(w, item, indent+"  ")
+			if err != nil {
+				return
+			}
+		}
+		_, err = fmt.Fprintf(w, "%v]\n", indent)
+	case string:
+		_, err = fmt.Fprintf(w, "%q\n", value)
+	default:
+		_, err = fmt.Fprintf(w, "%v\n", value)
+	}
+
+	return
+}
@@ -0,0 +1,109 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func mpqCheck(args []string) int {
+	flagSet := newFlagSet("mpq check")
+	repair := flagSet.String("repair", "", "Write a repaired copy of the archive to this file.")
+	listfile := flagSet.String("listfile", "", "List file with extra file names to use when repairing.")
+	verbose := flagSet.Bool("v", false, "Verbose output.")
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return exitUsage
+	}
+	archive, err := openMpq(flagSet.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read MPQ (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+	defer archive.Close()
+
+	report := archive.Check()
+	for _, problem := range report.Problems {
+		fmt.Println(problem)
+	}
+	if *verbose || len(report.Problems) > 0 {
+		fmt.Println()
+	}
+	fmt.Printf("Hash entries: %v\n", report.HashEntries)
+	fmt.Printf("Block entries: %v\n", report.BlockEntries)
+	fmt.Printf("Known files: %v\n", report.KnownFiles)
+	fmt.Printf("Files checked: %v\n", report.FilesChecked)
+	fmt.Printf("Problems: %v\n", len(report.Problems))
+
+	if len(*repair) > 0 {
+		names := []string{}
+		if len(*listfile) > 0 {
+			data, err := ioutil.ReadFile(expandPath(*listfile))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to read list file: %v\n", err.Error())
+				return exitFailure
+			}
+			for _, line := range strings.Split(string(data), "\n") {
+				line = strings.TrimSpace(line)
+				if len(line) > 0 {
+					names = append(names, line)
+				}
+			}
+		}
+
+		output, err := os.Create(expandPath(*repair))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to create output file: %v\n", err.Error())
+			return exitFailure
+		}
+		err = archive.Repair(output, names)
+		closeErr := output.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to repair MPQ: %v\n", err.Error())
+			return exitFailure
+		}
+		if *verbose {
+			fmt.Printf("Repaired archive written to %v\n", *repair)
+		}
+		return exitOk
+	}
+
+	if !report.Ok() {
+		return exitFailure
+	}
+	return exitOk
+}
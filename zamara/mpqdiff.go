@@ -0,0 +1,93 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/aphistic/go.Zamara/mpq"
+	"os"
+)
+
+func mpqDiff(args []string) int {
+	flagSet := newFlagSet("mpq diff")
+	content := flagSet.Bool("content", false, "Show line changes for text files.")
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 2 {
+		flagSet.Usage()
+		return exitUsage
+	}
+
+	archives := make([]*mpq.Mpq, 2)
+	for idx := range archives {
+		archive, err := openMpq(flagSet.Arg(idx))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read MPQ (%v): %v\n", flagSet.Arg(idx), err.Error())
+			return exitFailure
+		}
+		defer archive.Close()
+		archives[idx] = archive
+	}
+
+	diff, err := mpq.DiffMpq(archives[0], archives[1], mpq.DiffOptions{Content: *content})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to compare archives: %v\n", err.Error())
+		return exitFailure
+	}
+
+	for _, change := range diff.Header {
+		fmt.Printf("header %v\n", change)
+	}
+	for _, file := range diff.Files {
+		switch file.Kind {
+		case mpq.DiffAdded:
+			fmt.Printf("+ %v\n", file.Filename)
+		case mpq.DiffRemoved:
+			fmt.Printf("- %v\n", file.Filename)
+		default:
+			fmt.Printf("~ %v\n", file.Filename)
+			for _, change := range file.Changes {
+				fmt.Printf("    %v\n", change)
+			}
+			for _, line := range file.Lines {
+				switch line.Op {
+				case '-':
+					fmt.Printf("    -%v: %v\n", line.OldLine, line.Text)
+				case '+':
+					fmt.Printf("    +%v: %v\n", line.NewLine, line.Text)
+				}
+			}
+		}
+	}
+
+	if !diff.Empty() {
+		return exitFailure
+	}
+	return exitOk
+}
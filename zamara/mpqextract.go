@@ -31,66 +31,117 @@ package main
 import (
 	"fmt"
 	pmpq "github.com/aphistic/go.Zamara/mpq"
-	"log"
 	"os"
+	"strconv"
+	"strings"
 )
 
+// stringList collects the values of a flag that can be repeated.
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
 func extractMpq(flags zamaraFlags) {
-	err := os.MkdirAll(flags.output, 0755)
+	mpq, err := openMpq(flags.input)
 	if err != nil {
-		fmt.Printf("Could not access output location: %v\n", err.Error())
+		fmt.Fprintf(os.Stderr, "Error reading MPQ: %v\n", err.Error())
 		os.Exit(1)
 	}
+	defer mpq.Close()
 
-	reader, err := os.Open(flags.input)
-	if err != nil {
-		log.Printf("Error opening MPQ: %v\n", err.Error())
-		os.Exit(1)
+	options := pmpq.ExtractOptions{Overwrite: true}
+	os.Exit(extractFiles(mpq, flags.outputAbs, options, flags.verbose))
+}
+
+func mpqExtract(args []string) int {
+	var include, exclude, locales stringList
+
+	flagSet := newFlagSet("mpq extract")
+	flagSet.Var(&include, "include", "Only extract files matching this pattern. May be repeated.")
+	flagSet.Var(&exclude, "exclude", "Don't extract files matching this pattern. May be repeated.")
+	flagSet.Var(&locales, "locale", "Only extract files with this language ID, such as 0x409. May be repeated.")
+	overwrite := flagSet.Bool("overwrite", false, "Replace files that already exist instead of skipping them.")
+	verbose := flagSet.Bool("v", false, "List files as they're extracted.")
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 2 {
+		flagSet.Usage()
+		return exitUsage
+	}
+
+	options := pmpq.ExtractOptions{
+		Include:   include,
+		Exclude:   exclude,
+		Overwrite: *overwrite,
+	}
+	for _, locale := range locales {
+		language, err := strconv.ParseUint(locale, 0, 16)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid locale: %v\n", locale)
+			return exitUsage
+		}
+		options.Locales = append(options.Locales, uint16(language))
 	}
-	mpq, err := pmpq.NewMpq(reader)
+
+	archive, err := openMpq(flagSet.Arg(0))
 	if err != nil {
-		log.Printf("Error reading MPQ: %v\n", err.Error())
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Unable to read MPQ (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
 	}
+	defer archive.Close()
 
-	for _, file := range mpq.Files() {
-		cleanPath := cleanDirectory(flags.output) +
-			cleanFilename(file.Filename)
-		if flags.verbose {
-			fmt.Printf("Extracting %v to %v\n",
-				file.Filename, cleanPath)
-		}
+	return extractFiles(archive, expandPath(flagSet.Arg(1)), options, *verbose)
+}
 
-		_, err = mpq.File(file.Filename)
-		if err != nil {
-			fmt.Printf("Error extracting file %v\n%v\n",
-				file.Filename, err.Error())
-			continue
+// extractFiles extracts the files selected by options to the output
+// directory, reports on them and returns the exit code.
+func extractFiles(archive *pmpq.Mpq, output string, options pmpq.ExtractOptions, verbose bool) int {
+	options.Progress = func(result *pmpq.ExtractResult, done int, total int) {
+		switch {
+		case result.Err != nil:
+			fmt.Fprintf(os.Stderr, "Error extracting %v: %v\n",
+				result.Filename, result.Err.Error())
+		case result.Skipped && verbose:
+			fmt.Printf("[%v/%v] Skipping %v, %v already exists\n",
+				done, total, result.Filename, result.Path)
+		case verbose:
+			fmt.Printf("[%v/%v] Extracting %v to %v\n",
+				done, total, result.Filename, result.Path)
 		}
+	}
 
-		osFile, err := os.Create(cleanPath)
-		defer osFile.Close()
-		if err != nil {
-			fmt.Printf("Error creating file %v\n%v\n",
-				cleanPath, err.Error())
-			continue
-		}
+	results, err := archive.Extract(output, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err.Error())
+		return exitFailure
+	}
 
-		buffer := make([]byte, file.FileSize)
-		_, err = mpq.Read(buffer)
-		if err != nil && err != pmpq.EOF {
-			fmt.Printf("Error reading file %v from MPQ\n%v\n",
-				file.Filename, err.Error())
-			continue
+	extracted, skipped, failed := 0, 0, 0
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			failed++
+		case result.Skipped:
+			skipped++
+		default:
+			extracted++
 		}
+	}
 
-		_, err = osFile.Write(buffer)
-		if err != nil {
-			fmt.Printf("Error writing file %v to %v\n%v\n",
-				file.Filename, cleanPath, err.Error())
-			continue
-		}
+	if verbose || skipped > 0 || failed > 0 {
+		fmt.Fprintf(os.Stderr, "Extracted %v files, skipped %v, failed %v\n",
+			extracted, skipped, failed)
 	}
 
-	os.Exit(0)
+	if failed > 0 {
+		return exitFailure
+	}
+	return exitOk
 }
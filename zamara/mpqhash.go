@@ -0,0 +1,100 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/aphistic/go.Zamara/mpq"
+	"os"
+)
+
+func mpqHash(args []string) int {
+	flagSet := newFlagSet("mpq hash")
+	archivePath := flagSet.String("archive", "", "Archive to take the hash table size and file keys from.")
+	entries := flagSet.Uint("entries", 0, "Hash table size used for the table index.")
+	position := flagSet.Uint("position", 0, "Block position used for fixed file keys.")
+	size := flagSet.Uint("size", 0, "File size used for fixed file keys.")
+	fixKey := flagSet.Bool("fixkey", false, "Adjust the file key by the block position and file size.")
+	flagSet.Parse(args)
+
+	if flagSet.NArg() < 1 {
+		flagSet.Usage()
+		return exitUsage
+	}
+
+	var archive *mpq.Mpq
+	if len(*archivePath) > 0 {
+		var err error
+		archive, err = openMpq(*archivePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read MPQ (%v): %v\n", *archivePath, err.Error())
+			return exitFailure
+		}
+		defer archive.Close()
+		if *entries == 0 {
+			*entries = uint(archive.Header.HashTableEntries)
+		}
+	}
+
+	if *entries&(*entries-1) != 0 {
+		fmt.Fprintf(os.Stderr, "Hash table size must be a power of two: %v\n", *entries)
+		return exitUsage
+	}
+
+	flags := uint32(0)
+	if *fixKey {
+		flags = mpq.FlagFixKey
+	}
+
+	for idx, path := range flagSet.Args() {
+		if idx > 0 {
+			fmt.Printf("\n")
+		}
+
+		fmt.Printf("Path: %v\n", path)
+		fmt.Printf("Hash A: 0x%08x\n", mpq.HashString(path, mpq.HashTypeNameA))
+		fmt.Printf("Hash B: 0x%08x\n", mpq.HashString(path, mpq.HashTypeNameB))
+		fmt.Printf("Table Offset Hash: 0x%08x\n", mpq.HashString(path, mpq.HashTypeTableOffset))
+		if *entries > 0 {
+			fmt.Printf("Table Index: %v (of %v)\n",
+				mpq.HashTableIndex(path, uint32(*entries)), *entries)
+		}
+
+		key := mpq.FileKey(path, uint32(*position), uint32(*size), flags)
+		if archive != nil {
+			archiveKey, err := archive.FileKey(path)
+			if err == nil {
+				key = archiveKey
+			}
+		}
+		fmt.Printf("File Key: 0x%08x\n", key)
+	}
+
+	return exitOk
+}
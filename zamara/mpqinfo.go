@@ -0,0 +1,109 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/aphistic/go.Zamara/mpq"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+func mpqInfo(args []string) int {
+	flagSet := newFlagSet("mpq info")
+	format := formatFlag(flagSet)
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return exitUsage
+	}
+
+	archive, err := openMpq(flagSet.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read MPQ (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+	defer archive.Close()
+
+	return printOutput(*format, archive, func() {
+		mpqStdoutInfo(archive)
+	})
+}
+
+type fileList struct {
+	XMLName xml.Name    `xml:"files" json:"-"`
+	Files   []*mpq.File `xml:"file" json:"files"`
+}
+
+func mpqLs(args []string) int {
+	flagSet := newFlagSet("mpq ls")
+	long := flagSet.Bool("l", false, "Show sizes, flags and locales.")
+	format := formatFlag(flagSet)
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return exitUsage
+	}
+
+	archive, err := openMpq(flagSet.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read MPQ (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+	defer archive.Close()
+
+	list := &fileList{}
+	for _, file := range archive.Files() {
+		list.Files = append(list.Files, file)
+	}
+	sort.Slice(list.Files, func(i, j int) bool {
+		return list.Files[i].Filename < list.Files[j].Filename
+	})
+
+	return printOutput(*format, list, func() {
+		if !*long {
+			for _, file := range list.Files {
+				fmt.Println(file.Filename)
+			}
+			return
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(writer, "Size\tCompressed\tFlags\tLocale\t\n")
+		for _, file := range list.Files {
+			fmt.Fprintf(writer, "%v\t%v\t0x%08x\t0x%04x\t %v\n", file.FileSize,
+				file.CompressedSize, file.Flags, file.Language, file.Filename)
+		}
+		writer.Flush()
+	})
+}
@@ -0,0 +1,150 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/aphistic/go.Zamara/mpq"
+	"os"
+	"sort"
+)
+
+func mpqStats(flags zamaraFlags) {
+	archive, err := openMpq(flags.inputAbs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr,
+			"Unable to read MPQ (%v): %v\n", flags.input, err.Error())
+		os.Exit(1)
+	}
+	defer archive.Close()
+
+	fmt.Printf("Reading MPQ: %v\n\n", flags.input)
+	mpqStatsText(archive, archive.Stats())
+
+	os.Exit(0)
+}
+
+func mpqStatsCommand(args []string) int {
+	flagSet := newFlagSet("mpq stats")
+	format := flagSet.String("format", "text", "Output format. [text, json]")
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return exitUsage
+	}
+
+	archive, err := openMpq(flagSet.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read MPQ (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+	defer archive.Close()
+
+	stats := archive.Stats()
+	return printTextOrJson(*format, stats, func() {
+		mpqStatsText(archive, stats)
+	})
+}
+
+func mpqStatsText(archive *mpq.Mpq, stats *mpq.Stats) {
+	mpqStatsFiles(stats)
+	mpqStatsCodecs(stats)
+	mpqStatsSpace(archive, stats)
+}
+
+func mpqStatsFiles(stats *mpq.Stats) {
+	fmt.Printf("Files\n")
+	fmt.Printf("=====\n")
+	fmt.Printf("Block\tCompressedSize\tFileSize\tRatio\tCompression\tFilename\n")
+	fmt.Printf("-----\t--------------\t--------\t-----\t-----------\t--------\n")
+	for _, file := range stats.Files {
+		compression := "unknown"
+		if file.Encrypted {
+			compression = "encrypted"
+		} else if file.Compression != nil {
+			compression = ""
+			for idx, mask := range file.Compression {
+				if idx > 0 {
+					compression += ","
+				}
+				compression += mpq.CompressionName(mask)
+			}
+		}
+
+		filename := file.Filename
+		if len(filename) == 0 {
+			filename = "(unknown)"
+		}
+		if file.Orphaned {
+			filename += " [orphaned]"
+		}
+
+		fmt.Printf("%v\t%v\t\t%v\t\t%.2f\t%v\t\t%v\n", file.BlockIndex,
+			file.CompressedSize, file.FileSize, file.Ratio, compression, filename)
+	}
+	fmt.Printf("\n")
+	fmt.Printf("Total Compressed Size: %v\n", stats.CompressedSize)
+	fmt.Printf("Total File Size: %v\n", stats.FileSize)
+	fmt.Printf("Total Ratio: %.2f\n", stats.Ratio)
+	fmt.Printf("\n")
+}
+
+func mpqStatsCodecs(stats *mpq.Stats) {
+	masks := []int{}
+	for mask := range stats.Codecs {
+		masks = append(masks, int(mask))
+	}
+	sort.Ints(masks)
+
+	fmt.Printf("Compression\n")
+	fmt.Printf("===========\n")
+	for _, mask := range masks {
+		fmt.Printf("%v: %v\n", mpq.CompressionName(byte(mask)), stats.Codecs[byte(mask)])
+	}
+	fmt.Printf("\n")
+}
+
+func mpqStatsSpace(archive *mpq.Mpq, stats *mpq.Stats) {
+	fmt.Printf("Space\n")
+	fmt.Printf("=====\n")
+	fmt.Printf("Archive Size: %v\n", archive.Header.ArchiveSize)
+	fmt.Printf("Slack: %v bytes in %v ranges\n", stats.SlackSize, len(stats.Slack))
+	for _, slack := range stats.Slack {
+		fmt.Printf("  %#x: %v bytes\n", slack.Offset, slack.Size)
+	}
+	fmt.Printf("Hash Slots: %v (%v used, %v deleted, %v free)\n", stats.HashSlots,
+		stats.HashUsed, stats.HashDeleted, stats.HashSlots-stats.HashUsed-stats.HashDeleted)
+	fmt.Printf("Load Factor: %.2f\n", stats.LoadFactor)
+	fmt.Printf("Orphaned Blocks: %v\n", len(stats.OrphanedBlocks))
+	for _, idx := range stats.OrphanedBlocks {
+		fmt.Printf("  %v\n", idx)
+	}
+	fmt.Printf("\n")
+}
@@ -34,29 +34,29 @@ import (
 	"os"
 )
 
-func mpqStdout(flags zamaraFlags) {
-	reader, err := os.Open(flags.inputAbs)
+func mpqStdout(flags zamaraFlags) int {
+	mpq, err := openMpq(flags.inputAbs)
 	if err != nil {
 		fmt.Fprintf(os.Stderr,
-			"Unable to open MPQ (%v): %v", flags.input, err.Error())
-		os.Exit(1)
-	}
-
-	mpq, err := mpq.NewMpq(reader)
-	if err != nil {
-		fmt.Fprintf(os.Stderr,
-			"Unable to read MPQ: %v", err.Error())
-		os.Exit(1)
+			"Unable to read MPQ (%v): %v\n", flags.input, err.Error())
+		return exitFailure
 	}
+	defer mpq.Close()
 
 	fmt.Printf("Reading MPQ: %v\n", flags.input)
-	mpqStdoutHeader(flags, mpq)
-	mpqStdoutUserData(flags, mpq)
-	mpqStdoutHashTable(flags, mpq)
-	mpqStdoutBlockTable(flags, mpq)
+	mpqStdoutInfo(mpq)
+
+	return exitOk
+}
+
+func mpqStdoutInfo(mpq *mpq.Mpq) {
+	mpqStdoutHeader(mpq)
+	mpqStdoutUserData(mpq)
+	mpqStdoutHashTable(mpq)
+	mpqStdoutBlockTable(mpq)
 }
 
-func mpqStdoutHeader(flags zamaraFlags, mpq *mpq.Mpq) {
+func mpqStdoutHeader(mpq *mpq.Mpq) {
 	fmt.Printf("Archive Offset: %v\n\n", mpq.ArchiveOffset)
 
 	fmt.Printf("Header\n")
@@ -75,7 +75,7 @@ func mpqStdoutHeader(flags zamaraFlags, mpq *mpq.Mpq) {
 	fmt.Printf("\n")
 }
 
-func mpqStdoutUserData(flags zamaraFlags, mpq *mpq.Mpq) {
+func mpqStdoutUserData(mpq *mpq.Mpq) {
 	if mpq.HasUserData {
 		fmt.Printf("User Data\n")
 		fmt.Printf("=========\n")
@@ -86,7 +86,7 @@ func mpqStdoutUserData(flags zamaraFlags, mpq *mpq.Mpq) {
 	fmt.Printf("\n")
 }
 
-func mpqStdoutHashTable(flags zamaraFlags, mpq *mpq.Mpq) {
+func mpqStdoutHashTable(mpq *mpq.Mpq) {
 	fmt.Printf("Hash Table\n")
 	fmt.Printf("==========\n")
 	fmt.Printf("Index\tFilePathHashA\tFilePathHashB\tLanguage\tPlatform\tBlockIndex\n")
@@ -102,7 +102,7 @@ func mpqStdoutHashTable(flags zamaraFlags, mpq *mpq.Mpq) {
 	fmt.Printf("\n")
 }
 
-func mpqStdoutBlockTable(flags zamaraFlags, mpq *mpq.Mpq) {
+func mpqStdoutBlockTable(mpq *mpq.Mpq) {
 	fmt.Printf("Block Table\n")
 	fmt.Printf("===========\n")
 	fmt.Printf("FilePosition\tCompressedSize\tFileSize\tFlags\n")
@@ -29,66 +29,32 @@
 package main
 
 import (
-	"encoding/xml"
 	"fmt"
-	"github.com/aphistic/go.Zamara/mpq"
 	"os"
 )
 
 func mpqXml(flags zamaraFlags) {
 	if len(flags.outputAbs) <= 0 {
-		fmt.Printf("An output file must be specified.")
+		fmt.Fprintf(os.Stderr, "An output file must be specified.\n")
 		usage()
 	}
 
-	reader, err := os.Open(flags.inputAbs)
+	mpq, err := openMpq(flags.inputAbs)
 	if err != nil {
 		fmt.Fprintf(os.Stderr,
-			"Unable to open MPQ (%v): %v", flags.input, err.Error())
-		os.Exit(1)
-	}
-
-	mpq, err := mpq.NewMpq(reader)
-	if err != nil {
-		fmt.Fprintf(os.Stderr,
-			"Unable to read MPQ: %v", err.Error())
+			"Unable to read MPQ (%v): %v\n", flags.input, err.Error())
 		os.Exit(1)
 	}
+	defer mpq.Close()
 
 	if flags.verbose {
-		fmt.Printf("Reading MPQ: %v\n", flags.input)
-	}
-
-	xmlOutput, err := xml.MarshalIndent(mpq, "", "    ")
-	if err != nil {
-		fmt.Printf("Error: %v\n", err.Error())
-	}
-
-	writer, err := os.Create(flags.outputAbs)
-	if err != nil {
-		fmt.Fprintf(os.Stderr,
-			"Unable to open output file: %v", flags.outputAbs)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Reading MPQ: %v\n", flags.input)
 	}
 
-	_, err = writer.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\" ?>")
-	if err != nil {
-		fmt.Printf("There was a problem writing the XML file.\n%v", err.Error())
-		os.Exit(1)
-	}
-	_, err = writer.Write(xmlOutput)
-	if err != nil {
-		fmt.Printf("There was a problem writing the XML file.\n%v", err.Error())
-		os.Exit(1)
-	}
-	_, err = writer.WriteString("\n")
-	if err != nil {
-		fmt.Printf("There was a problem writing the XML file.\n%v", err.Error())
-		os.Exit(1)
-	}
+	writeXml(flags, mpq)
 
 	if flags.verbose {
-		fmt.Printf("The MPQ's XML was written to %v", flags.output)
+		fmt.Fprintf(os.Stderr, "The MPQ's XML was written to %v\n", flags.output)
 	}
 
 	os.Exit(0)
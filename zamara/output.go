@@ -0,0 +1,101 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// formatFlag adds the -format flag used by commands that can show
+// their output as text, JSON or XML.
+func formatFlag(flagSet *flag.FlagSet) *string {
+	return flagSet.String("format", "text", "Output format. [text, json, xml]")
+}
+
+func encodeJson(writer io.Writer, value interface{}) (err error) {
+	output, err := json.MarshalIndent(value, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(append(output, '\n'))
+	return err
+}
+
+func encodeXml(writer io.Writer, value interface{}) (err error) {
+	output, err := xml.MarshalIndent(value, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(writer, "<?xml version=\"1.0\" encoding=\"utf-8\" ?>\n")
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(append(output, '\n'))
+	return err
+}
+
+// printOutput writes value to stdout in the given format, calling text
+// to print it for the text format, and returns the exit code.
+func printOutput(format string, value interface{}, text func()) int {
+	var err error
+	switch strings.ToLower(format) {
+	case "text":
+		text()
+	case "json":
+		err = encodeJson(os.Stdout, value)
+	case "xml":
+		err = encodeXml(os.Stdout, value)
+	default:
+		fmt.Fprintf(os.Stderr, "Unrecognized output format: %v\n", format)
+		return exitUsage
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write output: %v\n", err.Error())
+		return exitFailure
+	}
+	return exitOk
+}
+
+// printTextOrJson is printOutput for values that have no XML form, such
+// as those with raw bytes or maps in them.
+func printTextOrJson(format string, value interface{}, text func()) int {
+	if strings.ToLower(format) == "xml" {
+		fmt.Fprintf(os.Stderr, "Unrecognized output format: %v\n", format)
+		return exitUsage
+	}
+
+	return printOutput(format, value, text)
+}
@@ -29,19 +29,10 @@
 package main
 
 import (
-	"os"
 	"os/user"
 	"strings"
 )
 
-func cleanDirectory(dir string) (cleanDir string) {
-	if strings.LastIndex(dir, string(os.PathSeparator)) != len(dir) {
-		return dir + string(os.PathSeparator)
-	}
-
-	return dir
-}
-
 func expandPath(path string) (expanded string) {
 	if strings.Index(path, "~") == 0 {
 		u, err := user.Current()
@@ -54,10 +45,3 @@ func expandPath(path string) (expanded string) {
 
 	return path
 }
-
-func cleanFilename(filename string) (cleanFilename string) {
-	cleanFilename = strings.Replace(filename, "(", "_", -1)
-	cleanFilename = strings.Replace(cleanFilename, ")", "_", -1)
-
-	return
-}
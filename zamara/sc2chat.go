@@ -0,0 +1,125 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/aphistic/go.Zamara/sc2"
+	"os"
+	"strings"
+	"time"
+)
+
+var recipientNames = map[int]string{
+	sc2.RecipientAll:       "All",
+	sc2.RecipientAllies:    "Allies",
+	sc2.RecipientObservers: "Observers",
+}
+
+func sc2Chat(args []string) int {
+	flagSet := newFlagSet("sc2 chat")
+	format := flagSet.String("format", "text", "Output format. [text, json]")
+	pings := flagSet.Bool("pings", false, "Include minimap pings.")
+	protocols := protocolFlag(flagSet)
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return exitUsage
+	}
+	switch strings.ToLower(*format) {
+	case "text", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "Unrecognized output format: %v\n", *format)
+		return exitUsage
+	}
+	if !loadProtocols(*protocols) {
+		return exitFailure
+	}
+
+	archive, err := openMpq(flagSet.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read replay (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+	defer archive.Close()
+
+	replay, err := sc2.NewReplayFromMpq(archive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read replay (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+
+	messages, err := replay.Messages()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err.Error())
+		return exitFailure
+	}
+
+	log := []sc2.Event{}
+	for _, message := range messages {
+		switch message.(type) {
+		case *sc2.ChatMessage:
+			log = append(log, message)
+		case *sc2.PingMessage:
+			if *pings {
+				log = append(log, message)
+			}
+		}
+	}
+
+	return printTextOrJson(*format, log, func() {
+		for _, message := range log {
+			sc2StdoutMessage(replay, message)
+		}
+	})
+}
+
+func sc2StdoutMessage(replay *sc2.Replay, message sc2.Event) {
+	header := message.Header()
+	sender := fmt.Sprintf("User %v", header.UserId)
+	if player := replay.UserPlayer(header.UserId); player != nil {
+		sender = player.Name
+	}
+
+	switch message := message.(type) {
+	case *sc2.ChatMessage:
+		fmt.Printf("[%v] %v to %v: %v\n", clockTime(header.Time), sender,
+			name(recipientNames, message.Recipient), message.Text)
+	case *sc2.PingMessage:
+		fmt.Printf("[%v] %v pinged %v at %.2f,%.2f\n", clockTime(header.Time), sender,
+			name(recipientNames, message.Recipient), message.Point.X, message.Point.Y)
+	}
+}
+
+// clockTime formats a time in the game as hh:mm:ss.
+func clockTime(duration time.Duration) string {
+	seconds := int(duration / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
@@ -0,0 +1,153 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aphistic/go.Zamara/sc2"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+func sc2Events(args []string) int {
+	flagSet := newFlagSet("sc2 events")
+	format := flagSet.String("format", "text", "Output format, json writes an event per line. [text, json]")
+	user := flagSet.Int("user", -1, "Only show events sent by this user ID.")
+	eventType := flagSet.String("type", "", "Only show events with this name, e.g. Cmd.")
+	protocols := protocolFlag(flagSet)
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return exitUsage
+	}
+	switch strings.ToLower(*format) {
+	case "text", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "Unrecognized output format: %v\n", *format)
+		return exitUsage
+	}
+	if !loadProtocols(*protocols) {
+		return exitFailure
+	}
+
+	// The events are read as they're printed, so keep the archive open
+	archive, err := openMpq(flagSet.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read replay (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+	defer archive.Close()
+
+	replay, err := sc2.NewReplayFromMpq(archive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read replay (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+
+	events, err := replay.GameEvents()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err.Error())
+		return exitFailure
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for {
+		event, err := events.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err.Error())
+			return exitFailure
+		}
+
+		header := event.Header()
+		if (*user >= 0 && header.UserId != *user) ||
+			(*eventType != "" && !strings.EqualFold(header.Name, *eventType)) {
+			continue
+		}
+
+		if strings.ToLower(*format) == "json" {
+			err = encoder.Encode(event)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to write output: %v\n", err.Error())
+				return exitFailure
+			}
+		} else {
+			fmt.Printf("%8v  %8v  %2v  %-20v %v\n", header.Loop,
+				replay.LoopTime(header.Loop).Truncate(time.Second), header.UserId,
+				header.Name, eventDetails(event))
+		}
+	}
+
+	return exitOk
+}
+
+// eventDetails describes the fields of an event for the text output.
+func eventDetails(event sc2.Event) string {
+	switch event := event.(type) {
+	case *sc2.CmdEvent:
+		details := "default"
+		if event.Ability != nil {
+			details = fmt.Sprintf("ability %v/%v", event.Ability.Link, event.Ability.CmdIndex)
+		}
+		if event.Unit != nil {
+			details += fmt.Sprintf(" unit %#x (type %v)", event.Unit.Tag, event.Unit.Link)
+		}
+		if event.Point != nil {
+			details += fmt.Sprintf(" at %.2f,%.2f", event.Point.X, event.Point.Y)
+		}
+		return details
+	case *sc2.SelectionDeltaEvent:
+		group := "selection"
+		if event.ControlGroupId != sc2.ActiveSelection {
+			group = fmt.Sprintf("group %v", event.ControlGroupId)
+		}
+		return fmt.Sprintf("%v remove %v add %v units", group,
+			event.RemoveMask.Bits, len(event.AddUnitTags))
+	case *sc2.ControlGroupUpdateEvent:
+		return fmt.Sprintf("%v group %v", name(controlGroupUpdateNames, event.Update), event.Index)
+	case *sc2.CameraUpdateEvent:
+		return fmt.Sprintf("%.2f,%.2f", event.Target.X, event.Target.Y)
+	case *sc2.ResourceTradeEvent:
+		return fmt.Sprintf("to %v %v", event.RecipientId, event.Resources)
+	case *sc2.GenericEvent:
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+
+	return ""
+}
@@ -0,0 +1,127 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"github.com/aphistic/go.Zamara/sc2"
+	"os"
+)
+
+// protocolFlag adds the flag for loading extra replay protocols.
+func protocolFlag(flagSet *flag.FlagSet) *stringList {
+	protocols := new(stringList)
+	flagSet.Var(protocols, "protocol", "Load replay protocols from a JSON file. May be repeated.")
+	return protocols
+}
+
+func loadProtocols(files stringList) bool {
+	for _, file := range files {
+		_, err := sc2.LoadProtocolFile(expandPath(file))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to load protocol (%v): %v\n", file, err.Error())
+			return false
+		}
+	}
+
+	return true
+}
+
+func sc2Info(args []string) int {
+	flagSet := newFlagSet("sc2 info")
+	format := formatFlag(flagSet)
+	protocols := protocolFlag(flagSet)
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return exitUsage
+	}
+	if !loadProtocols(*protocols) {
+		return exitFailure
+	}
+
+	replay, err := loadReplay(expandPath(flagSet.Arg(0)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read replay (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+
+	return printOutput(*format, replay, func() {
+		sc2StdoutSummary(replay)
+		sc2StdoutPlayers(replay)
+	})
+}
+
+type playerList struct {
+	XMLName xml.Name      `xml:"players" json:"-"`
+	Players []*sc2.Player `xml:"player" json:"players"`
+}
+
+func sc2Players(args []string) int {
+	flagSet := newFlagSet("sc2 players")
+	format := formatFlag(flagSet)
+	protocols := protocolFlag(flagSet)
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return exitUsage
+	}
+	if !loadProtocols(*protocols) {
+		return exitFailure
+	}
+
+	// Outcomes replay.details doesn't have are inferred from the game
+	// events, so keep the archive open
+	archive, err := openMpq(expandPath(flagSet.Arg(0)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read replay (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+	defer archive.Close()
+
+	replay, err := sc2.NewReplayFromMpq(archive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read replay (%v): %v\n", flagSet.Arg(0), err.Error())
+		return exitFailure
+	}
+
+	// The players are still listed if that fails
+	err = replay.ResolveOutcomes()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to infer outcomes (%v): %v\n", flagSet.Arg(0), err.Error())
+	}
+
+	return printOutput(*format, &playerList{Players: replay.Players}, func() {
+		sc2StdoutPlayers(replay)
+	})
+}
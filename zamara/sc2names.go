@@ -0,0 +1,125 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"github.com/aphistic/go.Zamara/sc2"
+)
+
+var gameTypeNames = map[int]string{
+	sc2.Game1v1:    "1v1",
+	sc2.Game2v2:    "2v2",
+	sc2.Game3v3:    "3v3",
+	sc2.Game4v4:    "4v4",
+	sc2.Game5v5:    "5v5",
+	sc2.GameFfa:    "FFA",
+	sc2.Game6v6:    "6v6",
+	sc2.GameCustom: "Custom",
+}
+
+var gameSpeedNames = map[int]string{
+	sc2.SpeedSlower: "Slower",
+	sc2.SpeedSlow:   "Slow",
+	sc2.SpeedNormal: "Normal",
+	sc2.SpeedFast:   "Fast",
+	sc2.SpeedFaster: "Faster",
+}
+
+var gameCategoryNames = map[int]string{
+	sc2.CategoryPrivate: "Private",
+	sc2.CategoryLadder:  "Ladder",
+	sc2.CategoryPublic:  "Public",
+}
+
+var playerTypeNames = map[int]string{
+	sc2.PlayerHuman:    "Human",
+	sc2.PlayerComputer: "Computer",
+	sc2.PlayerOpen:     "Open",
+	sc2.PlayerClosed:   "Closed",
+}
+
+var roleNames = map[int]string{
+	sc2.RoleParticipant: "Participant",
+	sc2.RoleObserver:    "Observer",
+	sc2.RoleReferee:     "Referee",
+}
+
+var raceNames = map[int]string{
+	sc2.RaceRandom:  "Random",
+	sc2.RaceTerran:  "Terran",
+	sc2.RaceProtoss: "Protoss",
+	sc2.RaceZerg:    "Zerg",
+}
+
+var difficultyNames = map[int]string{
+	sc2.DifficultyVeryEasy: "Very Easy",
+	sc2.DifficultyEasy:     "Easy",
+	sc2.DifficultyMedium:   "Medium",
+	sc2.DifficultyHard:     "Hard",
+	sc2.DifficultyVeryHard: "Very Hard",
+	sc2.DifficultyInsane:   "Insane",
+}
+
+var colorNames = map[int]string{
+	sc2.ColorRed:        "Red",
+	sc2.ColorBlue:       "Blue",
+	sc2.ColorTeal:       "Teal",
+	sc2.ColorPurple:     "Purple",
+	sc2.ColorYellow:     "Yellow",
+	sc2.ColorOrange:     "Orange",
+	sc2.ColorGreen:      "Green",
+	sc2.ColorLightPink:  "Light Pink",
+	sc2.ColorViolet:     "Violet",
+	sc2.ColorLightGrey:  "Light Grey",
+	sc2.ColorDarkGreen:  "Dark Green",
+	sc2.ColorBrown:      "Brown",
+	sc2.ColorLightGreen: "Light Green",
+	sc2.ColorDarkGrey:   "Dark Grey",
+	sc2.ColorPink:       "Pink",
+}
+
+var controlGroupUpdateNames = map[int]string{
+	sc2.ControlGroupSet:    "Set",
+	sc2.ControlGroupAppend: "Append to",
+	sc2.ControlGroupRecall: "Recall",
+}
+
+var outcomeNames = map[int]string{
+	sc2.OutcomeWin:  "Win",
+	sc2.OutcomeLoss: "Loss",
+	sc2.OutcomeTie:  "Tie",
+}
+
+// name returns the name of value in names, or "Unknown".
+func name(names map[int]string, value int) string {
+	if name, found := names[value]; found {
+		return name
+	}
+	return "Unknown"
+}
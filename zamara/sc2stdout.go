@@ -0,0 +1,121 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/aphistic/go.Zamara/sc2"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// loadReplay reads a replay from disk or from inside another archive.
+func loadReplay(path string) (replay *sc2.Replay, err error) {
+	archive, err := openMpq(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	return sc2.NewReplayFromMpq(archive)
+}
+
+// openReplay reads the replay named by the input flag and exits if it
+// can't.
+func openReplay(flags zamaraFlags) (replay *sc2.Replay) {
+	replay, err := loadReplay(flags.inputAbs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr,
+			"Unable to read replay (%v): %v\n", flags.input, err.Error())
+		os.Exit(1)
+	}
+
+	return replay
+}
+
+func sc2Stdout(flags zamaraFlags) int {
+	replay := openReplay(flags)
+
+	fmt.Printf("Reading Replay: %v\n\n", flags.input)
+	sc2StdoutSummary(replay)
+	sc2StdoutPlayers(replay)
+
+	return exitOk
+}
+
+func sc2StdoutSummary(replay *sc2.Replay) {
+	fmt.Printf("Replay\n")
+	fmt.Printf("======\n")
+	fmt.Printf("Map: %v\n", replay.MapName)
+	fmt.Printf("Version: %v (base build %v)\n", replay.Version, replay.Version.BaseBuild)
+	fmt.Printf("Duration: %v (%v game loops)\n", replay.Duration.Truncate(time.Second), replay.GameLoops)
+	fmt.Printf("Timestamp: %v\n", replay.Timestamp.Format("2006-01-02 15:04:05 -0700"))
+	fmt.Printf("Game Type: %v\n", name(gameTypeNames, replay.GameType))
+	fmt.Printf("Game Speed: %v\n", name(gameSpeedNames, replay.GameSpeed))
+	fmt.Printf("Game Category: %v\n", name(gameCategoryNames, replay.GameCategory))
+	fmt.Printf("\n")
+}
+
+func sc2StdoutPlayers(replay *sc2.Replay) {
+	fmt.Printf("Players\n")
+	fmt.Printf("=======\n")
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(writer, "Name\tType\tRace\tColor\tTeam\tHandicap\tDifficulty\tOutcome\n")
+	fmt.Fprintf(writer, "----\t----\t----\t-----\t----\t--------\t----------\t-------\n")
+	for _, player := range replay.Players {
+		race := name(raceNames, player.ActualRace)
+		if player.ChosenRace == sc2.RaceRandom {
+			race += " (Random)"
+		}
+
+		difficulty := ""
+		if player.Type == sc2.PlayerComputer {
+			difficulty = name(difficultyNames, player.Difficulty)
+		}
+
+		team := fmt.Sprint(player.Team + 1)
+		if player.Role != sc2.RoleParticipant {
+			team = name(roleNames, player.Role)
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v%%\t%v\t%v\n",
+			player.Name,
+			name(playerTypeNames, player.Type),
+			race,
+			name(colorNames, player.NamedColor),
+			team,
+			player.Handicap,
+			difficulty,
+			name(outcomeNames, player.Outcome))
+	}
+	writer.Flush()
+	fmt.Printf("\n")
+}
@@ -28,10 +28,13 @@
 
 package main
 
-func handleStdout(flags zamaraFlags) {
+func handleStdout(flags zamaraFlags) int {
 	switch flags.runType {
 	case "mpq":
-		mpqStdout(flags)
-		break
+		return mpqStdout(flags)
+	case "sc2":
+		return sc2Stdout(flags)
 	}
+
+	return exitUsage
 }
@@ -0,0 +1,75 @@
+/* go.Zamara Library
+ * Copyright (c) 2012, Erik Davidson
+ * All rights reserved.
+ * 
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice,
+ *    this list of conditions and the following disclaimer.
+ * 
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ *    this list of conditions and the following disclaimer in the documentation
+ *    and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	. "launchpad.net/gocheck"
+	"os"
+	"testing"
+)
+
+// Hook into gotest
+func Test(t *testing.T) { TestingT(t) }
+
+type StdoutSuite struct {
+	stdout *os.File
+}
+
+var _ = Suite(&StdoutSuite{})
+
+func (s *StdoutSuite) SetUpTest(c *C) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	c.Assert(err, IsNil)
+	s.stdout, os.Stdout = os.Stdout, devNull
+}
+
+func (s *StdoutSuite) TearDownTest(c *C) {
+	os.Stdout.Close()
+	os.Stdout = s.stdout
+}
+
+func (s *StdoutSuite) TestLegacyStdoutExitCode(c *C) {
+	flags := zamaraFlags{
+		input:    "replay1.SC2Replay",
+		inputAbs: "../mpq/testdata/replay1.SC2Replay",
+		format:   "stdout",
+	}
+
+	flags.runType = "mpq"
+	c.Check(handleStdout(flags), Equals, exitOk)
+	flags.runType = "sc2"
+	c.Check(handleStdout(flags), Equals, exitOk)
+
+	flags.runType = "mpq"
+	flags.inputAbs = "../mpq/testdata/not_a_replay.SC2Replay"
+	c.Check(handleStdout(flags), Equals, exitFailure)
+
+	flags.runType = "unknown"
+	c.Check(handleStdout(flags), Equals, exitUsage)
+}
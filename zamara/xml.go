@@ -28,10 +28,36 @@
 
 package main
 
+import (
+	"fmt"
+	"os"
+)
+
 func handleXml(flags zamaraFlags) {
 	switch flags.runType {
 	case "mpq":
 		mpqXml(flags)
 		break
+	case "sc2":
+		sc2Xml(flags)
+		break
+	}
+}
+
+// writeXml writes value as an XML document to the output file and
+// exits if it can't.
+func writeXml(flags zamaraFlags, value interface{}) {
+	writer, err := os.Create(flags.outputAbs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr,
+			"Unable to open output file: %v\n", flags.outputAbs)
+		os.Exit(1)
+	}
+	defer writer.Close()
+
+	err = encodeXml(writer, value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "There was a problem writing the XML file.\n%v\n", err.Error())
+		os.Exit(1)
 	}
 }